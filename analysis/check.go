@@ -0,0 +1,304 @@
+package analysis
+
+import (
+	"fmt"
+	"mscript/ast"
+	"mscript/evaluator"
+	"mscript/object"
+	"mscript/token"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error   Severity = iota //Fails at runtime when reached
+	Warning                 //Most likely a mistake
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// A problem found in a program, End is just past the code it is about
+type Diagnostic struct {
+	Start    Position
+	End      Position
+	Severity Severity
+	Check    string //Name of the check that reported it
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", d.Start.Line, d.Start.Column, d.Severity, d.Message, d.Check)
+}
+
+// Number of arguments builtins take, those missing take any number
+var builtinArity = map[string]int{
+	"readline": 0,
+	"len":      1,
+}
+
+// Runs every check on program and returns what they found in source order
+func Check(program *ast.Program) []Diagnostic {
+	info := Resolve(program)
+	var diagnostics []Diagnostic
+	diagnostics = append(diagnostics, undefined(info)...)
+	diagnostics = append(diagnostics, unused(info)...)
+	diagnostics = append(diagnostics, shadowed(info)...)
+	diagnostics = append(diagnostics, unreachable(program)...)
+	diagnostics = append(diagnostics, arity(program, info)...)
+	diagnostics = append(diagnostics, constantConditions(program)...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Start.Before(diagnostics[j].Start)
+	})
+	return diagnostics
+}
+
+// Names that evaluating would fail to find
+func undefined(info *Info) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, ident := range info.Unresolved {
+		if info.quiet[ident] {
+			continue
+		}
+		diagnostics = append(diagnostics, identDiagnostic(ident, Error, "undefined", "identifier not found: "+ident.Value))
+	}
+	return diagnostics
+}
+
+// Lets, parameters and imports nothing refers to
+// Exports are used by importers and names starting with _ are unused on purpose
+func unused(info *Info) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, b := range info.Bindings {
+		if len(b.Uses) != 0 || b.Exported || strings.HasPrefix(b.Name, "_") {
+			continue
+		}
+		var message string
+		switch b.Kind {
+		case Parameter:
+			message = "parameter " + b.Name + " is never used"
+		case Import:
+			message = "module " + b.Name + " is imported but never used"
+		default:
+			message = b.Name + " is declared but never used"
+		}
+		diagnostics = append(diagnostics, bindingDiagnostic(b, Warning, "unused", message))
+	}
+	return diagnostics
+}
+
+// Bindings inside functions that hide a binding of an enclosing scope or a builtin
+func shadowed(info *Info) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, b := range info.Bindings {
+		if object.GetBuiltinByName(b.Name) != nil {
+			diagnostics = append(diagnostics, bindingDiagnostic(b, Warning, "shadow", b.Name+" shadows the builtin "+b.Name))
+			continue
+		}
+		for s := b.Scope.Parent; s != nil; s = s.Parent {
+			if outer := s.lookupName(b.Name); outer != nil {
+				message := fmt.Sprintf("%s shadows the %s on line %d", b.Name, outer.Kind, outer.Pos.Line)
+				diagnostics = append(diagnostics, bindingDiagnostic(b, Warning, "shadow", message))
+				break
+			}
+		}
+	}
+	return diagnostics
+}
+
+// Returns the first binding called name in s
+func (s *Scope) lookupName(name string) *Binding {
+	for _, b := range s.Bindings {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// Statements following a return in the same block, they never run
+func unreachable(program *ast.Program) []Diagnostic {
+	var diagnostics []Diagnostic
+	check := func(stmts []ast.Statement) {
+		for i, stmt := range stmts {
+			if _, ok := stmt.(*ast.ReturnStatement); ok && i+1 < len(stmts) {
+				start := StatementPos(stmts[i+1])
+				diagnostics = append(diagnostics, Diagnostic{
+					Start:    start,
+					End:      Position{start.Line, start.Column + len(stmts[i+1].TokenLiteral())},
+					Severity: Warning,
+					Check:    "unreachable",
+					Message:  "unreachable code after return",
+				})
+				return
+			}
+		}
+	}
+	check(program.Statements)
+	inspect(program, func(node ast.Node) {
+		if block, ok := node.(*ast.BlockStatement); ok {
+			check(block.Statements)
+		}
+	})
+	return diagnostics
+}
+
+// Calls of functions, macros and builtins known before running with the wrong number of arguments
+func arity(program *ast.Program, info *Info) []Diagnostic {
+	var diagnostics []Diagnostic
+	inspect(program, func(node ast.Node) {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return
+		}
+		want, name := -1, ""
+		switch fn := call.Function.(type) {
+		case *ast.FunctionLiteral:
+			want = len(fn.Parameters)
+		case *ast.Identifier:
+			name = fn.Value
+			if b := info.Uses[fn]; b != nil {
+				switch value := b.Value.(type) {
+				case *ast.FunctionLiteral:
+					want = len(value.Parameters)
+				case *ast.MacroLiteral:
+					want = len(value.Parameters)
+				}
+			} else if n, ok := builtinArity[fn.Value]; ok && object.GetBuiltinByName(fn.Value) != nil {
+				want = n
+			}
+		}
+		if want < 0 || want == len(call.Arguments) {
+			return
+		}
+		message := fmt.Sprintf("wrong number of arguments: want=%d, got=%d", want, len(call.Arguments))
+		if name != "" {
+			message = fmt.Sprintf("wrong number of arguments to %s: want=%d, got=%d", name, want, len(call.Arguments))
+		}
+		start := Position{call.Token.Line, call.Token.Column}
+		diagnostics = append(diagnostics, Diagnostic{
+			Start:    start,
+			End:      Position{start.Line, start.Column + 1},
+			Severity: Error,
+			Check:    "arity",
+			Message:  message,
+		})
+	})
+	return diagnostics
+}
+
+// If conditions built only from literals, their branch is always or never taken
+func constantConditions(program *ast.Program) []Diagnostic {
+	var diagnostics []Diagnostic
+	inspect(program, func(node ast.Node) {
+		ifExp, ok := node.(*ast.IfExpression)
+		if !ok {
+			return
+		}
+		truthy, ok := constantTruth(ifExp.Condition)
+		if !ok {
+			return
+		}
+		message := "condition is always false"
+		if truthy {
+			message = "condition is always true"
+		}
+		start := Position{ifExp.Token.Line, ifExp.Token.Column}
+		diagnostics = append(diagnostics, Diagnostic{
+			Start:    start,
+			End:      Position{start.Line, start.Column + len(ifExp.Token.Literal)},
+			Severity: Warning,
+			Check:    "constant-condition",
+			Message:  message,
+		})
+	})
+	return diagnostics
+}
+
+// Evaluates exp when it does not depend on any binding and reports whether the result is truthy
+// Expressions that fail, like 1 / 0, are not constant conditions
+func constantTruth(exp ast.Expression) (truthy bool, ok bool) {
+	if _, isFunction := exp.(*ast.FunctionLiteral); !isFunction {
+		constant := true
+		ast.Inspect(exp, func(node ast.Node) bool {
+			switch node.(type) {
+			case *ast.Identifier, *ast.CallExpression, *ast.MemberExpression, *ast.MacroLiteral:
+				constant = false
+			}
+			return constant
+		})
+		if !constant {
+			return false, false
+		}
+	}
+
+	result := evaluator.Eval(exp, object.NewEnvironment())
+	if result == nil || result.Type() == object.ERROR_OBJ {
+		return false, false
+	}
+	return result != object.NULL && result != object.FALSE, true
+}
+
+// Calls f for every node of program except those inside quotes, which are not run as written
+func inspect(program *ast.Program, f func(ast.Node)) {
+	ast.Inspect(program, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok && isCallOf(call, "quote") {
+			return false
+		}
+		if node != nil {
+			f(node)
+		}
+		return true
+	})
+}
+
+func identDiagnostic(ident *ast.Identifier, severity Severity, check, message string) Diagnostic {
+	start := identPos(ident)
+	return Diagnostic{
+		Start:    start,
+		End:      Position{start.Line, start.Column + len(ident.Value)},
+		Severity: severity,
+		Check:    check,
+		Message:  message,
+	}
+}
+
+// For imports without a name the diagnostic covers the import keyword
+func bindingDiagnostic(b *Binding, severity Severity, check, message string) Diagnostic {
+	if b.Ident.Token.Line == 0 {
+		return Diagnostic{
+			Start:    b.Pos,
+			End:      Position{b.Pos.Line, b.Pos.Column + len(b.Import.TokenLiteral())},
+			Severity: severity,
+			Check:    check,
+			Message:  message,
+		}
+	}
+	return identDiagnostic(b.Ident, severity, check, message)
+}
+
+// Where stmt starts, its first token
+func StatementPos(stmt ast.Statement) Position {
+	var tok token.Token
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		tok = stmt.Token
+	case *ast.ReturnStatement:
+		tok = stmt.Token
+	case *ast.ExpressionStatement:
+		tok = stmt.Token
+	case *ast.ImportStatement:
+		tok = stmt.Token
+	case *ast.ExportStatement:
+		tok = stmt.Token
+	case *ast.BlockStatement:
+		tok = stmt.Token
+	}
+	return Position{tok.Line, tok.Column}
+}
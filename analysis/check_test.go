@@ -0,0 +1,61 @@
+package analysis
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; puts(x)", nil},
+		{"puts(y)", []string{"1:6: error: identifier not found: y (undefined)"}},
+		{"let f = fn() {\n  g + h\n};\nf()", []string{
+			"2:3: error: identifier not found: g (undefined)",
+			"2:7: error: identifier not found: h (undefined)",
+		}},
+		//Unused bindings
+		{"let x = 1;", []string{"1:5: warning: x is declared but never used (unused)"}},
+		{"let f = fn(a, _b) { 1 }; f(1, 2)", []string{"1:12: warning: parameter a is never used (unused)"}},
+		{"import \"m.ms\" as m;", []string{"1:18: warning: module m is imported but never used (unused)"}},
+		{"import \"m.ms\";", []string{"1:1: warning: module m is imported but never used (unused)"}},
+		{"export let x = 1;", nil},
+		{"let x = 1; let x = 2; puts(x)", []string{"1:5: warning: x is declared but never used (unused)"}},
+		//Shadowing
+		{"let x = 1; let f = fn(x) { x }; puts(f(x))", []string{"1:23: warning: x shadows the let on line 1 (shadow)"}},
+		{"let x = 1;\nlet f = fn() { let x = 2; x }; puts(f(), x)", []string{"2:20: warning: x shadows the let on line 1 (shadow)"}},
+		{"let len = fn(a) { a }; len(1)", []string{"1:5: warning: len shadows the builtin len (shadow)"}},
+		//Unreachable code
+		{"let f = fn() {\n  return 1;\n  puts(2);\n  3\n}; f()", []string{"3:3: warning: unreachable code after return (unreachable)"}},
+		{"return 1; puts(2);", []string{"1:11: warning: unreachable code after return (unreachable)"}},
+		//Arity
+		{"let add = fn(a, b) { a + b }; add(1)", []string{"1:34: error: wrong number of arguments to add: want=2, got=1 (arity)"}},
+		{"len(1, 2); readline(1); puts(1, 2, 3)", []string{
+			"1:4: error: wrong number of arguments to len: want=1, got=2 (arity)",
+			"1:20: error: wrong number of arguments to readline: want=0, got=1 (arity)",
+		}},
+		{"fn(a) { a }()", []string{"1:12: error: wrong number of arguments: want=1, got=0 (arity)"}},
+		{"let m = macro(a) { quote(unquote(a)) }; m(1, 2)", []string{"1:42: error: wrong number of arguments to m: want=1, got=2 (arity)"}},
+		//Constant conditions
+		{"if (true) { 1 }", []string{"1:1: warning: condition is always true (constant-condition)"}},
+		{"if (1 > 2) { 1 }", []string{"1:1: warning: condition is always false (constant-condition)"}},
+		{"if (!\"\") { 1 } else { 2 }", []string{"1:1: warning: condition is always false (constant-condition)"}},
+		{"if ([1][5]) { 1 }", []string{"1:1: warning: condition is always false (constant-condition)"}},
+		{"if (1 / 0) { 1 }", nil},
+		{"let x = 1; if (x > 2) { 1 }", nil},
+		//Quoted code is not checked
+		{"let m = macro() { quote(if (true) { len() }) }; m()", nil},
+	}
+
+	for _, tt := range tests {
+		got := Check(parse(t, tt.input))
+		if len(got) != len(tt.expected) {
+			t.Errorf("%q: wrong diagnostics. got=%v, want=%v", tt.input, got, tt.expected)
+			continue
+		}
+		for i, d := range got {
+			if d.String() != tt.expected[i] {
+				t.Errorf("%q: diagnostics[%d] = %q, want %q", tt.input, i, d, tt.expected[i])
+			}
+		}
+	}
+}
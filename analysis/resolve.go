@@ -0,0 +1,390 @@
+// Package analysis resolves the names of a program to the bindings they refer to
+// and checks programs for mistakes without running them.
+package analysis
+
+import (
+	"mscript/ast"
+	"mscript/object"
+	"mscript/token"
+)
+
+// A source position, lines and columns start at 1 and columns count bytes
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Reports whether p comes before q
+func (p Position) Before(q Position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+}
+
+// What introduced a binding
+type Kind int
+
+const (
+	Let Kind = iota
+	Parameter
+	Import
+	Macro //A top level let of a macro literal
+)
+
+var kindNames = map[Kind]string{
+	Let:       "let",
+	Parameter: "parameter",
+	Import:    "import",
+	Macro:     "macro",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// A name introduced by a let statement, a parameter or an import
+type Binding struct {
+	Name     string
+	Kind     Kind
+	Ident    *ast.Identifier
+	Pos      Position       //Where the name is written, the import token for imports without 'as'
+	Value    ast.Expression //The value of a let, nil for other kinds
+	Import   *ast.ImportStatement
+	Exported bool
+	Scope    *Scope
+	Uses     []*ast.Identifier //In source order
+
+	seq int //Order of declaration among bindings and uses
+}
+
+// The bindings of a program or of a function or macro body
+// Blocks do not open scopes, like in the evaluator a let inside an if is visible in the rest of the function
+type Scope struct {
+	Parent   *Scope
+	Node     ast.Node //The program or the function or macro literal
+	Start    Position
+	End      Position //The closing brace, the zero position for the program
+	Bindings []*Binding
+
+	open int //seq when the scope was opened
+}
+
+// Reports whether pos is inside s
+func (s *Scope) Contains(pos Position) bool {
+	if s.Parent == nil {
+		return true
+	}
+	return !pos.Before(s.Start) && !s.End.Before(pos)
+}
+
+// Returns the bindings visible inside s, inner ones hide outer ones with the same name
+func (s *Scope) Visible() []*Binding {
+	var visible []*Binding
+	seen := map[string]bool{}
+	for ; s != nil; s = s.Parent {
+		for i := len(s.Bindings) - 1; i >= 0; i-- {
+			b := s.Bindings[i]
+			if !seen[b.Name] {
+				seen[b.Name] = true
+				visible = append(visible, b)
+			}
+		}
+	}
+	return visible
+}
+
+// The result of resolving a program
+type Info struct {
+	Global     *Scope
+	Scopes     []*Scope   //Global first, then in source order
+	Bindings   []*Binding //In source order
+	Uses       map[*ast.Identifier]*Binding
+	Builtins   []*ast.Identifier //Uses of builtins
+	Unresolved []*ast.Identifier //Names that are neither bound nor builtins
+
+	quiet map[*ast.Identifier]bool //Unresolved names in arguments to macros, which may never be evaluated
+}
+
+// Finds the identifier at pos, either where a binding is declared or where one is used
+// The binding is nil for builtins and unresolved names
+func (info *Info) At(pos Position) (*ast.Identifier, *Binding) {
+	covers := func(ident *ast.Identifier) bool {
+		start := identPos(ident)
+		return start.Line == pos.Line && start.Column <= pos.Column && pos.Column <= start.Column+len(ident.Value)
+	}
+	for _, b := range info.Bindings {
+		if b.Ident.Token.Line != 0 && covers(b.Ident) {
+			return b.Ident, b
+		}
+	}
+	for ident, b := range info.Uses {
+		if covers(ident) {
+			return ident, b
+		}
+	}
+	for _, ident := range info.Builtins {
+		if covers(ident) {
+			return ident, nil
+		}
+	}
+	return nil, nil
+}
+
+// Returns the innermost scope containing pos
+func (info *Info) ScopeAt(pos Position) *Scope {
+	scope := info.Global
+	for _, s := range info.Scopes {
+		if s.Parent != nil && s.Contains(pos) {
+			scope = s
+		}
+	}
+	return scope
+}
+
+// Resolves every name in program to the binding it refers to
+// Uses refer to the latest binding of the name before them in their own scope,
+// inside functions a later binding in an outer scope counts as well since the function may run after it is made
+func Resolve(program *ast.Program) *Info {
+	r := &resolver{
+		info:   &Info{Uses: map[*ast.Identifier]*Binding{}, quiet: map[*ast.Identifier]bool{}},
+		macros: map[string]bool{},
+	}
+	//Macros are defined before anything is expanded so calls may come before the definition
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			if _, ok := let.Value.(*ast.MacroLiteral); ok {
+				r.macros[let.Name.Value] = true
+			}
+		}
+	}
+
+	r.info.Global = r.openScope(program, Position{1, 1}, Position{})
+	r.statements(program.Statements)
+	r.resolveUses()
+	return r.info
+}
+
+type use struct {
+	ident *ast.Identifier
+	scope *Scope
+	seq   int
+	quiet bool
+}
+
+type resolver struct {
+	info   *Info
+	scope  *Scope
+	seq    int
+	quiet  int //Above 0 inside arguments to macros
+	uses   []use
+	macros map[string]bool
+}
+
+func (r *resolver) next() int {
+	r.seq++
+	return r.seq
+}
+
+func (r *resolver) openScope(node ast.Node, start, end Position) *Scope {
+	s := &Scope{Parent: r.scope, Node: node, Start: start, End: end, open: r.next()}
+	r.info.Scopes = append(r.info.Scopes, s)
+	r.scope = s
+	return s
+}
+
+func (r *resolver) declare(ident *ast.Identifier, kind Kind) *Binding {
+	b := &Binding{Name: ident.Value, Kind: kind, Ident: ident, Pos: identPos(ident), Scope: r.scope, seq: r.next()}
+	r.scope.Bindings = append(r.scope.Bindings, b)
+	r.info.Bindings = append(r.info.Bindings, b)
+	return b
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement) *Binding {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		//The value is evaluated before the name is set, let x = x refers to an earlier x
+		r.expression(stmt.Value)
+		kind := Let
+		if _, ok := stmt.Value.(*ast.MacroLiteral); ok && r.scope.Parent == nil {
+			kind = Macro
+		}
+		b := r.declare(stmt.Name, kind)
+		b.Value = stmt.Value
+		return b
+
+	case *ast.ReturnStatement:
+		r.expression(stmt.ReturnValue)
+
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+
+	case *ast.BlockStatement:
+		r.statements(stmt.Statements)
+
+	case *ast.ImportStatement:
+		b := r.declare(stmt.Name, Import)
+		b.Import = stmt
+		if stmt.Name.Token.Line == 0 {
+			b.Pos = Position{stmt.Token.Line, stmt.Token.Column}
+		}
+		return b
+
+	case *ast.ExportStatement:
+		if b := r.statement(stmt.Statement); b != nil {
+			b.Exported = true
+		}
+	}
+	return nil
+}
+
+func (r *resolver) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		r.uses = append(r.uses, use{ident: exp, scope: r.scope, seq: r.next(), quiet: r.quiet > 0})
+
+	case *ast.PrefixExpression:
+		r.expression(exp.Right)
+
+	case *ast.InfixExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Right)
+
+	case *ast.IfExpression:
+		r.expression(exp.Condition)
+		r.statement(exp.Consequence)
+		if exp.Alternative != nil {
+			r.statement(exp.Alternative)
+		}
+
+	case *ast.FunctionLiteral:
+		r.function(exp, exp.Token, exp.Parameters, exp.Body)
+
+	case *ast.MacroLiteral:
+		r.function(exp, exp.Token, exp.Parameters, exp.Body)
+
+	case *ast.CallExpression:
+		r.call(exp)
+
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			r.expression(el)
+		}
+
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			r.expression(key)
+			r.expression(exp.Pairs[key])
+		}
+
+	case *ast.IndexExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Index)
+
+	case *ast.MemberExpression:
+		//The property names an export of the module, not a binding
+		r.expression(exp.Object)
+	}
+}
+
+// Resolves a function or macro literal in a scope of its own, tok is its first token
+func (r *resolver) function(node ast.Node, tok token.Token, params []*ast.Identifier, body *ast.BlockStatement) {
+	outer := r.scope
+	r.openScope(node, Position{tok.Line, tok.Column}, Position{body.Rbrace.Line, body.Rbrace.Column})
+	for _, param := range params {
+		r.declare(param, Parameter)
+	}
+	r.statements(body.Statements)
+	r.scope = outer
+}
+
+func (r *resolver) call(call *ast.CallExpression) {
+	ident, _ := call.Function.(*ast.Identifier)
+	switch {
+	case ident != nil && ident.Value == "quote":
+		//Quoted code is not evaluated, only what is unquoted inside it
+		for _, arg := range call.Arguments {
+			ast.Inspect(arg, func(node ast.Node) bool {
+				unquote, ok := node.(*ast.CallExpression)
+				if !ok || !isCallOf(unquote, "unquote") {
+					return true
+				}
+				for _, arg := range unquote.Arguments {
+					r.expression(arg)
+				}
+				return false
+			})
+		}
+		return
+
+	case ident != nil && r.macros[ident.Value]:
+		r.expression(ident)
+		r.quiet++
+		for _, arg := range call.Arguments {
+			r.expression(arg)
+		}
+		r.quiet--
+		return
+	}
+
+	r.expression(call.Function)
+	for _, arg := range call.Arguments {
+		r.expression(arg)
+	}
+}
+
+func isCallOf(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// Links every use to its binding once all bindings are known
+func (r *resolver) resolveUses() {
+	for _, u := range r.uses {
+		if b := lookup(u); b != nil {
+			b.Uses = append(b.Uses, u.ident)
+			r.info.Uses[u.ident] = b
+			continue
+		}
+		if object.GetBuiltinByName(u.ident.Value) != nil {
+			r.info.Builtins = append(r.info.Builtins, u.ident)
+			continue
+		}
+		r.info.Unresolved = append(r.info.Unresolved, u.ident)
+		if u.quiet {
+			r.info.quiet[u.ident] = true
+		}
+	}
+}
+
+func lookup(u use) *Binding {
+	point := u.seq
+	for s := u.scope; s != nil; s = s.Parent {
+		var before, after *Binding
+		for _, b := range s.Bindings {
+			if b.Name != u.ident.Value {
+				continue
+			}
+			if b.seq < point {
+				before = b
+			} else if after == nil {
+				after = b
+			}
+		}
+		if before != nil {
+			return before
+		}
+		if s != u.scope && after != nil {
+			return after
+		}
+		point = s.open
+	}
+	return nil
+}
+
+func identPos(ident *ast.Identifier) Position {
+	return Position{ident.Token.Line, ident.Token.Column}
+}
@@ -0,0 +1,118 @@
+package analysis
+
+import (
+	"mscript/ast"
+	"mscript/lexer"
+	"mscript/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+// Resolves input and returns, for each use, the position of the binding it refers to
+func uses(t *testing.T, input string) map[Position]Position {
+	info := Resolve(parse(t, input))
+	resolved := map[Position]Position{}
+	for ident, b := range info.Uses {
+		resolved[identPos(ident)] = b.Pos
+	}
+	return resolved
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[Position]Position
+	}{
+		{"let x = 1; x", map[Position]Position{{1, 12}: {1, 5}}},
+		{"let x = 1; let x = x + 1; x", map[Position]Position{{1, 20}: {1, 5}, {1, 27}: {1, 16}}},
+		{"let f = fn(x) { x }; x", map[Position]Position{{1, 17}: {1, 12}}},
+		//A function may call a binding made after it
+		{"let f = fn() { g() }; let g = fn() { f() };", map[Position]Position{{1, 16}: {1, 27}, {1, 38}: {1, 5}}},
+		{"let f = fn(n) { f(n) };", map[Position]Position{{1, 17}: {1, 5}, {1, 19}: {1, 12}}},
+		//Blocks share the scope of their function
+		{"if (true) { let y = 1; } y", map[Position]Position{{1, 26}: {1, 17}}},
+		{"import \"m.ms\" as m; m.x", map[Position]Position{{1, 21}: {1, 18}}},
+		{"import \"m.ms\"; m.x", map[Position]Position{{1, 16}: {1, 1}}},
+	}
+
+	for _, tt := range tests {
+		got := uses(t, tt.input)
+		if len(got) != len(tt.expected) {
+			t.Errorf("%q: wrong number of resolved uses. got=%v, want=%v", tt.input, got, tt.expected)
+			continue
+		}
+		for use, def := range tt.expected {
+			if got[use] != def {
+				t.Errorf("%q: use at %v resolved to %v, want %v", tt.input, use, got[use], def)
+			}
+		}
+	}
+}
+
+func TestUnresolved(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"x; let x = 1;", []string{"x"}},
+		{"let x = x;", []string{"x"}},
+		{"puts(len([]))", nil},
+		{"fn(a) { b }", []string{"b"}},
+		{"let m = macro(a) { quote(unquote(a) + c) }; m(z)", []string{"z"}},
+		{"m.x", []string{"m"}},
+	}
+
+	for _, tt := range tests {
+		info := Resolve(parse(t, tt.input))
+		var got []string
+		for _, ident := range info.Unresolved {
+			got = append(got, ident.Value)
+		}
+		if len(got) != len(tt.expected) {
+			t.Errorf("%q: wrong unresolved names. got=%v, want=%v", tt.input, got, tt.expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("%q: wrong unresolved names. got=%v, want=%v", tt.input, got, tt.expected)
+			}
+		}
+	}
+}
+
+func TestAtAndScopes(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a + b\n};\nadd(1, 2)"
+	info := Resolve(parse(t, input))
+
+	ident, b := info.At(Position{4, 2})
+	if ident == nil || b == nil || b.Name != "add" || b.Kind != Let {
+		t.Fatalf("At(4:2) = %v, %v", ident, b)
+	}
+	if len(b.Uses) != 1 {
+		t.Errorf("add has %d uses, want 1", len(b.Uses))
+	}
+	if _, b := info.At(Position{1, 14}); b == nil || b.Kind != Parameter || b.Name != "a" {
+		t.Errorf("At(1:14) = %v, want the parameter a", b)
+	}
+
+	scope := info.ScopeAt(Position{2, 3})
+	var names []string
+	for _, b := range scope.Visible() {
+		names = append(names, b.Name)
+	}
+	if len(names) != 3 || names[0] != "b" || names[1] != "a" || names[2] != "add" {
+		t.Errorf("visible in the body: %v", names)
+	}
+	if scope := info.ScopeAt(Position{4, 1}); scope != info.Global {
+		t.Errorf("4:1 is not in the global scope")
+	}
+}
@@ -22,10 +22,11 @@ type Expression interface {
 }
 
 // Let statements have the token 'let', The identifer (name) and the expression (value)
-// let <identifier> = <expression>
+// let <identifier>[: <type>] = <expression>
 type LetStatement struct {
 	Token token.Token //Let token
 	Name  *Identifier
+	Type  *TypeAnnotation //nil when not annotated
 	Value Expression
 }
 
@@ -45,6 +46,11 @@ type IntegerLiteral struct {
 	Value int64
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
 // <prefix><expression>
 type PrefixExpression struct {
 	Token    token.Token
@@ -76,20 +82,26 @@ type IfExpression struct {
 }
 
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token //The '{' token
 	Statements []Statement
+	Rbrace     token.Token //The closing '}' token
 }
 
+// fn(<parameter>[: <type>], ...)[: <type>] <body>
 type FunctionLiteral struct {
-	Token      token.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token          token.Token
+	Parameters     []*Identifier
+	ParameterTypes []*TypeAnnotation //nil when no parameter is annotated, else one per parameter with nil for the unannotated ones
+	ReturnType     *TypeAnnotation   //nil when not annotated
+	Body           *BlockStatement
+	Rparen         token.Token //The ')' closing the parameters
 }
 
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Token //The closing ')' token
 }
 
 type StringLiteral struct {
@@ -114,6 +126,9 @@ func (ls *LetStatement) String() string {
 
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -191,6 +206,16 @@ func (il *IntegerLiteral) TokenLiteral() string {
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+// Return token literal for float
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
 func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
@@ -273,14 +298,22 @@ func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
 
-	for _, p := range fl.Parameters {
+	for i, p := range fl.Parameters {
+		if t := fl.ParameterType(i); t != nil {
+			params = append(params, p.String()+": "+t.String())
+			continue
+		}
 		params = append(params, p.String())
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") ")
+	out.WriteString(")")
+	if fl.ReturnType != nil {
+		out.WriteString(": " + fl.ReturnType.String())
+	}
+	out.WriteString(" ")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -328,3 +361,211 @@ func (sl *StringLiteral) TokenLiteral() string {
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
+
+// import "<path>" as <identifier>
+type ImportStatement struct {
+	Token token.Token //The 'import' token
+	Path  string
+	Name  *Identifier
+}
+
+func (is *ImportStatement) statementNode() {}
+func (is *ImportStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(is.TokenLiteral() + " ")
+	out.WriteString("\"" + is.Path + "\"")
+	if is.Name != nil {
+		out.WriteString(" as " + is.Name.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// export <let statement>
+type ExportStatement struct {
+	Token     token.Token //The 'export' token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode() {}
+func (es *ExportStatement) TokenLiteral() string {
+	return es.Token.Literal
+}
+
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// <expression>.<identifier>
+type MemberExpression struct {
+	Token    token.Token //The '.' token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MemberExpression) String() string {
+	return me.Object.String() + "." + me.Property.String()
+}
+
+// [<expression>, <expression>, ...]
+type ArrayLiteral struct {
+	Token    token.Token //The '[' token
+	Elements []Expression
+	Rbracket token.Token //The closing ']' token
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) TokenLiteral() string {
+	return al.Token.Literal
+}
+
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// {<expression>: <expression>, ...}
+// Keys keeps the order the pairs were written in
+type HashLiteral struct {
+	Token  token.Token //The '{' token
+	Keys   []Expression
+	Pairs  map[Expression]Expression
+	Rbrace token.Token //The closing '}' token
+}
+
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
+}
+
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+": "+hl.Pairs[key].String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// <expression>[<expression>]
+type IndexExpression struct {
+	Token    token.Token //The '[' token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token //The closing ']' token
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// macro(<parameters>) <body>
+type MacroLiteral struct {
+	Token      token.Token //The 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+	Rparen     token.Token //The ')' closing the parameters
+}
+
+func (ml *MacroLiteral) expressionNode() {}
+func (ml *MacroLiteral) TokenLiteral() string {
+	return ml.Token.Literal
+}
+
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+	params := []string{}
+
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
+// Returns the annotation of the i-th parameter, nil when it has none
+func (fl *FunctionLiteral) ParameterType(i int) *TypeAnnotation {
+	if i < len(fl.ParameterTypes) {
+		return fl.ParameterTypes[i]
+	}
+	return nil
+}
+
+// A type written after a let name, a parameter or a parameter list
+// <name> | [<type>] | {<type>: <type>} | fn | fn(<type>, ...)[: <type>]
+type TypeAnnotation struct {
+	Token    token.Token       //The name, '[', '{' or 'fn' token
+	Name     string            //The name as written, "array" for [...], "hash" for {...} and "fn" for functions
+	Elements []*TypeAnnotation //The element of an array, the key and value of a hash or the parameters of fn, nil for a bare fn
+	Result   *TypeAnnotation   //The result of fn, nil when not written
+}
+
+func (ta *TypeAnnotation) TokenLiteral() string {
+	return ta.Token.Literal
+}
+
+func (ta *TypeAnnotation) String() string {
+	switch {
+	case ta.Name == "array" && len(ta.Elements) == 1:
+		return "[" + ta.Elements[0].String() + "]"
+	case ta.Name == "hash" && len(ta.Elements) == 2:
+		return "{" + ta.Elements[0].String() + ": " + ta.Elements[1].String() + "}"
+	case ta.Name == "fn" && ta.Elements != nil:
+		params := []string{}
+		for _, e := range ta.Elements {
+			params = append(params, e.String())
+		}
+		out := "fn(" + strings.Join(params, ", ") + ")"
+		if ta.Result != nil {
+			out += ": " + ta.Result.String()
+		}
+		return out
+	}
+	return ta.Name
+}
@@ -0,0 +1,209 @@
+package ast
+
+// Returns a deep copy of node, nil stays nil
+func Copy(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		return &Program{Statements: copyStatements(n.Statements)}
+
+	case *LetStatement:
+		c := *n
+		c.Name = copyIdentifier(n.Name)
+		c.Type = copyType(n.Type)
+		c.Value = copyExpression(n.Value)
+		return &c
+
+	case *ReturnStatement:
+		c := *n
+		c.ReturnValue = copyExpression(n.ReturnValue)
+		return &c
+
+	case *ExpressionStatement:
+		c := *n
+		c.Expression = copyExpression(n.Expression)
+		return &c
+
+	case *BlockStatement:
+		c := *n
+		c.Statements = copyStatements(n.Statements)
+		return &c
+
+	case *ImportStatement:
+		c := *n
+		c.Name = copyIdentifier(n.Name)
+		return &c
+
+	case *ExportStatement:
+		c := *n
+		if n.Statement != nil {
+			c.Statement = Copy(n.Statement).(*LetStatement)
+		}
+		return &c
+
+	case *Identifier:
+		c := *n
+		return &c
+
+	case *IntegerLiteral:
+		c := *n
+		return &c
+
+	case *FloatLiteral:
+		c := *n
+		return &c
+
+	case *Boolean:
+		c := *n
+		return &c
+
+	case *StringLiteral:
+		c := *n
+		return &c
+
+	case *PrefixExpression:
+		c := *n
+		c.Right = copyExpression(n.Right)
+		return &c
+
+	case *InfixExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Right = copyExpression(n.Right)
+		return &c
+
+	case *IfExpression:
+		c := *n
+		c.Condition = copyExpression(n.Condition)
+		c.Consequence = copyBlock(n.Consequence)
+		c.Alternative = copyBlock(n.Alternative)
+		return &c
+
+	case *FunctionLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.ParameterTypes = copyTypes(n.ParameterTypes)
+		c.ReturnType = copyType(n.ReturnType)
+		c.Body = copyBlock(n.Body)
+		return &c
+
+	case *MacroLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = copyBlock(n.Body)
+		return &c
+
+	case *CallExpression:
+		c := *n
+		c.Function = copyExpression(n.Function)
+		c.Arguments = copyExpressions(n.Arguments)
+		return &c
+
+	case *ArrayLiteral:
+		c := *n
+		c.Elements = copyExpressions(n.Elements)
+		return &c
+
+	case *HashLiteral:
+		c := *n
+		c.Keys = make([]Expression, len(n.Keys))
+		c.Pairs = make(map[Expression]Expression, len(n.Pairs))
+		for i, key := range n.Keys {
+			c.Keys[i] = copyExpression(key)
+			c.Pairs[c.Keys[i]] = copyExpression(n.Pairs[key])
+		}
+		return &c
+
+	case *IndexExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Index = copyExpression(n.Index)
+		return &c
+
+	case *MemberExpression:
+		c := *n
+		c.Object = copyExpression(n.Object)
+		c.Property = copyIdentifier(n.Property)
+		return &c
+
+	case *TypeAnnotation:
+		c := *n
+		c.Elements = copyTypes(n.Elements)
+		c.Result = copyType(n.Result)
+		return &c
+	}
+
+	return node
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	c := make([]Statement, len(stmts))
+	for i, s := range stmts {
+		c[i], _ = Copy(s).(Statement)
+	}
+	return c
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	c := make([]Expression, len(exps))
+	for i, e := range exps {
+		c[i] = copyExpression(e)
+	}
+	return c
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	c := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		c[i] = copyIdentifier(ident)
+	}
+	return c
+}
+
+func copyExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	c, _ := Copy(exp).(Expression)
+	return c
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return Copy(block).(*BlockStatement)
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return Copy(ident).(*Identifier)
+}
+
+func copyTypes(types []*TypeAnnotation) []*TypeAnnotation {
+	if types == nil {
+		return nil
+	}
+	c := make([]*TypeAnnotation, len(types))
+	for i, t := range types {
+		c[i] = copyType(t)
+	}
+	return c
+}
+
+func copyType(t *TypeAnnotation) *TypeAnnotation {
+	if t == nil {
+		return nil
+	}
+	return Copy(t).(*TypeAnnotation)
+}
@@ -0,0 +1,46 @@
+package ast_test
+
+import (
+	"mscript/ast"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	program := parse(t, everyNode)
+	before := program.String()
+
+	copied := ast.Copy(program)
+	if copied.String() != before {
+		t.Fatalf("copy differs. got=%q, want=%q", copied.String(), before)
+	}
+
+	// Changing every node of the copy must leave the original alone
+	ast.Inspect(copied, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			n.Value = "changed"
+		case *ast.IntegerLiteral:
+			n.Value = 99
+		}
+		return true
+	})
+	ast.Modify(copied, func(n ast.Node) ast.Node { return n })
+
+	if program.String() != before {
+		t.Errorf("original changed. got=%q, want=%q", program.String(), before)
+	}
+
+	seen := map[ast.Node]bool{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			seen[n] = true
+		}
+		return true
+	})
+	ast.Inspect(copied, func(n ast.Node) bool {
+		if n != nil && seen[n] {
+			t.Errorf("node %T shared between copy and original", n)
+		}
+		return true
+	})
+}
@@ -0,0 +1,133 @@
+package ast
+
+// Called by Modify for every node, returns the node to put in its place
+type ModifierFunc func(Node) Node
+
+// Rewrites the ast bottom up: the children of node are modified first, then node itself
+// Replacements must fit where the node was, a statement for a statement, a block for a
+// block and an identifier for an identifier in a binding position. Replacements that
+// do not fit are ignored and the original node is kept.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = modifyStatements(n.Statements, modifier)
+
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		if n.Value != nil {
+			n.Value = modifyExpression(n.Value, modifier)
+		}
+
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			n.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+		}
+
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			n.Expression = modifyExpression(n.Expression, modifier)
+		}
+
+	case *BlockStatement:
+		n.Statements = modifyStatements(n.Statements, modifier)
+
+	case *ImportStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+
+	case *ExportStatement:
+		if let, ok := Modify(n.Statement, modifier).(*LetStatement); ok {
+			n.Statement = let
+		}
+
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		if n.Alternative != nil {
+			n.Alternative = modifyBlock(n.Alternative, modifier)
+		}
+
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+
+	case *MacroLiteral:
+		for i, p := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		n.Arguments = modifyExpressions(n.Arguments, modifier)
+
+	case *ArrayLiteral:
+		n.Elements = modifyExpressions(n.Elements, modifier)
+
+	case *HashLiteral:
+		keys := make([]Expression, len(n.Keys))
+		pairs := make(map[Expression]Expression, len(n.Pairs))
+		for i, key := range n.Keys {
+			newKey := modifyExpression(key, modifier)
+			keys[i] = newKey
+			pairs[newKey] = modifyExpression(n.Pairs[key], modifier)
+		}
+		n.Keys = keys
+		n.Pairs = pairs
+
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+
+	case *MemberExpression:
+		n.Object = modifyExpression(n.Object, modifier)
+		n.Property = modifyIdentifier(n.Property, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) []Statement {
+	for i, s := range stmts {
+		if modified, ok := Modify(s, modifier).(Statement); ok {
+			stmts[i] = modified
+		}
+	}
+	return stmts
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) []Expression {
+	for i, e := range exps {
+		exps[i] = modifyExpression(e, modifier)
+	}
+	return exps
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if modified, ok := Modify(exp, modifier).(Expression); ok {
+		return modified
+	}
+	return exp
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if modified, ok := Modify(block, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return block
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if modified, ok := Modify(ident, modifier).(*Identifier); ok {
+		return modified
+	}
+	return ident
+}
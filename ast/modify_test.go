@@ -0,0 +1,107 @@
+package ast_test
+
+import (
+	"mscript/ast"
+	"mscript/token"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	turnOneIntoTwo := func(node ast.Node) ast.Node {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		integer.Token.Literal = "2"
+		return integer
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1", "2"},
+		{"1 + 2", "(2 + 2)"},
+		{"-1", "(-2)"},
+		{"let a = 1;", "let a = 2;"},
+		{"fn() { return 1; }", "fn() return 2;"},
+		{"if (1) { 1 } else { 1 }", "if2 2else 2"},
+		{"f(1, 1)", "f(2, 2)"},
+		{"[1, 1]", "[2, 2]"},
+		{"{1: 1}", "{2: 2}"},
+		{"a[1]", "(a[2])"},
+		{"(1)[1].x", "(2[2]).x"},
+		{"export let a = 1;", "export let a = 2;"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		modified := ast.Modify(program, turnOneIntoTwo)
+		if modified.String() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, modified.String(), tt.expected)
+		}
+	}
+}
+
+func TestModifyHashKeys(t *testing.T) {
+	program := parse(t, `{"a": 1, "b": 2}`)
+
+	upper := func(node ast.Node) ast.Node {
+		str, ok := node.(*ast.StringLiteral)
+		if !ok {
+			return node
+		}
+		return &ast.StringLiteral{Token: str.Token, Value: str.Value + str.Value}
+	}
+	ast.Modify(program, upper)
+
+	hash := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	if len(hash.Pairs) != 2 || len(hash.Keys) != 2 {
+		t.Fatalf("wrong number of pairs. got=%d keys=%d", len(hash.Pairs), len(hash.Keys))
+	}
+	for i, want := range []string{"aa", "bb"} {
+		key := hash.Keys[i].(*ast.StringLiteral)
+		if key.Value != want {
+			t.Errorf("wrong key %d. got=%q, want=%q", i, key.Value, want)
+		}
+		if _, ok := hash.Pairs[key]; !ok {
+			t.Errorf("key %q missing from Pairs", key.Value)
+		}
+	}
+}
+
+func TestModifyRenamesBindings(t *testing.T) {
+	program := parse(t, `import "m.ms" as m; let x = fn(x) { x + m.x };`)
+
+	rename := func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: ident.Value + "_"}, Value: ident.Value + "_"}
+	}
+	ast.Modify(program, rename)
+
+	expected := `import "m.ms" as m_;let x_ = fn(x_) (x_ + m_.x_);`
+	if program.String() != expected {
+		t.Errorf("wrong result. got=%q, want=%q", program.String(), expected)
+	}
+}
+
+func TestModifyKeepsMisfitReplacements(t *testing.T) {
+	program := parse(t, "let a = 1;")
+
+	// An expression cannot replace the binding name of a let statement
+	toInteger := func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.Identifier); ok {
+			return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "0"}, Value: 0}
+		}
+		return node
+	}
+	ast.Modify(program, toInteger)
+
+	if program.String() != "let a = 1;" {
+		t.Errorf("wrong result. got=%q", program.String())
+	}
+}
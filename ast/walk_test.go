@@ -0,0 +1,133 @@
+package ast_test
+
+import (
+	"fmt"
+	"mscript/ast"
+	"mscript/lexer"
+	"mscript/parser"
+	"testing"
+)
+
+// Contains every node type at least once
+const everyNode = `
+import "lib/util.ms" as u;
+export let add = fn(a, b) { return a + b; };
+let xs = [1, -2, "three", true];
+let h = {"k": xs[0]};
+let m = macro(q) { q };
+if (u.ok(h["k"])) { add(1, 2) } else { false };
+`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestInspectVisitsEveryNodeType(t *testing.T) {
+	program := parse(t, everyNode)
+
+	seen := map[string]int{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			seen[fmt.Sprintf("%T", n)]++
+		}
+		return true
+	})
+
+	expected := map[string]int{
+		"*ast.Program":             1,
+		"*ast.ImportStatement":     1,
+		"*ast.ExportStatement":     1,
+		"*ast.LetStatement":        4,
+		"*ast.ReturnStatement":     1,
+		"*ast.ExpressionStatement": 4,
+		"*ast.BlockStatement":      4,
+		"*ast.FunctionLiteral":     1,
+		"*ast.MacroLiteral":        1,
+		"*ast.InfixExpression":     1,
+		"*ast.PrefixExpression":    1,
+		"*ast.ArrayLiteral":        1,
+		"*ast.HashLiteral":         1,
+		"*ast.IndexExpression":     2,
+		"*ast.IfExpression":        1,
+		"*ast.CallExpression":      2,
+		"*ast.MemberExpression":    1,
+		"*ast.IntegerLiteral":      5,
+		"*ast.StringLiteral":       3,
+		"*ast.Boolean":             2,
+		// u, add, a, b, a, b, xs, h, xs, m, q, q, u, ok, h, add
+		"*ast.Identifier": 16,
+	}
+	for typ, count := range expected {
+		if seen[typ] != count {
+			t.Errorf("wrong number of %s visited. got=%d, want=%d", typ, seen[typ], count)
+		}
+	}
+	for typ := range seen {
+		if _, ok := expected[typ]; !ok {
+			t.Errorf("unexpected node visited: %s", typ)
+		}
+	}
+}
+
+func TestInspectOrderAndPruning(t *testing.T) {
+	program := parse(t, "f(1 + 2, fn(x) { x * 3 });")
+
+	var visited []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if _, ok := n.(*ast.FunctionLiteral); ok {
+			visited = append(visited, "fn")
+			return false
+		}
+		if _, ok := n.(*ast.InfixExpression); ok {
+			visited = append(visited, n.TokenLiteral())
+		}
+		if ident, ok := n.(*ast.Identifier); ok {
+			visited = append(visited, ident.Value)
+		}
+		return true
+	})
+
+	expected := []string{"f", "+", "fn"}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Errorf("wrong visiting order. got=%v, want=%v", visited, expected)
+	}
+}
+
+type depthVisitor struct {
+	depth    *int
+	maxDepth *int
+}
+
+func (v depthVisitor) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		*v.depth--
+		return nil
+	}
+	*v.depth++
+	*v.maxDepth = max(*v.maxDepth, *v.depth)
+	return v
+}
+
+func TestWalkCallsVisitNilAfterChildren(t *testing.T) {
+	program := parse(t, "let x = -(1 + 2);")
+
+	depth, maxDepth := 0, 0
+	ast.Walk(depthVisitor{&depth, &maxDepth}, program)
+
+	if depth != 0 {
+		t.Errorf("Visit(nil) not called once per visited node. depth=%d", depth)
+	}
+	// program, let, prefix, infix, integer
+	if maxDepth != 5 {
+		t.Errorf("wrong max depth. got=%d, want=5", maxDepth)
+	}
+}
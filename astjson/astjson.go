@@ -0,0 +1,681 @@
+// Package astjson converts parse trees to and from JSON so tools outside Go
+// can consume them without reimplementing the parser.
+//
+// Every node is an object whose "kind" is the name of its ast type and whose
+// "pos" holds the 1-based line and column of the node's token, followed by one
+// member per field of the node. Missing nodes are null. Block statements also
+// carry the position of their closing brace in "end". Type annotations are only
+// present when written, so unannotated programs encode as they always have.
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mscript/ast"
+	"mscript/token"
+	"strconv"
+	"strings"
+)
+
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Returns the JSON encoding of node
+func Marshal(node ast.Node) ([]byte, error) {
+	return json.Marshal(encode(node))
+}
+
+// Like Marshal but indents the output like json.MarshalIndent
+func MarshalIndent(node ast.Node, prefix, indent string) ([]byte, error) {
+	return json.MarshalIndent(encode(node), prefix, indent)
+}
+
+// Rebuilds the node encoded in data
+// Tokens are reconstructed from the node kinds, fields and positions
+func Unmarshal(data []byte) (ast.Node, error) {
+	return decode(json.RawMessage(data))
+}
+
+// Like Unmarshal but requires data to hold a Program
+func UnmarshalProgram(data []byte) (*ast.Program, error) {
+	node, err := Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	program, ok := node.(*ast.Program)
+	if !ok {
+		return nil, fmt.Errorf("astjson: expected Program, got %s", kindOf(node))
+	}
+	return program, nil
+}
+
+// A JSON object that keeps its members in order, so "kind" always comes first
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func kindOf(node ast.Node) string {
+	if node == nil {
+		return "null"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+func positionOf(t token.Token) Position {
+	return Position{Line: t.Line, Column: t.Column}
+}
+
+func encode(node ast.Node) any {
+	if isNil(node) {
+		return nil
+	}
+
+	var pos token.Token
+	var members []member
+	switch n := node.(type) {
+	case *ast.Program:
+		return object{{"kind", "Program"}, {"statements", encodeStatements(n.Statements)}}
+
+	case *ast.LetStatement:
+		pos = n.Token
+		members = []member{{"name", encode(n.Name)}}
+		if n.Type != nil {
+			members = append(members, member{"type", encode(n.Type)})
+		}
+		members = append(members, member{"value", encode(n.Value)})
+
+	case *ast.ReturnStatement:
+		pos = n.Token
+		members = []member{{"returnValue", encode(n.ReturnValue)}}
+
+	case *ast.ExpressionStatement:
+		pos = n.Token
+		members = []member{{"expression", encode(n.Expression)}}
+
+	case *ast.BlockStatement:
+		pos = n.Token
+		members = []member{{"statements", encodeStatements(n.Statements)}, {"end", positionOf(n.Rbrace)}}
+
+	case *ast.ImportStatement:
+		pos = n.Token
+		members = []member{{"path", n.Path}, {"name", encode(n.Name)}}
+
+	case *ast.ExportStatement:
+		pos = n.Token
+		members = []member{{"statement", encode(n.Statement)}}
+
+	case *ast.Identifier:
+		pos = n.Token
+		members = []member{{"value", n.Value}}
+
+	case *ast.IntegerLiteral:
+		pos = n.Token
+		members = []member{{"value", n.Value}}
+
+	case *ast.FloatLiteral:
+		pos = n.Token
+		members = []member{{"value", n.Value}}
+
+	case *ast.StringLiteral:
+		pos = n.Token
+		members = []member{{"value", n.Value}}
+
+	case *ast.Boolean:
+		pos = n.Token
+		members = []member{{"value", n.Value}}
+
+	case *ast.PrefixExpression:
+		pos = n.Token
+		members = []member{{"operator", n.Operator}, {"right", encode(n.Right)}}
+
+	case *ast.InfixExpression:
+		pos = n.Token
+		members = []member{{"operator", n.Operator}, {"left", encode(n.Left)}, {"right", encode(n.Right)}}
+
+	case *ast.IfExpression:
+		pos = n.Token
+		members = []member{
+			{"condition", encode(n.Condition)},
+			{"consequence", encode(n.Consequence)},
+			{"alternative", encode(n.Alternative)},
+		}
+
+	case *ast.FunctionLiteral:
+		pos = n.Token
+		members = []member{{"parameters", encodeIdentifiers(n.Parameters)}}
+		if n.ParameterTypes != nil {
+			members = append(members, member{"parameterTypes", encodeTypes(n.ParameterTypes)})
+		}
+		if n.ReturnType != nil {
+			members = append(members, member{"returnType", encode(n.ReturnType)})
+		}
+		members = append(members, member{"body", encode(n.Body)})
+
+	case *ast.MacroLiteral:
+		pos = n.Token
+		members = []member{{"parameters", encodeIdentifiers(n.Parameters)}, {"body", encode(n.Body)}}
+
+	case *ast.CallExpression:
+		pos = n.Token
+		members = []member{{"function", encode(n.Function)}, {"arguments", encodeExpressions(n.Arguments)}}
+
+	case *ast.ArrayLiteral:
+		pos = n.Token
+		members = []member{{"elements", encodeExpressions(n.Elements)}}
+
+	case *ast.HashLiteral:
+		pairs := make([]any, len(n.Keys))
+		for i, key := range n.Keys {
+			pairs[i] = object{{"key", encode(key)}, {"value", encode(n.Pairs[key])}}
+		}
+		pos = n.Token
+		members = []member{{"pairs", pairs}}
+
+	case *ast.IndexExpression:
+		pos = n.Token
+		members = []member{{"left", encode(n.Left)}, {"index", encode(n.Index)}}
+
+	case *ast.MemberExpression:
+		pos = n.Token
+		members = []member{{"object", encode(n.Object)}, {"property", encode(n.Property)}}
+
+	case *ast.TypeAnnotation:
+		pos = n.Token
+		members = []member{{"name", n.Name}}
+		if n.Elements != nil {
+			members = append(members, member{"elements", encodeTypes(n.Elements)})
+		}
+		if n.Result != nil {
+			members = append(members, member{"result", encode(n.Result)})
+		}
+
+	default:
+		return object{{"kind", kindOf(node)}}
+	}
+
+	return append(object{{"kind", kindOf(node)}, {"pos", positionOf(pos)}}, members...)
+}
+
+// Nodes are stored in interfaces, a typed nil must encode as null too
+func isNil(node ast.Node) bool {
+	if node == nil {
+		return true
+	}
+	switch n := node.(type) {
+	case *ast.Identifier:
+		return n == nil
+	case *ast.BlockStatement:
+		return n == nil
+	case *ast.LetStatement:
+		return n == nil
+	case *ast.TypeAnnotation:
+		return n == nil
+	}
+	return false
+}
+
+func encodeStatements(stmts []ast.Statement) []any {
+	list := make([]any, len(stmts))
+	for i, s := range stmts {
+		list[i] = encode(s)
+	}
+	return list
+}
+
+func encodeExpressions(exps []ast.Expression) []any {
+	list := make([]any, len(exps))
+	for i, e := range exps {
+		list[i] = encode(e)
+	}
+	return list
+}
+
+func encodeIdentifiers(idents []*ast.Identifier) []any {
+	list := make([]any, len(idents))
+	for i, ident := range idents {
+		list[i] = encode(ident)
+	}
+	return list
+}
+
+func encodeTypes(types []*ast.TypeAnnotation) []any {
+	list := make([]any, len(types))
+	for i, t := range types {
+		list[i] = encode(t)
+	}
+	return list
+}
+
+// The members of one encoded node, the first error sticks and later reads return zero values
+type fields struct {
+	kind    string
+	members map[string]json.RawMessage
+	err     error
+}
+
+func (f *fields) fail(format string, args ...any) {
+	if f.err == nil {
+		f.err = fmt.Errorf("astjson: %s: "+format, append([]any{f.kind}, args...)...)
+	}
+}
+
+func (f *fields) value(key string, v any) {
+	if f.err != nil {
+		return
+	}
+	raw, ok := f.members[key]
+	if !ok {
+		f.fail("missing %q", key)
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		f.fail("%q: %s", key, err)
+	}
+}
+
+func (f *fields) string(key string) string {
+	var s string
+	f.value(key, &s)
+	return s
+}
+
+func (f *fields) position(key string) Position {
+	var p Position
+	f.value(key, &p)
+	return p
+}
+
+func (f *fields) token(tokenType token.TokenType, literal string) token.Token {
+	p := f.position("pos")
+	return token.Token{Type: tokenType, Literal: literal, Line: p.Line, Column: p.Column}
+}
+
+func (f *fields) node(key string) ast.Node {
+	if f.err != nil {
+		return nil
+	}
+	raw, ok := f.members[key]
+	if !ok {
+		f.fail("missing %q", key)
+		return nil
+	}
+	node, err := decode(raw)
+	if err != nil {
+		f.err = err
+	}
+	return node
+}
+
+func (f *fields) nodes(key string) []ast.Node {
+	var list []json.RawMessage
+	f.value(key, &list)
+	nodes := make([]ast.Node, 0, len(list))
+	for _, raw := range list {
+		if f.err != nil {
+			return nil
+		}
+		node, err := decode(raw)
+		if err != nil {
+			f.err = err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (f *fields) expression(key string) ast.Expression {
+	return f.asExpression(key, f.node(key))
+}
+
+func (f *fields) asExpression(key string, node ast.Node) ast.Expression {
+	if node == nil {
+		return nil
+	}
+	exp, ok := node.(ast.Expression)
+	if !ok {
+		f.fail("%q: expected an expression, got %s", key, kindOf(node))
+	}
+	return exp
+}
+
+func (f *fields) expressions(key string) []ast.Expression {
+	exps := []ast.Expression{}
+	for _, node := range f.nodes(key) {
+		exps = append(exps, f.asExpression(key, node))
+	}
+	return exps
+}
+
+func (f *fields) statements(key string) []ast.Statement {
+	stmts := []ast.Statement{}
+	for _, node := range f.nodes(key) {
+		stmt, ok := node.(ast.Statement)
+		if !ok {
+			f.fail("%q: expected a statement, got %s", key, kindOf(node))
+			return nil
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func (f *fields) identifier(key string) *ast.Identifier {
+	return f.asIdentifier(key, f.node(key))
+}
+
+func (f *fields) asIdentifier(key string, node ast.Node) *ast.Identifier {
+	if node == nil {
+		return nil
+	}
+	ident, ok := node.(*ast.Identifier)
+	if !ok {
+		f.fail("%q: expected Identifier, got %s", key, kindOf(node))
+	}
+	return ident
+}
+
+func (f *fields) identifiers(key string) []*ast.Identifier {
+	idents := []*ast.Identifier{}
+	for _, node := range f.nodes(key) {
+		idents = append(idents, f.asIdentifier(key, node))
+	}
+	return idents
+}
+
+func (f *fields) block(key string) *ast.BlockStatement {
+	node := f.node(key)
+	if node == nil {
+		return nil
+	}
+	block, ok := node.(*ast.BlockStatement)
+	if !ok {
+		f.fail("%q: expected BlockStatement, got %s", key, kindOf(node))
+	}
+	return block
+}
+
+func (f *fields) has(key string) bool {
+	_, ok := f.members[key]
+	return ok
+}
+
+// Reads an optional type annotation, nil when key is missing
+func (f *fields) typeAnnotation(key string) *ast.TypeAnnotation {
+	if !f.has(key) {
+		return nil
+	}
+	return f.asTypeAnnotation(key, f.node(key))
+}
+
+func (f *fields) asTypeAnnotation(key string, node ast.Node) *ast.TypeAnnotation {
+	if node == nil {
+		return nil
+	}
+	t, ok := node.(*ast.TypeAnnotation)
+	if !ok {
+		f.fail("%q: expected TypeAnnotation, got %s", key, kindOf(node))
+	}
+	return t
+}
+
+// Reads an optional list of type annotations, nil when key is missing
+func (f *fields) typeAnnotations(key string) []*ast.TypeAnnotation {
+	if !f.has(key) {
+		return nil
+	}
+	types := []*ast.TypeAnnotation{}
+	for _, node := range f.nodes(key) {
+		types = append(types, f.asTypeAnnotation(key, node))
+	}
+	return types
+}
+
+func decode(raw json.RawMessage) (ast.Node, error) {
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+
+	f := &fields{}
+	if err := json.Unmarshal(raw, &f.members); err != nil {
+		return nil, fmt.Errorf("astjson: node is not an object")
+	}
+	if err := json.Unmarshal(f.members["kind"], &f.kind); err != nil {
+		return nil, fmt.Errorf("astjson: node without a kind")
+	}
+
+	var node ast.Node
+	switch f.kind {
+	case "Program":
+		node = &ast.Program{Statements: f.statements("statements")}
+
+	case "LetStatement":
+		let := &ast.LetStatement{Token: f.token(token.LET, "let")}
+		let.Name = f.identifier("name")
+		let.Type = f.typeAnnotation("type")
+		let.Value = f.expression("value")
+		node = let
+
+	case "ReturnStatement":
+		node = &ast.ReturnStatement{Token: f.token(token.RETURN, "return"), ReturnValue: f.expression("returnValue")}
+
+	case "ExpressionStatement":
+		exp := f.expression("expression")
+		first := firstToken(exp)
+		node = &ast.ExpressionStatement{Token: f.token(first.Type, first.Literal), Expression: exp}
+
+	case "BlockStatement":
+		end := f.position("end")
+		node = &ast.BlockStatement{
+			Token:      f.token(token.LBRACE, "{"),
+			Statements: f.statements("statements"),
+			Rbrace:     token.Token{Type: token.RBRACE, Literal: "}", Line: end.Line, Column: end.Column},
+		}
+
+	case "ImportStatement":
+		node = &ast.ImportStatement{Token: f.token(token.IMPORT, "import"), Path: f.string("path"), Name: f.identifier("name")}
+
+	case "ExportStatement":
+		export := &ast.ExportStatement{Token: f.token(token.EXPORT, "export")}
+		if stmt := f.node("statement"); stmt != nil {
+			let, ok := stmt.(*ast.LetStatement)
+			if !ok {
+				f.fail("%q: expected LetStatement, got %s", "statement", kindOf(stmt))
+			}
+			export.Statement = let
+		}
+		node = export
+
+	case "Identifier":
+		value := f.string("value")
+		node = &ast.Identifier{Token: f.token(token.IDENT, value), Value: value}
+
+	case "IntegerLiteral":
+		var value int64
+		f.value("value", &value)
+		node = &ast.IntegerLiteral{Token: f.token(token.INT, strconv.FormatInt(value, 10)), Value: value}
+
+	case "FloatLiteral":
+		var value float64
+		f.value("value", &value)
+		literal := strconv.FormatFloat(value, 'g', -1, 64)
+		//The literal must still read as a float
+		if !strings.ContainsAny(literal, ".e") {
+			literal += ".0"
+		}
+		node = &ast.FloatLiteral{Token: f.token(token.FLOAT, literal), Value: value}
+
+	case "StringLiteral":
+		value := f.string("value")
+		node = &ast.StringLiteral{Token: f.token(token.STRING, value), Value: value}
+
+	case "Boolean":
+		var value bool
+		f.value("value", &value)
+		t := f.token(token.FALSE, "false")
+		if value {
+			t.Type, t.Literal = token.TRUE, "true"
+		}
+		node = &ast.Boolean{Token: t, Value: value}
+
+	case "PrefixExpression":
+		operator := f.string("operator")
+		node = &ast.PrefixExpression{
+			Token:    f.token(token.TokenType(operator), operator),
+			Operator: operator,
+			Right:    f.expression("right"),
+		}
+
+	case "InfixExpression":
+		operator := f.string("operator")
+		node = &ast.InfixExpression{
+			Token:    f.token(token.TokenType(operator), operator),
+			Operator: operator,
+			Left:     f.expression("left"),
+			Right:    f.expression("right"),
+		}
+
+	case "IfExpression":
+		node = &ast.IfExpression{
+			Token:       f.token(token.IF, "if"),
+			Condition:   f.expression("condition"),
+			Consequence: f.block("consequence"),
+			Alternative: f.block("alternative"),
+		}
+
+	case "FunctionLiteral":
+		node = &ast.FunctionLiteral{
+			Token:          f.token(token.FUNCTION, "fn"),
+			Parameters:     f.identifiers("parameters"),
+			ParameterTypes: f.typeAnnotations("parameterTypes"),
+			ReturnType:     f.typeAnnotation("returnType"),
+			Body:           f.block("body"),
+		}
+
+	case "MacroLiteral":
+		node = &ast.MacroLiteral{
+			Token:      f.token(token.MACRO, "macro"),
+			Parameters: f.identifiers("parameters"),
+			Body:       f.block("body"),
+		}
+
+	case "CallExpression":
+		node = &ast.CallExpression{
+			Token:     f.token(token.LPAREN, "("),
+			Function:  f.expression("function"),
+			Arguments: f.expressions("arguments"),
+		}
+
+	case "ArrayLiteral":
+		node = &ast.ArrayLiteral{Token: f.token(token.LBRACKET, "["), Elements: f.expressions("elements")}
+
+	case "HashLiteral":
+		hash := &ast.HashLiteral{Token: f.token(token.LBRACE, "{"), Keys: []ast.Expression{}, Pairs: map[ast.Expression]ast.Expression{}}
+		var pairs []json.RawMessage
+		f.value("pairs", &pairs)
+		for _, raw := range pairs {
+			pair := &fields{kind: "HashLiteral pair"}
+			if err := json.Unmarshal(raw, &pair.members); err != nil {
+				return nil, fmt.Errorf("astjson: hash pair is not an object")
+			}
+			key, value := pair.expression("key"), pair.expression("value")
+			if pair.err != nil {
+				return nil, pair.err
+			}
+			hash.Keys = append(hash.Keys, key)
+			hash.Pairs[key] = value
+		}
+		node = hash
+
+	case "IndexExpression":
+		node = &ast.IndexExpression{Token: f.token(token.LBRACKET, "["), Left: f.expression("left"), Index: f.expression("index")}
+
+	case "MemberExpression":
+		node = &ast.MemberExpression{Token: f.token(token.DOT, "."), Object: f.expression("object"), Property: f.identifier("property")}
+
+	case "TypeAnnotation":
+		name := f.string("name")
+		t := &ast.TypeAnnotation{Name: name, Elements: f.typeAnnotations("elements"), Result: f.typeAnnotation("result")}
+		switch {
+		case name == "array" && t.Elements != nil:
+			t.Token = f.token(token.LBRACKET, "[")
+		case name == "hash" && t.Elements != nil:
+			t.Token = f.token(token.LBRACE, "{")
+		case name == "fn":
+			t.Token = f.token(token.FUNCTION, "fn")
+		default:
+			t.Token = f.token(token.IDENT, name)
+		}
+		node = t
+
+	default:
+		return nil, fmt.Errorf("astjson: unknown node kind %q", f.kind)
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	return node, nil
+}
+
+// The token an expression statement starts with, which is the leftmost token of its expression
+func firstToken(exp ast.Expression) token.Token {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return firstToken(exp.Left)
+	case *ast.CallExpression:
+		return firstToken(exp.Function)
+	case *ast.IndexExpression:
+		return firstToken(exp.Left)
+	case *ast.MemberExpression:
+		return firstToken(exp.Object)
+	case *ast.Identifier:
+		return exp.Token
+	case *ast.IntegerLiteral:
+		return exp.Token
+	case *ast.FloatLiteral:
+		return exp.Token
+	case *ast.StringLiteral:
+		return exp.Token
+	case *ast.Boolean:
+		return exp.Token
+	case *ast.PrefixExpression:
+		return exp.Token
+	case *ast.IfExpression:
+		return exp.Token
+	case *ast.FunctionLiteral:
+		return exp.Token
+	case *ast.MacroLiteral:
+		return exp.Token
+	case *ast.ArrayLiteral:
+		return exp.Token
+	case *ast.HashLiteral:
+		return exp.Token
+	}
+	return token.Token{}
+}
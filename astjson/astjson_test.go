@@ -0,0 +1,113 @@
+package astjson
+
+import (
+	"mscript/lexer"
+	"mscript/parser"
+	"strings"
+	"testing"
+)
+
+const everyNode = `import "lib/util.ms" as u;
+export let add = fn(a, b) { return a + b; };
+let xs = [1, -2, "three", true, 2.5];
+let h = {"k": xs[0], 2: false};
+let m = macro(q) { q };
+if (u.ok(h["k"])) { add(1, 2) } else { (1 + 2) * 3 };
+let typed: {string: [int]} = fn(a: int, b, f: fn(int): fn): fn { f };
+`
+
+func parse(t *testing.T, input string) string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	data, err := Marshal(program)
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+	return string(data)
+}
+
+func TestMarshal(t *testing.T) {
+	got := parse(t, "let x = -a;\nf(x)")
+	expected := `{"kind":"Program","statements":[` +
+		`{"kind":"LetStatement","pos":{"line":1,"column":1},` +
+		`"name":{"kind":"Identifier","pos":{"line":1,"column":5},"value":"x"},` +
+		`"value":{"kind":"PrefixExpression","pos":{"line":1,"column":9},"operator":"-",` +
+		`"right":{"kind":"Identifier","pos":{"line":1,"column":10},"value":"a"}}},` +
+		`{"kind":"ExpressionStatement","pos":{"line":2,"column":1},` +
+		`"expression":{"kind":"CallExpression","pos":{"line":2,"column":2},` +
+		`"function":{"kind":"Identifier","pos":{"line":2,"column":1},"value":"f"},` +
+		`"arguments":[{"kind":"Identifier","pos":{"line":2,"column":3},"value":"x"}]}}]}`
+
+	if got != expected {
+		t.Errorf("wrong json.\ngot= %s\nwant=%s", got, expected)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := parse(t, everyNode)
+
+	program, err := UnmarshalProgram([]byte(data))
+	if err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+
+	p := parser.New(lexer.New(everyNode))
+	if program.String() != p.ParseProgram().String() {
+		t.Errorf("decoded program differs.\ngot= %s", program.String())
+	}
+
+	again, err := Marshal(program)
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+	if string(again) != data {
+		t.Errorf("re-encoded json differs.\ngot= %s\nwant=%s", again, data)
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	p := parser.New(lexer.New("1"))
+	data, err := MarshalIndent(p.ParseProgram(), "", "  ")
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+	if !strings.HasPrefix(string(data), "{\n  \"kind\": \"Program\",\n  \"statements\": [\n") {
+		t.Errorf("not indented. got=%s", data)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[]`, "astjson: node is not an object"},
+		{`{}`, "astjson: node without a kind"},
+		{`{"kind":"Loop"}`, `astjson: unknown node kind "Loop"`},
+		{`{"kind":"Program"}`, `astjson: Program: missing "statements"`},
+		{`{"kind":"Program","statements":[{"kind":"Identifier","pos":{"line":1,"column":1},"value":"x"}]}`,
+			`astjson: Program: "statements": expected a statement, got Identifier`},
+		{`{"kind":"LetStatement","pos":{"line":1,"column":1},"name":{"kind":"IntegerLiteral","pos":{"line":1,"column":5},"value":1},"value":null}`,
+			`astjson: LetStatement: "name": expected Identifier, got IntegerLiteral`},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal([]byte(tt.input))
+		if err == nil {
+			t.Errorf("expected error for %s", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error.\ngot= %s\nwant=%s", err, tt.expected)
+		}
+	}
+
+	if _, err := UnmarshalProgram([]byte(`{"kind":"Identifier","pos":{"line":1,"column":1},"value":"x"}`)); err == nil ||
+		err.Error() != "astjson: expected Program, got Identifier" {
+		t.Errorf("wrong error for non-program. got=%v", err)
+	}
+}
@@ -0,0 +1,514 @@
+// Package checker infers the types of a program and reports the operations
+// that would fail at runtime with a type error, like adding a string to an int.
+//
+// Typing is gradual: annotations are optional, unannotated parameters are any
+// and any is consistent with every type, so only code whose types are known
+// is checked. Lets without an annotation take the type of their value.
+package checker
+
+import (
+	"fmt"
+	"mscript/analysis"
+	"mscript/ast"
+	"mscript/object"
+	"mscript/token"
+	"sort"
+)
+
+// The type of every binding of a checked program
+type Info struct {
+	Types map[*analysis.Binding]Type
+}
+
+// Checks the types of program and returns the mismatches in source order
+func Check(program *ast.Program) []analysis.Diagnostic {
+	diagnostics, _ := Infer(program)
+	return diagnostics
+}
+
+// Like Check but also returns the types inferred for the bindings
+func Infer(program *ast.Program) ([]analysis.Diagnostic, *Info) {
+	resolved := analysis.Resolve(program)
+	c := &checker{
+		resolved:   resolved,
+		bindings:   map[*ast.Identifier]*analysis.Binding{},
+		signatures: map[*ast.FunctionLiteral]*Func{},
+		info:       &Info{Types: map[*analysis.Binding]Type{}},
+	}
+	for _, b := range resolved.Bindings {
+		c.bindings[b.Ident] = b
+	}
+
+	c.statements(program.Statements)
+
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		return c.diagnostics[i].Start.Before(c.diagnostics[j].Start)
+	})
+	return c.diagnostics, c.info
+}
+
+type checker struct {
+	resolved    *analysis.Info
+	bindings    map[*ast.Identifier]*analysis.Binding //By the identifier declaring them
+	signatures  map[*ast.FunctionLiteral]*Func        //Built from the annotations of each literal once
+	info        *Info
+	diagnostics []analysis.Diagnostic
+
+	//Of the function being checked, returns is nil at the top level and result is nil when not annotated
+	returns *[]Type
+	result  Type
+}
+
+func (c *checker) errorf(tok token.Token, format string, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, analysis.Diagnostic{
+		Start:    analysis.Position{Line: tok.Line, Column: tok.Column},
+		End:      analysis.Position{Line: tok.Line, Column: tok.Column + len(tok.Literal)},
+		Severity: analysis.Error,
+		Check:    "type",
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// Checks stmts and returns the type of the value they produce, nil when they always return
+func (c *checker) statements(stmts []ast.Statement) Type {
+	var result Type = Null
+	returned := false
+	for _, stmt := range stmts {
+		t := c.statement(stmt)
+		if !returned {
+			result = t
+			returned = t == nil
+		}
+	}
+	return result
+}
+
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(stmt)
+
+	case *ast.ExportStatement:
+		if stmt.Statement != nil {
+			c.let(stmt.Statement)
+		}
+
+	case *ast.ImportStatement:
+		if b := c.bindings[stmt.Name]; b != nil {
+			c.info.Types[b] = Module
+		}
+
+	case *ast.ReturnStatement:
+		var t Type
+		if c.returns != nil && c.result != nil {
+			t = c.against(stmt.ReturnValue, c.result, "return")
+		} else {
+			t = c.expression(stmt.ReturnValue)
+		}
+		if c.returns == nil {
+			return nil
+		}
+		*c.returns = append(*c.returns, t)
+		return nil
+
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+
+	case *ast.BlockStatement:
+		return c.statements(stmt.Statements)
+	}
+	return Null
+}
+
+func (c *checker) let(let *ast.LetStatement) {
+	b := c.bindings[let.Name]
+	if b == nil {
+		return
+	}
+
+	var declared Type
+	if let.Type != nil {
+		declared = c.annotation(let.Type)
+		c.info.Types[b] = declared
+	} else if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+		//Known before the body is checked so recursive calls are checked too
+		c.info.Types[b] = c.signature(fn)
+	}
+
+	if declared == nil {
+		c.info.Types[b] = c.expression(let.Value)
+		return
+	}
+	c.against(let.Value, declared, "let "+let.Name.Value)
+}
+
+// Checks that exp can be used where a value of type want is expected, in what
+// The elements of array and hash literals are checked one by one against the element type
+// wanted instead of being joined, which would give any for elements of different types
+func (c *checker) against(exp ast.Expression, want Type, what string) Type {
+	switch exp := exp.(type) {
+	case *ast.ArrayLiteral:
+		if want, ok := want.(*Array); ok {
+			for _, el := range exp.Elements {
+				c.against(el, want.Elem, what)
+			}
+			return want
+		}
+	case *ast.HashLiteral:
+		if want, ok := want.(*Hash); ok {
+			for _, k := range exp.Keys {
+				if kt := c.against(k, want.Key, what); kt != nil && !hashable(kt) {
+					c.errorf(start(k), "unusable as hash key: %s", objectType(kt))
+				}
+				c.against(exp.Pairs[k], want.Value, what)
+			}
+			return want
+		}
+	}
+
+	t := c.expression(exp)
+	if t != nil && !consistent(t, want) {
+		c.errorf(start(exp), "cannot use %s as %s in %s", t, want, what)
+	}
+	return t
+}
+
+func (c *checker) expression(exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+
+	case *ast.FloatLiteral:
+		return Float
+
+	case *ast.StringLiteral:
+		return String
+
+	case *ast.Boolean:
+		return Bool
+
+	case *ast.Identifier:
+		if b := c.resolved.Uses[exp]; b != nil {
+			if t, ok := c.info.Types[b]; ok {
+				return t
+			}
+			//Declared later, a function may run after the binding is made
+			return Any
+		}
+		if t, ok := builtinTypes[exp.Value]; ok && object.GetBuiltinByName(exp.Value) != nil {
+			return t
+		}
+		return Any
+
+	case *ast.PrefixExpression:
+		right := c.expression(exp.Right)
+		switch {
+		case exp.Operator == "!":
+			return Bool
+		case right == Int || right == Float || right == Any:
+			return right
+		}
+		c.errorf(exp.Token, "unknown operator: %s%s", exp.Operator, objectType(right))
+		return Any
+
+	case *ast.InfixExpression:
+		return c.infix(exp, c.expression(exp.Left), c.expression(exp.Right))
+
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		consequence := c.statements(exp.Consequence.Statements)
+		if exp.Alternative == nil {
+			return join(consequence, Null)
+		}
+		return join(consequence, c.statements(exp.Alternative.Statements))
+
+	case *ast.FunctionLiteral:
+		return c.function(exp)
+
+	case *ast.CallExpression:
+		return c.call(exp)
+
+	case *ast.ArrayLiteral:
+		var elem Type
+		for _, el := range exp.Elements {
+			elem = join(elem, c.expression(el))
+		}
+		if elem == nil {
+			elem = Any
+		}
+		return &Array{Elem: elem}
+
+	case *ast.HashLiteral:
+		var key, value Type
+		for _, k := range exp.Keys {
+			kt := c.expression(k)
+			if !hashable(kt) {
+				c.errorf(start(k), "unusable as hash key: %s", objectType(kt))
+			}
+			key = join(key, kt)
+			value = join(value, c.expression(exp.Pairs[k]))
+		}
+		if key == nil {
+			key, value = Any, Any
+		}
+		return &Hash{Key: key, Value: value}
+
+	case *ast.IndexExpression:
+		left, index := c.expression(exp.Left), c.expression(exp.Index)
+		switch left := left.(type) {
+		case *Array:
+			if index == Int || index == Any {
+				return left.Elem
+			}
+		case *Hash:
+			if !hashable(index) {
+				c.errorf(start(exp.Index), "unusable as hash key: %s", objectType(index))
+			}
+			return left.Value
+		}
+		if left == Any {
+			return Any
+		}
+		c.errorf(exp.Token, "index operator not supported: %s", objectType(left))
+		return Any
+
+	case *ast.MemberExpression:
+		obj := c.expression(exp.Object)
+		if obj != Module && obj != Any {
+			c.errorf(exp.Token, "member access not supported: %s.%s", objectType(obj), exp.Property.Value)
+		}
+		if ident, ok := exp.Object.(*ast.Identifier); ok && c.resolved.Uses[ident] == nil {
+			if members, ok := builtinMembers[ident.Value]; ok {
+				if t, ok := members[exp.Property.Value]; ok {
+					return t
+				}
+				c.errorf(exp.Property.Token, "module %s has no exported member %s", ident.Value, exp.Property.Value)
+			}
+		}
+		return Any
+	}
+
+	//Macro literals, and nil for expressions that failed to parse
+	return Any
+}
+
+// The result of an infix expression, following evalInfixExpression
+func (c *checker) infix(exp *ast.InfixExpression, left, right Type) Type {
+	op := exp.Operator
+	comparison := op == "<" || op == ">" || op == "==" || op == "!="
+
+	if left == Any || right == Any {
+		known := left
+		if known == Any {
+			known = right
+		}
+		switch {
+		case comparison:
+			return Bool
+		case known == Float:
+			//With an integer or a float the result is a float
+			return Float
+		case known == String && op == "+":
+			return String
+		}
+		return Any
+	}
+
+	switch {
+	case left == Int && right == Int:
+		if comparison {
+			return Bool
+		}
+		return Int
+	case numeric(left) && numeric(right):
+		if comparison {
+			return Bool
+		}
+		return Float
+	case op == "==" || op == "!=":
+		return Bool
+	case objectType(left) != objectType(right):
+		c.errorf(exp.Token, "type mismatch: %s %s %s", objectType(left), op, objectType(right))
+		return Any
+	case left == String && right == String && op == "+":
+		return String
+	}
+	c.errorf(exp.Token, "unknown operator: %s %s %s", objectType(left), op, objectType(right))
+	return Any
+}
+
+// The type of fn as written in its annotations, unannotated parameters and results are any
+func (c *checker) signature(fn *ast.FunctionLiteral) *Func {
+	if sig, ok := c.signatures[fn]; ok {
+		return sig
+	}
+	sig := &Func{Params: make([]Type, len(fn.Parameters)), Result: Any}
+	for i := range fn.Parameters {
+		sig.Params[i] = Any
+		if t := fn.ParameterType(i); t != nil {
+			sig.Params[i] = c.annotation(t)
+		}
+	}
+	if fn.ReturnType != nil {
+		sig.Result = c.annotation(fn.ReturnType)
+	}
+	c.signatures[fn] = sig
+	return sig
+}
+
+// Checks the body of fn and returns its type, the result is inferred when not annotated
+func (c *checker) function(fn *ast.FunctionLiteral) Type {
+	sig := c.signature(fn)
+	for i, param := range fn.Parameters {
+		if b := c.bindings[param]; b != nil {
+			c.info.Types[b] = sig.Params[i]
+		}
+	}
+
+	outerReturns, outerResult := c.returns, c.result
+	var returns []Type
+	c.returns, c.result = &returns, nil
+	if fn.ReturnType != nil {
+		c.result = sig.Result
+	}
+	body := c.statements(fn.Body.Statements)
+	c.returns, c.result = outerReturns, outerResult
+
+	if fn.ReturnType != nil {
+		if body != nil && !consistent(body, sig.Result) {
+			tok := fn.Token
+			if n := len(fn.Body.Statements); n > 0 {
+				tok = statementToken(fn.Body.Statements[n-1])
+			}
+			c.errorf(tok, "cannot use %s as %s in return", body, sig.Result)
+		}
+		return sig
+	}
+
+	result := body
+	for _, t := range returns {
+		result = join(result, t)
+	}
+	if result == nil {
+		result = Null
+	}
+	return &Func{Params: sig.Params, Result: result}
+}
+
+func (c *checker) call(call *ast.CallExpression) Type {
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		b := c.resolved.Uses[ident]
+		//Arguments to quote and to macros are code, not values
+		if b == nil && (ident.Value == "quote" || ident.Value == "unquote") || b != nil && b.Kind == analysis.Macro {
+			return Any
+		}
+	}
+
+	callee := c.expression(call.Function)
+	args := make([]Type, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	switch fn := callee.(type) {
+	case *Func:
+		if ident, ok := call.Function.(*ast.Identifier); ok && ident.Value == "len" && c.resolved.Uses[ident] == nil && len(args) == 1 {
+			switch args[0].(type) {
+			case *Array, *Hash:
+			default:
+				if args[0] != String && args[0] != Any {
+					c.errorf(start(call.Arguments[0]), "argument to `len` not supported, got %s", objectType(args[0]))
+				}
+			}
+		}
+		//Calls with the wrong number of arguments are reported by the arity check of analysis
+		if fn.Params != nil && len(fn.Params) == len(args) {
+			for i, arg := range args {
+				if !consistent(arg, fn.Params[i]) {
+					c.errorf(start(call.Arguments[i]), "cannot use %s as %s in argument %d to %s", arg, fn.Params[i], i+1, call.Function)
+				}
+			}
+		}
+		return fn.Result
+	case Basic:
+		if fn == Any {
+			return Any
+		}
+	}
+	c.errorf(call.Token, "not a function: %s", objectType(callee))
+	return Any
+}
+
+// Converts an annotation to the type it names, unknown names are reported and become any
+func (c *checker) annotation(t *ast.TypeAnnotation) Type {
+	switch t.Name {
+	case "any", "int", "float", "string", "bool", "null", "module", "regex":
+		return Basic(t.Name)
+	case "array":
+		if len(t.Elements) == 1 {
+			return &Array{Elem: c.annotation(t.Elements[0])}
+		}
+		return &Array{Elem: Any}
+	case "hash":
+		if len(t.Elements) == 2 {
+			return &Hash{Key: c.annotation(t.Elements[0]), Value: c.annotation(t.Elements[1])}
+		}
+		return &Hash{Key: Any, Value: Any}
+	case "fn":
+		fn := &Func{Result: Any}
+		if t.Elements != nil {
+			fn.Params = make([]Type, len(t.Elements))
+			for i, p := range t.Elements {
+				fn.Params[i] = c.annotation(p)
+			}
+		}
+		if t.Result != nil {
+			fn.Result = c.annotation(t.Result)
+		}
+		return fn
+	}
+	c.errorf(t.Token, "unknown type %s", t.Name)
+	return Any
+}
+
+// The leftmost token of exp, where a diagnostic about it starts
+func start(exp ast.Expression) token.Token {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return start(exp.Left)
+	case *ast.CallExpression:
+		return start(exp.Function)
+	case *ast.IndexExpression:
+		return start(exp.Left)
+	case *ast.MemberExpression:
+		return start(exp.Object)
+	case *ast.Identifier:
+		return exp.Token
+	case *ast.IntegerLiteral:
+		return exp.Token
+	case *ast.FloatLiteral:
+		return exp.Token
+	case *ast.StringLiteral:
+		return exp.Token
+	case *ast.Boolean:
+		return exp.Token
+	case *ast.PrefixExpression:
+		return exp.Token
+	case *ast.IfExpression:
+		return exp.Token
+	case *ast.FunctionLiteral:
+		return exp.Token
+	case *ast.MacroLiteral:
+		return exp.Token
+	case *ast.ArrayLiteral:
+		return exp.Token
+	case *ast.HashLiteral:
+		return exp.Token
+	}
+	return token.Token{}
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	pos := analysis.StatementPos(stmt)
+	return token.Token{Literal: stmt.TokenLiteral(), Line: pos.Line, Column: pos.Column}
+}
@@ -0,0 +1,155 @@
+package checker
+
+import (
+	"mscript/ast"
+	"mscript/lexer"
+	"mscript/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x = 1 + 2; let s = "a" + "b"; x == s`, nil},
+		//Operators
+		{`1 + "a"`, []string{"1:3: error: type mismatch: INTEGER + STRING (type)"}},
+		{`let x = 1; let s = "a"; x - s`, []string{"1:27: error: type mismatch: INTEGER - STRING (type)"}},
+		{`"a" - "b"; true + false; -"a"`, []string{
+			"1:5: error: unknown operator: STRING - STRING (type)",
+			"1:17: error: unknown operator: BOOLEAN + BOOLEAN (type)",
+			"1:26: error: unknown operator: -STRING (type)",
+		}},
+		{`let f = fn(a: int) { a + 1 - "b" }`, []string{"1:28: error: type mismatch: INTEGER - STRING (type)"}},
+		{`let f = fn(a) { a + 1 - "b" }`, nil}, //a may be a float
+		{`let x: float = 1 + 0.5; let y: int = 2 * 1.5; let z: float = -x;`, []string{"1:38: error: cannot use float as int in let y (type)"}},
+		{`let f = fn(a) { a * 2.0 }; let n: int = f(1); 1.5 + "a"`, []string{
+			"1:41: error: cannot use float as int in let n (type)",
+			"1:51: error: type mismatch: FLOAT + STRING (type)",
+		}},
+		{`1 < 1.5; 2.5 == 2; let b: bool = 0.5 > 1;`, nil},
+		{`let f = fn(a) { a + "b" - 1 }`, []string{"1:25: error: type mismatch: STRING - INTEGER (type)"}},
+		{`1 == "a"; [1] != true; !5`, nil},
+		//Annotated lets
+		{`let x: int = 5; let y: string = x;`, []string{"1:33: error: cannot use int as string in let y (type)"}},
+		{`let x: any = 5; let y: string = x;`, nil},
+		{`let xs: [int] = [1, 2]; let ys: [string] = xs;`, []string{"1:44: error: cannot use [int] as [string] in let ys (type)"}},
+		{`let arr: [int] = [1, "a"]; let m: [[int]] = [[1], [2, true]];`, []string{
+			"1:22: error: cannot use string as int in let arr (type)",
+			"1:55: error: cannot use bool as int in let m (type)",
+		}},
+		{`let h: {string: int} = {"a": 1, "b": "c", 2: 3}; let f = fn(): [int] { return [1, "x"]; };`, []string{
+			"1:38: error: cannot use string as int in let h (type)",
+			"1:43: error: cannot use int as string in let h (type)",
+			"1:83: error: cannot use string as int in return (type)",
+		}},
+		{`let h: {string: int} = {"a": "b"};`, []string{`1:30: error: cannot use string as int in let h (type)`}},
+		{`let h = {"a": 1, "b": "c"}; let g: {string: int} = h;`, nil}, //Mixed values are any
+		{`let x: nothing = 1;`, []string{"1:8: error: unknown type nothing (type)"}},
+		//Functions
+		{`let add = fn(a: int, b: int): int { a + b }; add(1, "2")`, []string{"1:53: error: cannot use string as int in argument 2 to add (type)"}},
+		{`let add = fn(a: int, b: int) { a + b }; let s: string = add(1, 2);`, []string{"1:57: error: cannot use int as string in let s (type)"}},
+		{`let f = fn(): string { return 1; }`, []string{"1:31: error: cannot use int as string in return (type)"}},
+		{`let f = fn(): string { 1 }`, []string{"1:24: error: cannot use int as string in return (type)"}},
+		{`let f = fn(x): int { if (x) { return 1; } else { return 2; } }`, nil},
+		{`let f = fn(x) { if (x) { 1 } else { "a" } }; let n: int = f(true);`, nil},
+		{`let f = fn(x) { if (x) { return "a"; } "b" }; let n: int = f(true);`, []string{"1:60: error: cannot use string as int in let n (type)"}},
+		{`let fact = fn(n: int): int { if (n < 2) { return 1; } n * fact(n - 1) }; fact("3")`, []string{
+			"1:79: error: cannot use string as int in argument 1 to fact (type)",
+		}},
+		{`let apply = fn(f: fn(int): int, x: int) { f(x) }; apply(fn(s: string) { s }, 1); apply(fn(n) { n }, 1)`, []string{
+			"1:57: error: cannot use fn(string): string as fn(int): int in argument 1 to apply (type)",
+		}},
+		{`let f: fn = len; f(1, 2, 3); 5(1)`, []string{"1:31: error: not a function: INTEGER (type)"}},
+		{`len(1); len("a"); len([1]); puts(1, "a")`, []string{"1:5: error: argument to `len` not supported, got INTEGER (type)"}},
+		//Indexing and members
+		{`let xs = [1, 2]; xs["a"]; xs[0] + 1`, []string{"1:20: error: index operator not supported: ARRAY (type)"}},
+		{`let h = {"a": 1}; h[[1]]; let n: int = h["a"]; 1[0]`, []string{
+			"1:21: error: unusable as hash key: ARRAY (type)",
+			"1:49: error: index operator not supported: INTEGER (type)",
+		}},
+		{`{fn() {}: 1}`, []string{"1:2: error: unusable as hash key: FUNCTION (type)"}},
+		{`import "m.ms" as m; m.f(1) + "a"; let x = 1; x.f`, []string{"1:47: error: member access not supported: INTEGER.f (type)"}},
+		{`let parts = strings.split("a,b", ","); parts[0] + 1; strings.upper(1); strings.nope`, []string{
+			"1:49: error: type mismatch: STRING + INTEGER (type)",
+			"1:68: error: cannot use int as string in argument 1 to strings.upper (type)",
+			"1:80: error: module strings has no exported member nope (type)",
+		}},
+		{`let strings = fn() { 1 }; let f = fn(strings) { strings.x }`, nil},
+		{`let n: int = math.floor(2.5) + random.int(3); let s: string = math.sqrt(2);`, []string{"1:63: error: cannot use float as string in let s (type)"}},
+		{`let r: regex = re.compile("a+"); re.split("a", "b"); let n: int = re.replace(r, "a", "b");`, []string{
+			"1:43: error: cannot use string as regex in argument 1 to re.split (type)",
+			"1:67: error: cannot use string as int in let n (type)",
+		}},
+		//Unknown types are any
+		{`let f = fn(a, b) { a + b }; f(1, "a") + 1`, nil},
+		{`let g = fn() { h() + 1 }; let h = fn() { "a" };`, nil},
+		{`let line = readline(); line + 1`, nil},
+		{`let m = macro(a) { quote(unquote(a) + 1) }; m("a" - 1)`, nil},
+	}
+
+	for _, tt := range tests {
+		got := Check(parse(t, tt.input))
+		if len(got) != len(tt.expected) {
+			t.Errorf("%q: wrong diagnostics. got=%v, want=%v", tt.input, got, tt.expected)
+			continue
+		}
+		for i, d := range got {
+			if d.String() != tt.expected[i] {
+				t.Errorf("%q: diagnostics[%d] = %q, want %q", tt.input, i, d, tt.expected[i])
+			}
+		}
+	}
+}
+
+func TestInfer(t *testing.T) {
+	input := `let n = 1;
+let xs = [1, 2];
+let h = {"a": [true]};
+let add = fn(a: int, b) { a + b };
+let half = fn(v) { v / 2.0 };
+let mixed = [1, "a"];
+let f = fn(x) { if (x) { return "a"; } "b" };
+import "m.ms" as m;`
+	expected := map[string]string{
+		"n":     "int",
+		"xs":    "[int]",
+		"h":     "{string: [bool]}",
+		"add":   "fn(int, any)", //b may be a float
+		"half":  "fn(any): float",
+		"v":     "any",
+		"a":     "int",
+		"b":     "any",
+		"mixed": "[any]",
+		"f":     "fn(any): string",
+		"x":     "any",
+		"m":     "module",
+	}
+
+	_, info := Infer(parse(t, input))
+	for b, typ := range info.Types {
+		want, ok := expected[b.Name]
+		if !ok {
+			t.Errorf("unexpected binding %s", b.Name)
+			continue
+		}
+		if typ.String() != want {
+			t.Errorf("type of %s = %s, want %s", b.Name, typ, want)
+		}
+		delete(expected, b.Name)
+	}
+	for name := range expected {
+		t.Errorf("no type for %s", name)
+	}
+}
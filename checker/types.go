@@ -0,0 +1,255 @@
+package checker
+
+import (
+	"mscript/object"
+	"strings"
+)
+
+// The static type of an expression
+type Type interface {
+	String() string
+}
+
+// Types without parts
+type Basic string
+
+const (
+	Any    Basic = "any" //Unknown until the program runs, consistent with every type
+	Int    Basic = "int"
+	Float  Basic = "float"
+	String Basic = "string"
+	Bool   Basic = "bool"
+	Null   Basic = "null"
+	Module Basic = "module"
+	Regex  Basic = "regex"
+)
+
+func (b Basic) String() string {
+	return string(b)
+}
+
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string {
+	return "[" + a.Elem.String() + "]"
+}
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string {
+	return "{" + h.Key.String() + ": " + h.Value.String() + "}"
+}
+
+// Functions, builtins and closures
+type Func struct {
+	Params []Type //nil when the parameters are not known, like for puts or a bare fn annotation
+	Result Type
+}
+
+func (f *Func) String() string {
+	if f.Params == nil {
+		return "fn"
+	}
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.String()
+	}
+	out := "fn(" + strings.Join(params, ", ") + ")"
+	if f.Result != Any {
+		out += ": " + f.Result.String()
+	}
+	return out
+}
+
+// Reports whether a value of type t may be used where u is expected
+// Any is consistent with every type, other types only with types of the same shape
+func consistent(t, u Type) bool {
+	if t == Any || u == Any {
+		return true
+	}
+	switch t := t.(type) {
+	case Basic:
+		return t == u
+	case *Array:
+		u, ok := u.(*Array)
+		return ok && consistent(t.Elem, u.Elem)
+	case *Hash:
+		u, ok := u.(*Hash)
+		return ok && consistent(t.Key, u.Key) && consistent(t.Value, u.Value)
+	case *Func:
+		u, ok := u.(*Func)
+		if !ok || !consistent(t.Result, u.Result) {
+			return false
+		}
+		if t.Params == nil || u.Params == nil {
+			return true
+		}
+		if len(t.Params) != len(u.Params) {
+			return false
+		}
+		for i := range t.Params {
+			if !consistent(t.Params[i], u.Params[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// The type of a value that is either of type t or of type u
+// A nil type belongs to code that never produces a value because it returns first
+func join(t, u Type) Type {
+	switch {
+	case t == nil:
+		return u
+	case u == nil:
+		return t
+	case t.String() == u.String():
+		return t
+	}
+	if t, ok := t.(*Array); ok {
+		if u, ok := u.(*Array); ok {
+			return &Array{Elem: join(t.Elem, u.Elem)}
+		}
+	}
+	if t, ok := t.(*Hash); ok {
+		if u, ok := u.(*Hash); ok {
+			return &Hash{Key: join(t.Key, u.Key), Value: join(t.Value, u.Value)}
+		}
+	}
+	return Any
+}
+
+// The name the evaluator uses in its errors for values of type t
+func objectType(t Type) object.ObjectType {
+	switch t := t.(type) {
+	case Basic:
+		switch t {
+		case Int:
+			return object.INTEGER_OBJ
+		case Float:
+			return object.FLOAT_OBJ
+		case String:
+			return object.STRING_OBJ
+		case Bool:
+			return object.BOOLEAN_OBJ
+		case Null:
+			return object.NULL_OBJ
+		case Module:
+			return object.MODULE_OBJ
+		case Regex:
+			return object.REGEX_OBJ
+		}
+	case *Array:
+		return object.ARRAY_OBJ
+	case *Hash:
+		return object.HASH_OBJ
+	case *Func:
+		return object.FUNCTION_OBJ
+	}
+	return "ANY"
+}
+
+// Integers and floats mix in arithmetic
+func numeric(t Type) bool {
+	return t == Int || t == Float
+}
+
+// Only these can be hash keys, see object.Hashable
+func hashable(t Type) bool {
+	return t == Any || t == Int || t == String || t == Bool
+}
+
+// Types of the builtins, those missing are any
+var builtinTypes = map[string]Type{
+	"puts":     &Func{Result: Null},
+	"readline": &Func{Params: []Type{}, Result: Any}, //A string, or null at the end of the input
+	"len":      &Func{Params: []Type{Any}, Result: Int},
+	"strings":  Module,
+	"math":     Module,
+	"random":   Module,
+	"fs":       Module,
+	"os":       Module,
+	"json":     Module,
+	"re":       Module,
+}
+
+// Types of the members of builtin modules, by module
+var builtinMembers = map[string]map[string]Type{
+	"strings": {
+		"split":      &Func{Params: []Type{String, String}, Result: &Array{Elem: String}},
+		"join":       &Func{Params: []Type{&Array{Elem: String}, String}, Result: String},
+		"trim":       &Func{Result: String},
+		"trimLeft":   &Func{Result: String},
+		"trimRight":  &Func{Result: String},
+		"upper":      &Func{Params: []Type{String}, Result: String},
+		"lower":      &Func{Params: []Type{String}, Result: String},
+		"contains":   &Func{Params: []Type{String, String}, Result: Bool},
+		"startsWith": &Func{Params: []Type{String, String}, Result: Bool},
+		"endsWith":   &Func{Params: []Type{String, String}, Result: Bool},
+		"indexOf":    &Func{Params: []Type{String, String}, Result: Int},
+		"replace":    &Func{Params: []Type{String, String, String}, Result: String},
+		"repeat":     &Func{Params: []Type{String, Int}, Result: String},
+		"substring":  &Func{Result: String},
+		"chars":      &Func{Params: []Type{String}, Result: &Array{Elem: String}},
+		"format":     &Func{Result: String},
+	},
+	//Functions taking numbers accept any since integers and floats both work
+	"math": {
+		"pi":    Float,
+		"e":     Float,
+		"abs":   &Func{Params: []Type{Any}, Result: Any},
+		"min":   &Func{Result: Any},
+		"max":   &Func{Result: Any},
+		"pow":   &Func{Params: []Type{Any, Any}, Result: Any},
+		"sqrt":  &Func{Params: []Type{Any}, Result: Float},
+		"floor": &Func{Params: []Type{Any}, Result: Int},
+		"ceil":  &Func{Params: []Type{Any}, Result: Int},
+		"round": &Func{Params: []Type{Any}, Result: Int},
+		"sin":   &Func{Params: []Type{Any}, Result: Float},
+		"cos":   &Func{Params: []Type{Any}, Result: Float},
+		"tan":   &Func{Params: []Type{Any}, Result: Float},
+		"asin":  &Func{Params: []Type{Any}, Result: Float},
+		"acos":  &Func{Params: []Type{Any}, Result: Float},
+		"atan":  &Func{Params: []Type{Any}, Result: Float},
+		"atan2": &Func{Params: []Type{Any, Any}, Result: Float},
+	},
+	"random": {
+		"seed":    &Func{Params: []Type{Int}, Result: Null},
+		"float":   &Func{Params: []Type{}, Result: Float},
+		"int":     &Func{Result: Int},
+		"choice":  &Func{Params: []Type{&Array{Elem: Any}}, Result: Any},
+		"shuffle": &Func{Params: []Type{&Array{Elem: Any}}, Result: &Array{Elem: Any}},
+	},
+	"fs": {
+		"read":   &Func{Params: []Type{String}, Result: String},
+		"write":  &Func{Params: []Type{String, String}, Result: Null},
+		"exists": &Func{Params: []Type{String}, Result: Bool},
+		"list":   &Func{Params: []Type{String}, Result: &Array{Elem: String}},
+		"remove": &Func{Params: []Type{String}, Result: Null},
+	},
+	"os": {
+		"env":  &Func{Result: Any}, //A string or null for one variable, a hash of them all without arguments
+		"args": &Func{Params: []Type{}, Result: &Array{Elem: String}},
+		"exit": &Func{Result: Null},
+		"exec": &Func{Result: &Hash{Key: String, Value: Any}},
+	},
+	"json": {
+		"parse":     &Func{Params: []Type{String}, Result: Any},
+		"stringify": &Func{Result: String},
+	},
+	//Matches are hashes of the text, its position and its groups
+	"re": {
+		"compile": &Func{Params: []Type{String}, Result: Regex},
+		"match":   &Func{Params: []Type{Regex, String}, Result: Any},
+		"findAll": &Func{Params: []Type{Regex, String}, Result: &Array{Elem: &Hash{Key: String, Value: Any}}},
+		"replace": &Func{Params: []Type{Regex, String, String}, Result: String},
+		"split":   &Func{Params: []Type{Regex, String}, Result: &Array{Elem: String}},
+	},
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"mscript/debugger"
+	"os"
+)
+
+// mscript debug file
+// mscript debug --dap
+// Steps through file in the terminal, or serves the Debug Adapter Protocol on stdin and stdout for editors
+func debugCommand(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	dap := flags.Bool("dap", false, "serve the Debug Adapter Protocol, the client launches the program")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: mscript debug file\n       mscript debug --dap")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *dap {
+		if err := debugger.NewDAPServer(os.Stdin, os.Stdout).Serve(); err != nil {
+			fmt.Fprintf(os.Stderr, "debug: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if err := debugger.NewConsole(os.Stdin, os.Stdout).Run(flags.Arg(0)); err != nil {
+		return 1
+	}
+	return 0
+}
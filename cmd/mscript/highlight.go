@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"mscript/highlight"
+	"mscript/lineedit"
+	"os"
+)
+
+// mscript highlight [--format=auto|ansi|html] [file]
+// Prints file, or stdin without a file, with every token colored by its class
+func highlightCommand(args []string) int {
+	flags := flag.NewFlagSet("highlight", flag.ExitOnError)
+	format := flags.String("format", "auto", "ansi, html, or auto for ansi on a terminal and plain text otherwise")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: mscript highlight [--format=auto|ansi|html] [file]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	//Flags may also follow the file, as in mscript highlight file.ms --format=html
+	path := flags.Arg(0)
+	if flags.NArg() > 1 {
+		flags.Parse(flags.Args()[1:])
+		if flags.NArg() > 0 {
+			flags.Usage()
+			return 2
+		}
+	}
+
+	_, src, err := readSource(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch *format {
+	case "auto":
+		if colorEnabled() {
+			fmt.Print(highlight.ANSI(string(src)))
+		} else {
+			os.Stdout.Write(src)
+		}
+	case "ansi":
+		fmt.Print(highlight.ANSI(string(src)))
+	case "html":
+		fmt.Print(highlight.HTML(string(src)))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q, want auto, ansi or html\n", *format)
+		return 2
+	}
+	return 0
+}
+
+// Reports whether stdout takes colors, it has to be a terminal and NO_COLOR unset
+func colorEnabled() bool {
+	return os.Getenv("NO_COLOR") == "" && lineedit.IsTerminal(os.Stdout)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"mscript"
+	"mscript/lineedit"
+	"mscript/object"
+	"mscript/repl"
+	"os"
+	"os/user"
+	"strings"
+)
+
+// Subcommands, anything else is treated as a script to run
+var commands = map[string]func(args []string) int{
+	"debug":     debugCommand,
+	"fmt":       fmtCommand,
+	"highlight": highlightCommand,
+	"lsp":       lspCommand,
+	"parse":     parseCommand,
+	"tokens":    tokensCommand,
+	"vet":       vetCommand,
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
+	useVM := flag.Bool("vm", false, "run on the bytecode vm instead of the tree walker")
+	quiet := flag.Bool("q", false, "no banner or prompts, the default when stdin is not a terminal")
+	asJSON := flag.Bool("json", false, "print REPL results as JSON objects, one per line")
+	strict := flag.Bool("strict", false, "check values against type annotations while running, tree walker only")
+	optimize := flag.Bool("O", false, "fold constants and remove dead code before running or compiling")
+	caps := capabilityFlags(flag.CommandLine)
+	seed := flag.Int64("seed", 0, "seed the random module so a script draws the same numbers every run, 0 seeds from the clock")
+	flag.Parse()
+
+	//mscript file.ms runs a script, no arguments starts the REPL
+	if flag.NArg() > 0 {
+		if err := checkScript(flag.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "mscript: %s\n", err)
+			os.Exit(2)
+		}
+		if *useVM && *strict {
+			fmt.Fprintln(os.Stderr, "mscript: -strict is not supported with -vm")
+			os.Exit(2)
+		}
+		backend := mscript.TreeWalker
+		if *useVM {
+			backend = mscript.VM
+		}
+		opts := []mscript.Option{mscript.WithBackend(backend), mscript.WithStrict(*strict), mscript.WithOptimize(*optimize),
+			mscript.WithCapabilities(*caps), mscript.WithArgs(flag.Args()[1:]...)}
+		if *seed != 0 {
+			opts = append(opts, mscript.WithSeed(*seed))
+		}
+		os.Exit(runFile(flag.Arg(0), opts...))
+	}
+
+	*quiet = *quiet || !lineedit.IsTerminal(os.Stdin)
+	opts := []repl.Option{repl.WithQuiet(*quiet), repl.WithColor(colorEnabled()), repl.WithCapabilities(*caps)}
+	if *asJSON {
+		opts = append(opts, repl.WithFormat(repl.FormatJSON))
+	}
+
+	if !*quiet {
+		user, err := user.Current()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Hello %s! This is the mScript!\n", user.Username)
+		fmt.Printf("Feel free to type in commands\n")
+	}
+	os.Exit(repl.Start(os.Stdin, os.Stdout, opts...))
+}
+
+// Evaluates a script file and returns the process exit code
+func runFile(path string, opts ...mscript.Option) int {
+	in := mscript.New(opts...)
+	if _, err := in.RunFile(path); err != nil {
+		var exit *mscript.ExitError
+		if errors.As(err, &exit) {
+			return exit.Code
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return 1
+	}
+	return 0
+}
+
+// Defines the flags granting capabilities on fs
+// -allow-read and -allow-write alone grant every path, a directory must be given with = as
+// in -allow-read=dir since the next argument is never taken as their value
+func capabilityFlags(fs *flag.FlagSet) *object.Capabilities {
+	caps := &object.Capabilities{}
+	fs.Var((*pathsFlag)(&caps.Read), "allow-read", "let scripts read files, -allow-read=dir only inside dir, can be repeated")
+	fs.Var((*pathsFlag)(&caps.Write), "allow-write", "let scripts write files, -allow-write=dir only inside dir, can be repeated")
+	fs.BoolVar(&caps.Env, "allow-env", false, "let scripts read environment variables")
+	fs.BoolVar(&caps.Exec, "allow-exec", false, "let scripts run programs")
+	return caps
+}
+
+// Catches a directory given to -allow-read or -allow-write without = ending up as the script
+func checkScript(path string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("%s is a directory, not a script (give directories as -allow-read=%s)", path, path)
+	}
+	return nil
+}
+
+// A flag granting every path when given alone or the directories given with =dir, it can be repeated
+type pathsFlag object.Paths
+
+func (f *pathsFlag) String() string {
+	if f == nil || f.All {
+		return ""
+	}
+	return strings.Join(f.Dirs, ",")
+}
+
+func (f *pathsFlag) Set(value string) error {
+	if value == "true" {
+		f.All = true
+		return nil
+	}
+	if value == "" || value == "false" {
+		return fmt.Errorf("expected a directory")
+	}
+	f.Dirs = append(f.Dirs, value)
+	return nil
+}
+
+func (f *pathsFlag) IsBoolFlag() bool { return true }
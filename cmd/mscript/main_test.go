@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestCapabilityFlags(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		args     []string
+		read     []string
+		readAll  bool
+		writeAll bool
+		rest     []string
+	}{
+		{[]string{"-allow-read=" + dir, "-allow-read=lib", "s.ms"}, []string{dir, "lib"}, false, false, []string{"s.ms"}},
+		{[]string{"--allow-write", "-allow-env", "s.ms", "a"}, nil, false, true, []string{"s.ms", "a"}},
+		//Without = the directory is not the value of the flag, it is taken as the script
+		{[]string{"-allow-read", dir, "s.ms"}, nil, true, false, []string{dir, "s.ms"}},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("mscript", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		caps := capabilityFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatalf("%v: %s", tt.args, err)
+		}
+		if !reflect.DeepEqual(caps.Read.Dirs, tt.read) || caps.Read.All != tt.readAll || caps.Write.All != tt.writeAll {
+			t.Errorf("%v: wrong capabilities. got=%+v", tt.args, *caps)
+		}
+		if !reflect.DeepEqual(fs.Args(), tt.rest) {
+			t.Errorf("%v: wrong arguments. got=%v, want=%v", tt.args, fs.Args(), tt.rest)
+		}
+	}
+
+	if err := checkScript(dir); err == nil {
+		t.Errorf("a directory was accepted as the script")
+	}
+	if err := checkScript("s.ms"); err != nil {
+		t.Errorf("script rejected: %s", err)
+	}
+
+	fs := flag.NewFlagSet("mscript", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	capabilityFlags(fs)
+	if err := fs.Parse([]string{"-allow-read="}); err == nil {
+		t.Errorf("empty directory accepted")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"mscript/astjson"
+	"mscript/lexer"
+	"mscript/parser"
+	"os"
+)
+
+// mscript parse [--json] [--trace] [file]
+// Prints the parse tree of file, or stdin without a file
+func parseCommand(args []string) int {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the tree as JSON with node kinds and positions")
+	trace := flags.Bool("trace", false, "log entry and exit of every parse function to stderr")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: mscript parse [--json] [--trace] [file]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	name, src, err := readSource(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	if *trace {
+		p.Trace(os.Stderr)
+	}
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, msg)
+		}
+		return 1
+	}
+
+	if !*asJSON {
+		//One fully parenthesized statement per line
+		for _, stmt := range program.Statements {
+			fmt.Println(stmt.String())
+		}
+		return 0
+	}
+
+	data, err := astjson.MarshalIndent(program, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(append(data, '\n'))
+	return 0
+}
+
+// Reads the file at path, or stdin when path is empty, and returns the name to report it by
+func readSource(path string) (string, []byte, error) {
+	if path == "" {
+		src, err := io.ReadAll(os.Stdin)
+		return "<stdin>", src, err
+	}
+	src, err := os.ReadFile(path)
+	return path, src, err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"mscript/lexer"
+	"mscript/token"
+	"os"
+)
+
+// mscript tokens [file]
+// Prints every token of file, or stdin without a file, with its position
+func tokensCommand(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: mscript tokens [file]")
+	}
+	flags.Parse(args)
+
+	_, src, err := readSource(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	l := lexer.New(string(src))
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			fmt.Printf("%d:%d\tEOF\n", tok.Line, tok.Column)
+			return 0
+		}
+		fmt.Printf("%d:%d\t%-9s %q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+	}
+}
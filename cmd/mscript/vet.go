@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"mscript/analysis"
+	"mscript/checker"
+	"mscript/lexer"
+	"mscript/parser"
+	"os"
+	"sort"
+)
+
+// A diagnostic as printed by vet -json
+type vetDiagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Check     string `json:"check"`
+	Message   string `json:"message"`
+}
+
+// mscript vet [-json] [files...]
+// Reports likely mistakes and type errors in each file, or stdin without files, and exits with 1 when there are any
+func vetCommand(args []string) int {
+	flags := flag.NewFlagSet("vet", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the diagnostics as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: mscript vet [-json] [files...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+
+	diagnostics := []vetDiagnostic{}
+	for _, path := range paths {
+		name, src, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		diagnostics = append(diagnostics, vetSource(name, string(src))...)
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(diagnostics, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		for _, d := range diagnostics {
+			fmt.Printf("%s:%d:%d: %s: %s (%s)\n", d.File, d.Line, d.Column, d.Severity, d.Message, d.Check)
+		}
+	}
+	if len(diagnostics) != 0 {
+		return 1
+	}
+	return 0
+}
+
+// Parser errors when src does not parse, what the checks find otherwise
+func vetSource(name, src string) []vetDiagnostic {
+	var diagnostics []vetDiagnostic
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	for _, err := range p.ErrorList() {
+		diagnostics = append(diagnostics, vetDiagnostic{
+			File:      name,
+			Line:      err.Line,
+			Column:    err.Column,
+			EndLine:   err.Line,
+			EndColumn: err.Column,
+			Severity:  analysis.Error.String(),
+			Check:     "syntax",
+			Message:   err.Message,
+		})
+	}
+	if len(diagnostics) != 0 {
+		return diagnostics
+	}
+
+	found := append(analysis.Check(program), checker.Check(program)...)
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Start.Before(found[j].Start)
+	})
+	for _, d := range found {
+		diagnostics = append(diagnostics, vetDiagnostic{
+			File:      name,
+			Line:      d.Start.Line,
+			Column:    d.Start.Column,
+			EndLine:   d.End.Line,
+			EndColumn: d.End.Column,
+			Severity:  d.Severity.String(),
+			Check:     d.Check,
+			Message:   d.Message,
+		})
+	}
+	return diagnostics
+}
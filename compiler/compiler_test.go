@@ -0,0 +1,277 @@
+package compiler
+
+import (
+	"fmt"
+	"mscript/Code"
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/parser"
+	"strings"
+	"testing"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		expected := code.Instructions{}
+		for _, ins := range tt.expectedInstructions {
+			expected = append(expected, ins...)
+		}
+		if bytecode.Instructions.String() != expected.String() {
+			t.Errorf("%q: wrong instructions.\nwant=\n%s\ngot=\n%s", tt.input, expected, bytecode.Instructions)
+		}
+
+		if len(bytecode.Constants) != len(tt.expectedConstants) {
+			t.Fatalf("%q: wrong number of constants. got=%d, want=%d", tt.input, len(bytecode.Constants), len(tt.expectedConstants))
+		}
+		for i, constant := range tt.expectedConstants {
+			switch constant := constant.(type) {
+			case int:
+				if bytecode.Constants[i].(*object.Integer).Value != int64(constant) {
+					t.Errorf("%q: constant %d wrong. got=%s", tt.input, i, bytecode.Constants[i].Inspect())
+				}
+			case string:
+				if bytecode.Constants[i].(*object.String).Value != constant {
+					t.Errorf("%q: constant %d wrong. got=%s", tt.input, i, bytecode.Constants[i].Inspect())
+				}
+			case []code.Instructions:
+				fn, ok := bytecode.Constants[i].(*object.CompiledFunction)
+				if !ok {
+					t.Errorf("%q: constant %d not a function. got=%T", tt.input, i, bytecode.Constants[i])
+					continue
+				}
+				want := code.Instructions{}
+				for _, ins := range constant {
+					want = append(want, ins...)
+				}
+				if fn.Instructions.String() != want.String() {
+					t.Errorf("%q: constant %d wrong instructions.\nwant=\n%s\ngot=\n%s", tt.input, i, want, fn.Instructions)
+				}
+			}
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 < 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 } else { 20 }; 3333;",
+			expectedConstants: []interface{}{10, 20, 3333},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),              // 0000
+				code.Make(code.OpJumpNotTruthy, 10), // 0001
+				code.Make(code.OpConstant, 0),       // 0004
+				code.Make(code.OpJump, 13),          // 0007
+				code.Make(code.OpConstant, 1),       // 0010
+				code.Make(code.OpPop),               // 0013
+				code.Make(code.OpConstant, 2),       // 0014
+				code.Make(code.OpPop),               // 0017
+			},
+		},
+		{
+			input:             "if (true) { 10 };",
+			expectedConstants: []interface{}{10},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionsAndClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let countDown = fn(x) { countDown(x - 1); }; countDown(1);",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSub),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "fn() { }",
+			expectedConstants: []interface{}{[]code.Instructions{code.Make(code.OpReturn)}},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestBuiltinsAndMembers(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `puts(m.name)`,
+			expectedConstants: []interface{}{"name"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpMember, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		symbols := NewSymbolTable()
+		for i, v := range object.Builtins {
+			symbols.DefineBuiltin(i, v.Name)
+		}
+		symbols.Define("m")
+
+		compiler := NewWithState(symbols, []object.Object{})
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		expected := code.Instructions{}
+		for _, ins := range tt.expectedInstructions {
+			expected = append(expected, ins...)
+		}
+		if compiler.Bytecode().Instructions.String() != expected.String() {
+			t.Errorf("wrong instructions.\nwant=\n%s\ngot=\n%s", expected, compiler.Bytecode().Instructions)
+		}
+	}
+}
+
+func TestUnsupportedNode(t *testing.T) {
+	program := parser.New(lexer.New(`import "lib.ms";`)).ParseProgram()
+	if err := New().Compile(program); err == nil {
+		t.Errorf("expected an error compiling an import")
+	}
+}
+
+func TestUndefinedIdentifier(t *testing.T) {
+	program := parser.New(lexer.New(`foobar`)).ParseProgram()
+	err := New().Compile(program)
+	if err == nil || err.Error() != "identifier not found: foobar" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	//Identifiers cannot contain digits, so the locals are named vaa, vab, ...
+	var locals strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&locals, "let v%c%c = %d; ", 'a'+i/26, 'a'+i%26, i)
+	}
+	elements := make([]string, 65537)
+	for i := range elements {
+		elements[i] = fmt.Sprint(i)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn() { " + locals.String() + "}", "program too large: operand 256 of OpSetLocal is out of range, the limit is 255"},
+		{"[" + strings.Join(elements, ", ") + "]", "program too large: operand 65536 of OpConstant is out of range, the limit is 65535"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		err := New().Compile(program)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. got=%v, want=%q", err, tt.expected)
+		}
+	}
+}
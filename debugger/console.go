@@ -0,0 +1,388 @@
+package debugger
+
+import (
+	"fmt"
+	"io"
+	"mscript"
+	"mscript/ast"
+	"mscript/lineedit"
+	"mscript/object"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const PROMPT = "(debug) "
+
+// How many lines list shows on each side of the current one
+const listContext = 3
+
+type consoleCommand struct {
+	args string //Shown after the name in help
+	help string
+	//Runs the command, resume reports whether the program goes on with action
+	run func(c *Console, arg string) (action Action, resume bool)
+}
+
+var consoleCommands map[string]consoleCommand
+
+// Set up in init since help refers back to the table
+func init() {
+	consoleCommands = map[string]consoleCommand{
+		"help":        {"", "show this help", (*Console).help},
+		"break":       {"[file:]line", "pause before the statements on line", (*Console).breakCommand},
+		"delete":      {"[file:]line", "remove the breakpoint on line", (*Console).deleteCommand},
+		"breakpoints": {"", "list the breakpoints", (*Console).listBreakpoints},
+		"continue":    {"", "run until the next breakpoint", resumeWith(Continue)},
+		"step":        {"", "run to the next statement, entering calls", resumeWith(StepIn)},
+		"next":        {"", "run to the next statement of this function, stepping over calls", resumeWith(StepOver)},
+		"out":         {"", "run until the current function returns", resumeWith(StepOut)},
+		"where":       {"", "show the call stack", (*Console).where},
+		"frame":       {"n", "select frame n of the call stack for locals and print", (*Console).selectFrame},
+		"locals":      {"", "show the bindings visible in the selected frame", (*Console).locals},
+		"list":        {"", "show the source around the current statement", (*Console).list},
+		"print":       {"expr", "evaluate expr in the selected frame", (*Console).print},
+		"watch":       {"expr", "evaluate expr each time the program pauses", (*Console).watch},
+		"unwatch":     {"n", "remove watch n", (*Console).unwatch},
+		"quit":        {"", "stop the program", resumeWith(Stop)},
+	}
+}
+
+var consoleCommandOrder = []string{
+	"help", "break", "delete", "breakpoints", "continue", "step", "next", "out",
+	"where", "frame", "locals", "list", "print", "watch", "unwatch", "quit",
+}
+
+var consoleAliases = map[string]string{
+	"h": "help", "b": "break", "d": "delete", "c": "continue", "s": "step", "n": "next", "o": "out",
+	"bt": "where", "f": "frame", "l": "list", "p": "print", "w": "watch", "q": "quit",
+}
+
+// A debugger driven by commands typed in a terminal
+type Console struct {
+	out     io.Writer
+	editor  *lineedit.Editor
+	d       *Debugger
+	file    string //The program being debugged
+	frame   int    //Selected frame, 0 is the innermost
+	watches []string
+	repeat  string //Command run again by an empty line
+}
+
+// Creates a console reading commands from in and writing to out
+// The program being debugged shares both
+func NewConsole(in io.Reader, out io.Writer) *Console {
+	c := &Console{out: out, editor: lineedit.New(in, out), d: New()}
+	c.d.StopOnEntry = true
+	c.d.OnPause = c.paused
+	return c
+}
+
+// Runs the file at path, pausing before its first statement
+// opts are passed on to the interpreter running it
+func (c *Console) Run(path string, opts ...mscript.Option) error {
+	file, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	c.file = file
+
+	opts = append(opts,
+		mscript.WithStdout(c.out),
+		mscript.WithStdin(&programInput{editor: c.editor}),
+		mscript.WithDebugHook(c.d.Hook),
+	)
+	result, err := mscript.New(opts...).RunFile(file)
+	switch {
+	case err != nil && err.Error() == ErrStopped.Error():
+		fmt.Fprintln(c.out, "program stopped")
+		return nil
+	case err != nil:
+		fmt.Fprintf(c.out, "program failed: %s\n", err)
+		return err
+	}
+	if result != nil {
+		fmt.Fprintf(c.out, "program finished: %s\n", summarize(result))
+	} else {
+		fmt.Fprintln(c.out, "program finished")
+	}
+	return nil
+}
+
+// Shows where the program paused and reads commands until one resumes it
+func (c *Console) paused(reason string) Action {
+	c.frame = 0
+	frame := c.current()
+	fmt.Fprintf(c.out, "%s at %s:%d in %s\n", reason, c.relative(frame.File), frame.Line, frame.Name)
+	c.printLines(frame, frame.Line, frame.Line)
+	c.printWatches()
+
+	for {
+		line, err := c.editor.ReadLine(PROMPT)
+		if err == lineedit.ErrInterrupted {
+			continue
+		}
+		if err != nil {
+			return Stop
+		}
+		c.editor.AddHistory(line)
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			line = c.repeat
+		}
+		if line == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(line, " ")
+		if full, ok := consoleAliases[name]; ok {
+			name = full
+		}
+		cmd, ok := consoleCommands[name]
+		if !ok {
+			fmt.Fprintf(c.out, "unknown command %s, try help\n", name)
+			continue
+		}
+		arg = strings.TrimSpace(arg)
+		if cmd.args != "" && !strings.HasPrefix(cmd.args, "[") && arg == "" {
+			fmt.Fprintf(c.out, "usage: %s %s\n", name, cmd.args)
+			continue
+		}
+
+		c.repeat = ""
+		if name == "step" || name == "next" || name == "out" {
+			c.repeat = name
+		}
+		if action, resume := cmd.run(c, arg); resume {
+			return action
+		}
+	}
+}
+
+func resumeWith(action Action) func(c *Console, arg string) (Action, bool) {
+	return func(*Console, string) (Action, bool) { return action, true }
+}
+
+func (c *Console) help(string) (Action, bool) {
+	for _, name := range consoleCommandOrder {
+		cmd := consoleCommands[name]
+		usage := name
+		for alias, full := range consoleAliases {
+			if full == name {
+				usage += ", " + alias
+			}
+		}
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		fmt.Fprintf(c.out, "%-24s %s\n", usage, cmd.help)
+	}
+	fmt.Fprintln(c.out, "An empty line repeats the last step, next or out")
+	return 0, false
+}
+
+func (c *Console) current() Frame {
+	frames := c.d.Frames()
+	if c.frame >= len(frames) {
+		return Frame{}
+	}
+	return frames[c.frame]
+}
+
+// Parses [file:]line, files are relative to the program being debugged
+func (c *Console) location(arg string) (string, int, bool) {
+	file, lineText := c.file, arg
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		file, lineText = arg[:i], arg[i+1:]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(c.file), file)
+		}
+	}
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		fmt.Fprintf(c.out, "invalid line %q\n", lineText)
+		return "", 0, false
+	}
+	return file, line, true
+}
+
+func (c *Console) breakCommand(arg string) (Action, bool) {
+	if arg == "" {
+		arg = strconv.Itoa(c.current().Line)
+	}
+	file, line, ok := c.location(arg)
+	if !ok {
+		return 0, false
+	}
+	c.d.SetBreakpoint(file, line)
+	fmt.Fprintf(c.out, "breakpoint at %s:%d\n", c.relative(file), line)
+	return 0, false
+}
+
+func (c *Console) deleteCommand(arg string) (Action, bool) {
+	if arg == "" {
+		arg = strconv.Itoa(c.current().Line)
+	}
+	file, line, ok := c.location(arg)
+	if !ok {
+		return 0, false
+	}
+	if !c.d.ClearBreakpoint(file, line) {
+		fmt.Fprintf(c.out, "no breakpoint at %s:%d\n", c.relative(file), line)
+		return 0, false
+	}
+	fmt.Fprintf(c.out, "deleted breakpoint at %s:%d\n", c.relative(file), line)
+	return 0, false
+}
+
+func (c *Console) listBreakpoints(string) (Action, bool) {
+	breakpoints := c.d.Breakpoints()
+	files := make([]string, 0, len(breakpoints))
+	for file := range breakpoints {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		lines := breakpoints[file]
+		sort.Ints(lines)
+		for _, line := range lines {
+			fmt.Fprintf(c.out, "%s:%d\n", c.relative(file), line)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(c.out, "no breakpoints")
+	}
+	return 0, false
+}
+
+func (c *Console) where(string) (Action, bool) {
+	for i, frame := range c.d.Frames() {
+		marker := "  "
+		if i == c.frame {
+			marker = "=>"
+		}
+		fmt.Fprintf(c.out, "%s #%d %s at %s:%d\n", marker, i, frame.Name, c.relative(frame.File), frame.Line)
+	}
+	return 0, false
+}
+
+func (c *Console) selectFrame(arg string) (Action, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 || n >= len(c.d.Frames()) {
+		fmt.Fprintf(c.out, "no frame %s\n", arg)
+		return 0, false
+	}
+	c.frame = n
+	frame := c.current()
+	fmt.Fprintf(c.out, "#%d %s at %s:%d\n", n, frame.Name, c.relative(frame.File), frame.Line)
+	return 0, false
+}
+
+func (c *Console) locals(string) (Action, bool) {
+	for _, scope := range c.d.Scopes(c.current()) {
+		fmt.Fprintf(c.out, "%s:\n", scope.Name)
+		for _, name := range scope.Env.Names() {
+			value, _ := scope.Env.Get(name)
+			fmt.Fprintf(c.out, "  %s = %s\n", name, summarize(value))
+		}
+	}
+	return 0, false
+}
+
+func (c *Console) list(string) (Action, bool) {
+	frame := c.current()
+	c.printLines(frame, frame.Line-listContext, frame.Line+listContext)
+	return 0, false
+}
+
+// Prints the lines from first to last of the file of frame, marking the current line and breakpoints
+func (c *Console) printLines(frame Frame, first, last int) {
+	for line := max(first, 1); line <= last; line++ {
+		text := c.d.SourceLine(frame.File, line)
+		if text == "" && line != frame.Line {
+			continue
+		}
+		marker := "  "
+		switch {
+		case line == frame.Line:
+			marker = "=>"
+		case c.d.HasBreakpoint(frame.File, line):
+			marker = " *"
+		}
+		fmt.Fprintf(c.out, "%s %4d  %s\n", marker, line, text)
+	}
+}
+
+func (c *Console) print(arg string) (Action, bool) {
+	fmt.Fprintln(c.out, summarize(c.d.Eval(arg, c.current())))
+	return 0, false
+}
+
+func (c *Console) watch(arg string) (Action, bool) {
+	c.watches = append(c.watches, arg)
+	fmt.Fprintf(c.out, "%d: %s = %s\n", len(c.watches), arg, summarize(c.d.Eval(arg, c.current())))
+	return 0, false
+}
+
+func (c *Console) unwatch(arg string) (Action, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(c.watches) {
+		fmt.Fprintf(c.out, "no watch %s\n", arg)
+		return 0, false
+	}
+	c.watches = append(c.watches[:n-1], c.watches[n:]...)
+	return 0, false
+}
+
+func (c *Console) printWatches() {
+	for i, expr := range c.watches {
+		fmt.Fprintf(c.out, "%d: %s = %s\n", i+1, expr, summarize(c.d.Eval(expr, c.current())))
+	}
+}
+
+// file relative to the directory of the program, when it is inside it
+func (c *Console) relative(file string) string {
+	if rel, err := filepath.Rel(filepath.Dir(c.file), file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
+
+// Shows obj on one line, functions by their parameters only
+func summarize(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Function:
+		return "fn(" + joinIdentifiers(obj.Parameters) + ") {...}"
+	case *object.Macro:
+		return "macro(" + joinIdentifiers(obj.Parameters) + ") {...}"
+	}
+	return strings.ReplaceAll(obj.Inspect(), "\n", " ")
+}
+
+func joinIdentifiers(idents []*ast.Identifier) string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+// Gives readline in the program the lines typed into the console
+type programInput struct {
+	editor  *lineedit.Editor
+	pending string
+}
+
+func (p *programInput) Read(b []byte) (int, error) {
+	if p.pending == "" {
+		line, err := p.editor.ReadLine("")
+		if err != nil {
+			return 0, io.EOF
+		}
+		p.pending = line + "\n"
+	}
+	n := copy(b, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
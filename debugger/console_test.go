@@ -0,0 +1,78 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func runConsole(t *testing.T, src, input string) string {
+	t.Helper()
+	path := writeProgram(t, src)
+	var out bytes.Buffer
+	NewConsole(strings.NewReader(input), &out).Run(path)
+	return out.String()
+}
+
+func TestConsole(t *testing.T) {
+	out := runConsole(t, program, "b 2\nwatch sum\nc\nwhere\nlocals\np a * 10\nout\n\nq\n")
+
+	expected := []string{
+		"entry at main.ms:1 in main\n=>    1  let add = fn(a, b) {\n",
+		"breakpoint at main.ms:2\n",
+		"1: sum = ERROR: identifier not found: sum\n",
+		"breakpoint at main.ms:2 in add\n=>    2    let sum = a + b;\n1: sum = ERROR: identifier not found: sum\n",
+		"=> #0 add at main.ms:2\n   #1 main at main.ms:5\n",
+		"Locals:\n  a = 1\n  b = 2\nGlobals:\n  add = fn(a, b) {...}\n",
+		"(debug) 10\n",
+		"step at main.ms:6 in main\n",
+		"breakpoint at main.ms:2 in add\n",
+		"program stopped\n",
+	}
+	for _, want := range expected {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "13") {
+		t.Errorf("the program ran past quit:\n%s", out)
+	}
+}
+
+func TestConsoleCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"bogus\nq\n", "unknown command bogus, try help\n"},
+		{"p\nq\n", "usage: print expr\n"},
+		{"b x\nq\n", "invalid line \"x\"\n"},
+		{"d 4\nq\n", "no breakpoint at main.ms:4\n"},
+		{"b 4\nb 2\nbreakpoints\nq\n", "main.ms:2\nmain.ms:4\n"},
+		{"frame 3\nq\n", "no frame 3\n"},
+		{"watch 1 + 1\nunwatch 1\nunwatch 1\nq\n", "1: 1 + 1 = 2\n(debug) (debug) no watch 1\n"},
+		{"n\nl\nq\n", "      2    let sum = a + b;\n      3    sum\n      4  };\n=>    5  let x = add(1, 2);\n      6  let y = add(x, 10);\n      7  puts(y);\n"},
+		{"c\n", "13\nprogram finished: null\n"},
+		{"", "program stopped\n"},
+	}
+
+	for _, tt := range tests {
+		if out := runConsole(t, program, tt.input); !strings.Contains(out, tt.expected) {
+			t.Errorf("%q: output does not contain %q:\n%s", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestConsoleProgramInput(t *testing.T) {
+	out := runConsole(t, "let name = readline();\nputs(\"hi \" + name);\n", "c\nBob\n")
+	if !strings.Contains(out, "hi Bob\n") {
+		t.Errorf("readline did not read from the console:\n%s", out)
+	}
+}
+
+func TestConsoleProgramError(t *testing.T) {
+	out := runConsole(t, "let x = 1;\nx + true;\n", "c\n")
+	if !strings.Contains(out, "program failed: type mismatch: INTEGER + BOOLEAN\n") {
+		t.Errorf("error not reported:\n%s", out)
+	}
+}
@@ -0,0 +1,448 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mscript"
+	"mscript/object"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// The only thread, programs run on one
+const threadID = 1
+
+// A Debug Adapter Protocol server, editors launch a program through it and drive the debugger
+type DAPServer struct {
+	in  *bufio.Reader
+	out io.Writer
+	d   *Debugger
+
+	mu      sync.Mutex //Guards writing to out, seq and frames
+	seq     int
+	frames  []Frame //Of the paused program, nil while it runs
+	program string
+	opts    []mscript.Option
+	running bool
+	resume  chan Action
+	done    chan struct{}
+
+	//Values listed by variables requests, valid until the program resumes
+	refs []func() []variable
+}
+
+// Creates a server reading requests from in and writing responses and events to out
+// opts are passed on to the interpreter running the launched program
+func NewDAPServer(in io.Reader, out io.Writer, opts ...mscript.Option) *DAPServer {
+	s := &DAPServer{
+		in:     bufio.NewReader(in),
+		out:    out,
+		d:      New(),
+		opts:   opts,
+		resume: make(chan Action),
+		done:   make(chan struct{}),
+	}
+	s.d.OnPause = s.paused
+	return s
+}
+
+type dapMessage struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    *bool           `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       any             `json:"body,omitempty"`
+}
+
+type source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path"`
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type dapHandler func(s *DAPServer, args json.RawMessage) (body any, err error)
+
+var dapRequests = map[string]dapHandler{
+	"initialize":        (*DAPServer).initialize,
+	"launch":            (*DAPServer).launch,
+	"setBreakpoints":    (*DAPServer).setBreakpoints,
+	"configurationDone": (*DAPServer).configurationDone,
+	"threads":           (*DAPServer).threads,
+	"stackTrace":        (*DAPServer).stackTrace,
+	"scopes":            (*DAPServer).scopes,
+	"variables":         (*DAPServer).variables,
+	"evaluate":          (*DAPServer).evaluate,
+	"continue":          (*DAPServer).checkPaused,
+	"next":              (*DAPServer).checkPaused,
+	"stepIn":            (*DAPServer).checkPaused,
+	"stepOut":           (*DAPServer).checkPaused,
+	"pause":             (*DAPServer).pause,
+}
+
+// The requests that resume a paused program
+var resumeActions = map[string]Action{"continue": Continue, "next": StepOver, "stepIn": StepIn, "stepOut": StepOut}
+
+// Handles requests until the client disconnects or the input ends
+func (s *DAPServer) Serve() error {
+	for {
+		body, err := readFrame(s.in)
+		if err == io.EOF {
+			s.stopProgram()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req dapMessage
+		if err := json.Unmarshal(body, &req); err != nil || req.Type != "request" {
+			continue
+		}
+		if req.Command == "disconnect" || req.Command == "terminate" {
+			s.stopProgram()
+			s.respond(req, nil, nil)
+			if req.Command == "terminate" {
+				s.event("terminated", nil)
+			}
+			return nil
+		}
+
+		handle, ok := dapRequests[req.Command]
+		if !ok {
+			s.respond(req, nil, fmt.Errorf("unsupported request %s", req.Command))
+			continue
+		}
+		result, err := handle(s, req.Arguments)
+		s.respond(req, result, err)
+
+		if req.Command == "initialize" {
+			s.event("initialized", nil)
+		}
+		if action, ok := resumeActions[req.Command]; ok && err == nil {
+			s.resumeWith(action)
+		}
+	}
+}
+
+func (s *DAPServer) send(msg *dapMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	msg.Seq = s.seq
+	data, _ := json.Marshal(msg)
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *DAPServer) respond(req dapMessage, body any, err error) {
+	success := err == nil
+	msg := &dapMessage{Type: "response", Command: req.Command, RequestSeq: req.Seq, Success: &success, Body: body}
+	if err != nil {
+		msg.Message = err.Error()
+	}
+	s.send(msg)
+}
+
+func (s *DAPServer) event(name string, body any) {
+	s.send(&dapMessage{Type: "event", Event: name, Body: body})
+}
+
+func (s *DAPServer) initialize(json.RawMessage) (any, error) {
+	return map[string]any{
+		"supportsConfigurationDoneRequest": true,
+		"supportsEvaluateForHovers":        true,
+		"supportsTerminateRequest":         true,
+	}, nil
+}
+
+func (s *DAPServer) launch(args json.RawMessage) (any, error) {
+	var launch struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(args, &launch); err != nil {
+		return nil, err
+	}
+	if launch.Program == "" {
+		return nil, fmt.Errorf("launch needs a program")
+	}
+	program, err := filepath.Abs(launch.Program)
+	if err != nil {
+		return nil, err
+	}
+	s.program = program
+	s.d.StopOnEntry = launch.StopOnEntry
+	return nil, nil
+}
+
+// Replaces the breakpoints of one file
+func (s *DAPServer) setBreakpoints(args json.RawMessage) (any, error) {
+	var set struct {
+		Source      source `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(args, &set); err != nil {
+		return nil, err
+	}
+
+	s.d.ClearBreakpoints(set.Source.Path)
+	breakpoints := []map[string]any{}
+	for _, bp := range set.Breakpoints {
+		s.d.SetBreakpoint(set.Source.Path, bp.Line)
+		breakpoints = append(breakpoints, map[string]any{"verified": true, "line": bp.Line})
+	}
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+// Starts the launched program once the client has sent its breakpoints
+func (s *DAPServer) configurationDone(json.RawMessage) (any, error) {
+	if s.program == "" {
+		return nil, fmt.Errorf("no program launched")
+	}
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+
+	go func() {
+		defer close(s.done)
+		opts := append(s.opts,
+			mscript.WithStdout(outputWriter{s, "stdout"}),
+			mscript.WithDebugHook(s.d.Hook),
+		)
+		exitCode := 0
+		if _, err := mscript.New(opts...).RunFile(s.program); err != nil {
+			exitCode = 1
+			if err.Error() != ErrStopped.Error() {
+				s.event("output", map[string]any{"category": "stderr", "output": err.Error() + "\n"})
+			}
+		}
+		s.event("exited", map[string]any{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+	return nil, nil
+}
+
+func (s *DAPServer) threads(json.RawMessage) (any, error) {
+	return map[string]any{"threads": []map[string]any{{"id": threadID, "name": "main"}}}, nil
+}
+
+// Frames are numbered from 0 for the innermost one, they are only valid until the program resumes
+func (s *DAPServer) stackTrace(json.RawMessage) (any, error) {
+	frames := s.pausedFrames()
+	stackFrames := []map[string]any{}
+	for i, f := range frames {
+		stackFrames = append(stackFrames, map[string]any{
+			"id":     i,
+			"name":   f.Name,
+			"source": source{Name: filepath.Base(f.File), Path: f.File},
+			"line":   f.Line,
+			"column": f.Column,
+		})
+	}
+	return map[string]any{"stackFrames": stackFrames, "totalFrames": len(frames)}, nil
+}
+
+func (s *DAPServer) frame(id int) (Frame, error) {
+	frames := s.pausedFrames()
+	if id < 0 || id >= len(frames) {
+		return Frame{}, fmt.Errorf("no frame %d", id)
+	}
+	return frames[id], nil
+}
+
+func (s *DAPServer) pausedFrames() []Frame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.frames
+}
+
+func (s *DAPServer) scopes(args json.RawMessage) (any, error) {
+	var req struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	frame, err := s.frame(req.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []map[string]any{}
+	for _, scope := range s.d.Scopes(frame) {
+		env := scope.Env
+		scopes = append(scopes, map[string]any{
+			"name":               scope.Name,
+			"variablesReference": s.reference(func() []variable { return s.envVariables(env) }),
+			"expensive":          false,
+		})
+	}
+	return map[string]any{"scopes": scopes}, nil
+}
+
+func (s *DAPServer) variables(args json.RawMessage) (any, error) {
+	var req struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	if req.VariablesReference < 1 || req.VariablesReference > len(s.refs) {
+		return nil, fmt.Errorf("no variables with reference %d", req.VariablesReference)
+	}
+	return map[string]any{"variables": s.refs[req.VariablesReference-1]()}, nil
+}
+
+// Remembers list and returns the reference the client asks for it with
+func (s *DAPServer) reference(list func() []variable) int {
+	s.refs = append(s.refs, list)
+	return len(s.refs)
+}
+
+func (s *DAPServer) envVariables(env *object.Environment) []variable {
+	vars := []variable{}
+	for _, name := range env.Names() {
+		value, _ := env.Get(name)
+		vars = append(vars, s.variable(name, value))
+	}
+	return vars
+}
+
+// Arrays and hashes can be expanded into their elements
+func (s *DAPServer) variable(name string, value object.Object) variable {
+	v := variable{Name: name, Value: summarize(value), Type: string(value.Type())}
+	switch value := value.(type) {
+	case *object.Array:
+		v.VariablesReference = s.reference(func() []variable {
+			vars := []variable{}
+			for i, el := range value.Elements {
+				vars = append(vars, s.variable(strconv.Itoa(i), el))
+			}
+			return vars
+		})
+	case *object.Hash:
+		v.VariablesReference = s.reference(func() []variable {
+			vars := []variable{}
+			for _, pair := range value.SortedPairs() {
+				vars = append(vars, s.variable(pair.Key.Inspect(), pair.Value))
+			}
+			return vars
+		})
+	}
+	return v
+}
+
+func (s *DAPServer) evaluate(args json.RawMessage) (any, error) {
+	var req struct {
+		Expression string `json:"expression"`
+		FrameID    *int   `json:"frameId"`
+	}
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+	id := 0
+	if req.FrameID != nil {
+		id = *req.FrameID
+	}
+	frame, err := s.frame(id)
+	if err != nil {
+		return nil, err
+	}
+	result := s.d.Eval(req.Expression, frame)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, fmt.Errorf("%s", errObj.Message)
+	}
+	v := s.variable("", result)
+	return map[string]any{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+}
+
+func (s *DAPServer) checkPaused(json.RawMessage) (any, error) {
+	if s.pausedFrames() == nil {
+		return nil, fmt.Errorf("the program is not paused")
+	}
+	return map[string]any{"allThreadsContinued": true}, nil
+}
+
+// Lets the paused program go on, after the response so events about it come later
+func (s *DAPServer) resumeWith(action Action) {
+	s.refs = nil
+	s.mu.Lock()
+	s.frames = nil
+	s.mu.Unlock()
+	s.resume <- action
+}
+
+func (s *DAPServer) pause(json.RawMessage) (any, error) {
+	s.d.Pause()
+	return nil, nil
+}
+
+// Called in the program's goroutine, waits until the client resumes it
+func (s *DAPServer) paused(reason string) Action {
+	s.mu.Lock()
+	s.frames = s.d.Frames()
+	s.mu.Unlock()
+	s.event("stopped", map[string]any{"reason": reason, "threadId": threadID, "allThreadsStopped": true})
+	return <-s.resume
+}
+
+// Ends a running program and waits for it
+func (s *DAPServer) stopProgram() {
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if !running {
+		return
+	}
+	s.d.Stop()
+	//The program may be paused or pause before it sees the stop
+	for {
+		select {
+		case <-s.done:
+			return
+		case s.resume <- Stop:
+		}
+	}
+}
+
+// Sends what the program prints to the client as output events
+type outputWriter struct {
+	s        *DAPServer
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]any{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
+
+// Reads one message framed by a Content-Length header
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
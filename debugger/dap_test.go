@@ -0,0 +1,189 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+type dapClient struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan map[string]any
+	seq      int
+	errc     chan error
+}
+
+func startDAP(t *testing.T) *dapClient {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &dapClient{t: t, in: inW, messages: make(chan map[string]any, 100), errc: make(chan error, 1)}
+	go func() {
+		c.errc <- NewDAPServer(inR, outW).Serve()
+		outW.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			body, err := readFrame(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg map[string]any
+			json.Unmarshal(body, &msg)
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *dapClient) send(command string, args any) {
+	c.seq++
+	data, _ := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+// Waits for the response to command or the event named event, skipping output events
+func (c *dapClient) next(kind, name string) map[string]any {
+	c.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("server closed while waiting for %s %s", kind, name)
+			}
+			if msg["type"] == kind && (msg["command"] == name || msg["event"] == name) {
+				return msg
+			}
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %s %s", kind, name)
+		}
+	}
+}
+
+// Sends a request and returns the body of its successful response
+func (c *dapClient) request(command string, args any) map[string]any {
+	c.t.Helper()
+	c.send(command, args)
+	resp := c.next("response", command)
+	if resp["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, resp["message"])
+	}
+	body, _ := resp["body"].(map[string]any)
+	return body
+}
+
+func TestDAPSession(t *testing.T) {
+	path := writeProgram(t, program)
+	c := startDAP(t)
+
+	caps := c.request("initialize", map[string]any{"adapterID": "mscript"})
+	if caps["supportsConfigurationDoneRequest"] != true {
+		t.Errorf("wrong capabilities: %v", caps)
+	}
+	c.next("event", "initialized")
+	c.request("launch", map[string]any{"program": path})
+	bps := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []map[string]any{{"line": 3}},
+	})
+	if fmt.Sprint(bps["breakpoints"]) != "[map[line:3 verified:true]]" {
+		t.Errorf("wrong breakpoints: %v", bps)
+	}
+	c.request("configurationDone", nil)
+
+	stopped := c.next("event", "stopped")["body"].(map[string]any)
+	if stopped["reason"] != "breakpoint" {
+		t.Errorf("stopped for %v", stopped["reason"])
+	}
+
+	trace := c.request("stackTrace", map[string]any{"threadId": 1})
+	frames := trace["stackFrames"].([]any)
+	top := frames[0].(map[string]any)
+	if len(frames) != 2 || top["name"] != "add" || top["line"] != float64(3) {
+		t.Errorf("wrong stack: %v", frames)
+	}
+
+	scopes := c.request("scopes", map[string]any{"frameId": 0})["scopes"].([]any)
+	locals := scopes[0].(map[string]any)
+	vars := c.request("variables", map[string]any{"variablesReference": locals["variablesReference"]})["variables"].([]any)
+	var got []string
+	for _, v := range vars {
+		v := v.(map[string]any)
+		got = append(got, fmt.Sprintf("%s=%s", v["name"], v["value"]))
+	}
+	if fmt.Sprint(got) != "[a=1 b=2 sum=3]" {
+		t.Errorf("wrong locals: %v", got)
+	}
+
+	result := c.request("evaluate", map[string]any{"expression": "[sum, a]", "frameId": 0})
+	if result["result"] != "[3, 1]" || result["variablesReference"] == float64(0) {
+		t.Errorf("wrong evaluation: %v", result)
+	}
+	elements := c.request("variables", map[string]any{"variablesReference": result["variablesReference"]})["variables"].([]any)
+	if len(elements) != 2 {
+		t.Errorf("wrong elements: %v", elements)
+	}
+
+	c.request("stepOut", map[string]any{"threadId": 1})
+	c.next("event", "stopped")
+	top = c.request("stackTrace", map[string]any{"threadId": 1})["stackFrames"].([]any)[0].(map[string]any)
+	if top["name"] != "main" || top["line"] != float64(6) {
+		t.Errorf("stepped out to %v", top)
+	}
+
+	c.request("setBreakpoints", map[string]any{"source": map[string]any{"path": path}, "breakpoints": []any{}})
+	c.request("continue", map[string]any{"threadId": 1})
+	output := c.next("event", "output")["body"].(map[string]any)
+	if output["output"] != "13\n" {
+		t.Errorf("wrong output: %v", output)
+	}
+	exited := c.next("event", "exited")["body"].(map[string]any)
+	if exited["exitCode"] != float64(0) {
+		t.Errorf("exited with %v", exited["exitCode"])
+	}
+	c.next("event", "terminated")
+
+	c.request("disconnect", nil)
+	if err := <-c.errc; err != nil {
+		t.Errorf("Serve: %s", err)
+	}
+}
+
+func TestDAPDisconnectWhilePaused(t *testing.T) {
+	path := writeProgram(t, program)
+	c := startDAP(t)
+	c.request("initialize", nil)
+	c.request("launch", map[string]any{"program": path, "stopOnEntry": true})
+	c.request("configurationDone", nil)
+	if reason := c.next("event", "stopped")["body"].(map[string]any)["reason"]; reason != "entry" {
+		t.Errorf("stopped for %v", reason)
+	}
+
+	c.send("next", map[string]any{"threadId": 1})
+	c.send("evaluate", map[string]any{"expression": "x"})
+	c.request("disconnect", nil)
+	if err := <-c.errc; err != nil {
+		t.Errorf("Serve: %s", err)
+	}
+}
+
+func TestDAPErrors(t *testing.T) {
+	c := startDAP(t)
+	c.request("initialize", nil)
+	for _, command := range []string{"configurationDone", "continue", "stackTrace", "bogus"} {
+		c.send(command, nil)
+		if resp := c.next("response", command); command != "stackTrace" && resp["success"] != false {
+			t.Errorf("%s succeeded: %v", command, resp)
+		}
+	}
+	c.in.Close()
+	if err := <-c.errc; err != nil {
+		t.Errorf("Serve: %s", err)
+	}
+}
@@ -0,0 +1,301 @@
+// Package debugger pauses the tree walker before statements so a program can be stepped through,
+// from a terminal with Console or from an editor with the Debug Adapter Protocol server.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"mscript/analysis"
+	"mscript/ast"
+	"mscript/evaluator"
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Returned by Hook when the debugger stops the program
+var ErrStopped = errors.New("execution stopped by the debugger")
+
+// What a paused program does next
+type Action int
+
+const (
+	Continue Action = iota //Run until the next breakpoint
+	StepIn                 //Pause before the next statement, inside called functions too
+	StepOver               //Pause before the next statement of this function or its callers
+	StepOut                //Pause once the current function returned
+	Stop                   //End the program with ErrStopped
+)
+
+// Why a program paused
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// A function being run, Line and Column are those of its current statement
+type Frame struct {
+	Name   string
+	File   string
+	Line   int
+	Column int
+	Env    *object.Environment
+}
+
+// The bindings of one environment of a frame
+type Scope struct {
+	Name string //Locals, Closure or Globals
+	Env  *object.Environment
+}
+
+type Debugger struct {
+	//Called in the program's goroutine each time it pauses, the frames are in d.Frames
+	//The program waits for it and goes on as the returned action says
+	OnPause func(reason string) Action
+
+	//Pause before the first statement
+	StopOnEntry bool
+
+	mu          sync.Mutex              //Guards breakpoints, they may be changed while the program runs
+	breakpoints map[string]map[int]bool //Lines by absolute file path
+	action      Action
+	depth       int     //Call depth when the last step started
+	frames      []Frame //Outermost first
+	last        Frame   //The previous statement, a line only hits its breakpoint once
+	started     bool
+	evaluating  bool //Set while watches are evaluated, their statements do not pause
+	pause       atomic.Bool
+	stop        atomic.Bool
+
+	files map[string]*sourceFile
+}
+
+type sourceFile struct {
+	lines []string
+	info  *analysis.Info //nil when the file does not parse
+}
+
+func New() *Debugger {
+	return &Debugger{breakpoints: map[string]map[int]bool{}, files: map[string]*sourceFile{}}
+}
+
+// Adds a breakpoint before the statements starting on line of file
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	file = cleanPath(file)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.breakpoints[file] == nil {
+		d.breakpoints[file] = map[int]bool{}
+	}
+	d.breakpoints[file][line] = true
+}
+
+// Removes a breakpoint, reports whether there was one
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	file = cleanPath(file)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.breakpoints[file][line] {
+		return false
+	}
+	delete(d.breakpoints[file], line)
+	return true
+}
+
+// Removes every breakpoint in file
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, cleanPath(file))
+}
+
+// Reports whether there is a breakpoint on line of file
+func (d *Debugger) HasBreakpoint(file string, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.breakpoints[cleanPath(file)][line]
+}
+
+// Returns the lines with breakpoints by file
+func (d *Debugger) Breakpoints() map[string][]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := map[string][]int{}
+	for file, lines := range d.breakpoints {
+		for line := range lines {
+			result[file] = append(result[file], line)
+		}
+	}
+	return result
+}
+
+// Asks a running program to pause before its next statement, safe to call from any goroutine
+func (d *Debugger) Pause() {
+	d.pause.Store(true)
+}
+
+// Asks a running program to stop before its next statement, safe to call from any goroutine
+func (d *Debugger) Stop() {
+	d.stop.Store(true)
+}
+
+// The frames of the paused program, innermost first
+func (d *Debugger) Frames() []Frame {
+	frames := make([]Frame, len(d.frames))
+	for i, f := range d.frames {
+		frames[len(frames)-1-i] = f
+	}
+	return frames
+}
+
+// Pass to mscript.WithDebugHook or set as object.Runtime.Debug
+func (d *Debugger) Hook(stmt ast.Statement, env *object.Environment) error {
+	if d.evaluating {
+		return nil
+	}
+	if d.stop.Load() {
+		return ErrStopped
+	}
+
+	depth := env.Runtime().Depth()
+	pos := analysis.StatementPos(stmt)
+	frame := Frame{File: env.File(), Line: pos.Line, Column: pos.Column, Env: env}
+	frame.Name = d.functionName(frame)
+	for len(d.frames) < depth {
+		d.frames = append(d.frames, Frame{Name: "?"})
+	}
+	d.frames = append(d.frames[:depth], frame)
+
+	reason := ""
+	switch {
+	case !d.started:
+		d.started = true
+		if d.StopOnEntry {
+			reason = ReasonEntry
+		}
+	case d.pause.Swap(false):
+		reason = ReasonPause
+	case d.action == StepIn,
+		d.action == StepOver && depth <= d.depth,
+		d.action == StepOut && depth < d.depth:
+		reason = ReasonStep
+	}
+	sameLine := d.last.File == frame.File && d.last.Line == frame.Line && d.last.Env == frame.Env
+	if reason == "" && !sameLine && d.HasBreakpoint(frame.File, frame.Line) {
+		reason = ReasonBreakpoint
+	}
+	d.last = frame
+	if reason == "" || d.OnPause == nil {
+		return nil
+	}
+
+	d.action, d.depth = d.OnPause(reason), depth
+	if d.action == Stop {
+		return ErrStopped
+	}
+	return nil
+}
+
+// The environments visible from frame, innermost first
+func (d *Debugger) Scopes(frame Frame) []Scope {
+	var scopes []Scope
+	for env := frame.Env; env != nil; env = env.Outer() {
+		name := "Closure"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case env == frame.Env:
+			name = "Locals"
+		}
+		scopes = append(scopes, Scope{Name: name, Env: env})
+	}
+	return scopes
+}
+
+// Evaluates src in frame, bindings it makes are dropped afterwards
+// Statements it runs do not pause or hit breakpoints
+func (d *Debugger) Eval(src string, frame Frame) object.Object {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return &object.Error{Message: strings.Join(p.Errors(), "; ")}
+	}
+	if frame.Env == nil {
+		return &object.Error{Message: "no frame to evaluate in"}
+	}
+
+	d.evaluating = true
+	defer func() { d.evaluating = false }()
+	result := evaluator.Eval(program, object.NewEnclosedEnvironment(frame.Env))
+	if result == nil {
+		return object.NULL
+	}
+	return result
+}
+
+// Returns line of file, "" when it cannot be read
+func (d *Debugger) SourceLine(file string, line int) string {
+	src := d.source(file)
+	if src == nil || line < 1 || line > len(src.lines) {
+		return ""
+	}
+	return src.lines[line-1]
+}
+
+// Names frame after the let its function is bound to
+func (d *Debugger) functionName(frame Frame) string {
+	src := d.source(frame.File)
+	if src == nil || src.info == nil {
+		return "fn"
+	}
+	scope := src.info.ScopeAt(analysis.Position{Line: frame.Line, Column: frame.Column})
+	if scope.Parent == nil {
+		if frame.File == "" {
+			return "main"
+		}
+		return strings.TrimSuffix(filepath.Base(frame.File), filepath.Ext(frame.File))
+	}
+	for _, b := range scope.Parent.Bindings {
+		if b.Value == scope.Node {
+			return b.Name
+		}
+	}
+	return fmt.Sprintf("fn:%d", scope.Start.Line)
+}
+
+// Reads and resolves file the first time it is needed
+func (d *Debugger) source(file string) *sourceFile {
+	if file == "" {
+		return nil
+	}
+	if src, ok := d.files[file]; ok {
+		return src
+	}
+	var src *sourceFile
+	if data, err := os.ReadFile(file); err == nil {
+		src = &sourceFile{lines: strings.Split(string(data), "\n")}
+		p := parser.New(lexer.New(string(data)))
+		if program := p.ParseProgram(); len(p.Errors()) == 0 {
+			src.info = analysis.Resolve(program)
+		}
+	}
+	d.files[file] = src
+	return src
+}
+
+func cleanPath(file string) string {
+	if file == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
@@ -0,0 +1,145 @@
+package debugger
+
+import (
+	"fmt"
+	"io"
+	"mscript"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let y = add(x, 10);
+puts(y);
+`
+
+func writeProgram(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.ms")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Runs path under d answering each pause with the next of actions
+// Returns where each pause happened as "reason name:line"
+func debug(t *testing.T, d *Debugger, path string, actions ...Action) ([]string, error) {
+	t.Helper()
+	var pauses []string
+	d.OnPause = func(reason string) Action {
+		frame := d.Frames()[0]
+		pauses = append(pauses, fmt.Sprintf("%s %s:%d", reason, frame.Name, frame.Line))
+		if len(actions) == 0 {
+			return Continue
+		}
+		action := actions[0]
+		actions = actions[1:]
+		return action
+	}
+	_, err := mscript.New(mscript.WithStdout(io.Discard), mscript.WithDebugHook(d.Hook)).RunFile(path)
+	return pauses, err
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		actions     []Action
+		expected    []string
+	}{
+		{"breakpoints", []int{2, 7}, nil, []string{"breakpoint add:2", "breakpoint add:2", "breakpoint main:7"}},
+		{"step in", []int{5}, []Action{StepIn, StepIn, StepIn, Continue},
+			[]string{"breakpoint main:5", "step add:2", "step add:3", "step main:6"}},
+		{"step over", []int{5}, []Action{StepOver, StepOver, Continue},
+			[]string{"breakpoint main:5", "step main:6", "step main:7"}},
+		{"step out", []int{2}, []Action{StepOut, Continue},
+			[]string{"breakpoint add:2", "step main:6", "breakpoint add:2"}},
+		{"breakpoint while stepping over", []int{2, 5}, []Action{StepOver, Continue},
+			[]string{"breakpoint main:5", "breakpoint add:2", "breakpoint add:2"}},
+	}
+
+	path := writeProgram(t, program)
+	for _, tt := range tests {
+		d := New()
+		for _, line := range tt.breakpoints {
+			d.SetBreakpoint(path, line)
+		}
+		pauses, err := debug(t, d, path, tt.actions...)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if strings.Join(pauses, ", ") != strings.Join(tt.expected, ", ") {
+			t.Errorf("%s: wrong pauses.\ngot= %v\nwant=%v", tt.name, pauses, tt.expected)
+		}
+	}
+}
+
+func TestStopOnEntryAndStop(t *testing.T) {
+	d := New()
+	d.StopOnEntry = true
+	pauses, err := debug(t, d, writeProgram(t, program), StepOver, Stop)
+	if err == nil || err.Error() != ErrStopped.Error() {
+		t.Errorf("wrong error: %v", err)
+	}
+	if strings.Join(pauses, ", ") != "entry main:1, step main:5" {
+		t.Errorf("wrong pauses: %v", pauses)
+	}
+}
+
+func TestFramesScopesAndEval(t *testing.T) {
+	path := writeProgram(t, program)
+	d := New()
+	d.SetBreakpoint(path, 3)
+
+	var frames []Frame
+	var scopes []string
+	var values []string
+	d.OnPause = func(string) Action {
+		frames = d.Frames()
+		for _, s := range d.Scopes(frames[0]) {
+			scopes = append(scopes, s.Name+" "+strings.Join(s.Env.Names(), " "))
+		}
+		values = append(values, d.Eval("sum * 2", frames[0]).Inspect(), d.Eval("let sum = 0; sum", frames[0]).Inspect(),
+			d.Eval("sum", frames[0]).Inspect(), d.Eval("a", frames[1]).Inspect(), d.Eval("let", frames[0]).Inspect())
+		return Stop
+	}
+	mscript.New(mscript.WithStdout(io.Discard), mscript.WithDebugHook(d.Hook)).RunFile(path)
+
+	if len(frames) != 2 || frames[0].Name != "add" || frames[0].Line != 3 || frames[1].Name != "main" || frames[1].Line != 5 {
+		t.Errorf("wrong frames: %+v", frames)
+	}
+	if strings.Join(scopes, ", ") != "Locals a b sum, Globals add" {
+		t.Errorf("wrong scopes: %v", scopes)
+	}
+	expected := []string{"6", "0", "3", "ERROR: identifier not found: a", "ERROR: expected next token to be IDENT, got  instead"}
+	if strings.Join(values, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("wrong values.\ngot= %v\nwant=%v", values, expected)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := New()
+	d.SetBreakpoint("a.ms", 3)
+	d.SetBreakpoint("a.ms", 1)
+	abs, _ := filepath.Abs("a.ms")
+	if !d.HasBreakpoint(abs, 3) || d.HasBreakpoint("a.ms", 2) {
+		t.Errorf("breakpoints not found by path")
+	}
+	if !d.ClearBreakpoint("a.ms", 3) || d.ClearBreakpoint("a.ms", 3) {
+		t.Errorf("ClearBreakpoint did not report what it removed")
+	}
+	if got := d.Breakpoints(); len(got) != 1 || len(got[abs]) != 1 || got[abs][0] != 1 {
+		t.Errorf("Breakpoints() = %v", got)
+	}
+	d.ClearBreakpoints("a.ms")
+	if len(d.Breakpoints()) != 0 {
+		t.Errorf("ClearBreakpoints left %v", d.Breakpoints())
+	}
+}
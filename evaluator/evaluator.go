@@ -7,9 +7,9 @@ import (
 )
 
 var (
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
-	NULL  = &object.Null{}
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+	NULL  = object.NULL
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
@@ -26,6 +26,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
@@ -51,7 +54,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIfExpression(node, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		var val object.Object
+		if call, ok := node.ReturnValue.(*ast.CallExpression); ok {
+			//Returning ends the function, so the call is made after it by applyFunction
+			val = evalCall(call, env)
+		} else {
+			val = Eval(node.ReturnValue, env)
+		}
 		if isError(val) {
 			return val
 		}
@@ -62,6 +71,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if node.Type != nil && env.Runtime().Strict && !conforms(val, node.Type) {
+			return newError("cannot use %s as %s in let %s", typeName(val), node.Type, node.Name.Value)
+		}
 		env.Set(node.Name.Value, val)
 
 	case *ast.Identifier:
@@ -70,34 +82,74 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Env: env, Body: body, ParameterTypes: node.ParameterTypes, ReturnType: node.ReturnType}
 
-	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+	case *ast.MacroLiteral:
+		return newError("macros can only be defined by top level let statements")
 
-			return args[0]
+	case *ast.CallExpression:
+		result := evalCall(node, env)
+		if call, ok := result.(*tailCall); ok {
+			return applyFunction(call.fn, call.args, env.Runtime())
 		}
-		return applyFunction(function, args)
+		return result
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Property.Value)
 	}
 
 	return nil
 }
 
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	rt := env.Runtime()
+	rt.Call = func(fn object.Object, args ...object.Object) object.Object {
+		return applyFunction(fn, args, rt)
+	}
+
 	var result object.Object
 	for _, statement := range program.Statements {
+		if err := beforeStatement(statement, env); err != nil {
+			return err
+		}
 		result = Eval(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
-			return result.Value
+			return finishTailCall(result.Value, env.Runtime())
 		case *object.Error:
 			return result
 		}
@@ -105,9 +157,26 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
+// Counts a step and lets a debugger pause before stmt runs
+func beforeStatement(stmt ast.Statement, env *object.Environment) *object.Error {
+	rt := env.Runtime()
+	if err := rt.Step(); err != nil {
+		return newError("%s", err)
+	}
+	if rt.Debug != nil {
+		if err := rt.Debug(stmt, env); err != nil {
+			return newError("%s", err)
+		}
+	}
+	return nil
+}
+
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
+		if err := beforeStatement(statement, env); err != nil {
+			return err
+		}
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
@@ -151,6 +220,9 @@ func evalBangOperator(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if float, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -float.Value}
+	}
 	if right.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: -%s", right.Type())
 	}
@@ -163,6 +235,8 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -189,6 +263,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -204,26 +281,77 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 
 }
 
+// Arithmetic with a float operand, an integer operand is converted to a float first
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := floatValue(left)
+	rightVal := floatValue(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func floatValue(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return orNull(Eval(ie.Consequence, env))
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return orNull(Eval(ie.Alternative, env))
 	} else {
 		return NULL
 	}
 }
 
+// Blocks ending in a let or empty ones have no value, expressions using them get null
+func orNull(obj object.Object) object.Object {
+	if obj == nil {
+		return NULL
+	}
+	return obj
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	val, ok := env.Get(node.Value)
-	if !ok {
-		return newError("identifier not found: " + node.Value)
+	if val, ok := env.Get(node.Value); ok {
+		return val
 	}
-	return val
+	if builtin := object.GetBuiltinByName(node.Value); builtin != nil {
+		return builtin
+	}
+	return newError("identifier not found: " + node.Value)
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -249,14 +377,160 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	return &object.String{Value: leftVal + rightVal}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	function, ok := fn.(*object.Function)
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, keyNode := range node.Keys {
+		key := Eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(node.Pairs[keyNode], env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// Indexes outside of the array evaluate to null
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 || idx >= int64(len(elements)) {
+		return NULL
+	}
+	return elements[idx]
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
 	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hash.(*object.Hash).Get(key)
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	importer := env.Runtime().Importer
+	if importer == nil {
+		return newError("cannot import %q: no module loader configured", node.Path)
+	}
+	module, err := importer.Import(node.Path, env)
+	if err != nil {
+		return newError("%s", err)
+	}
+	env.Set(node.Name.Value, module)
+	return nil
+}
+
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return newError("member access not supported: %s.%s", obj.Type(), name)
+	}
+	member, ok := module.Exports[name]
+	if !ok {
+		return newError("module %s has no exported member %s", module.Name, name)
+	}
+	return member
+}
+
+// Calls a function or builtin with already evaluated arguments
+// Used by hosts to call back into functions defined by a script, the call runs with the runtime
+// of env like Eval, so builtins write to its output and the call counts against its limits
+func Apply(env *object.Environment, fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, env.Runtime())
+}
+
+func applyFunction(fn object.Object, args []object.Object, rt *object.Runtime) object.Object {
+	//Functions whose result is the result of the call being made, innermost last
+	var callers []*object.Function
+	for {
+		result := call(fn, args, rt)
+		if function, ok := fn.(*object.Function); ok && rt.Strict && function.ReturnType != nil {
+			//A function calling itself only needs checking once
+			if len(callers) == 0 || callers[len(callers)-1] != function {
+				callers = append(callers, function)
+			}
+		}
+		tc, ok := result.(*tailCall)
+		if !ok {
+			return checkResults(callers, result)
+		}
+		//The caller is done, so its call is replaced instead of nested
+		fn, args = tc.fn, tc.args
+	}
+}
+
+// Calls fn once, a call in tail position of its body is returned as a *tailCall instead of made
+func call(fn object.Object, args []object.Object, rt *object.Runtime) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		if len(args) != len(function.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+		}
+		if rt.Strict {
+			if err := checkArguments(function, args); err != nil {
+				return err
+			}
+		}
+		if err := rt.Enter(); err != nil {
+			return newError("%s", err)
+		}
+		defer rt.Leave()
+
+		extendedEnv := extendFunctionEnv(function, args)
+		return orNull(evalTailBlock(function.Body, extendedEnv))
+
+	case *object.Builtin:
+		if result := function.Fn(rt, args...); result != nil {
+			return result
+		}
+		return NULL
+
+	default:
 		return newError("not a function: %s", fn.Type())
 	}
-	extendedEnv := extendFunctionEnv(function, args)
-	evaluated := Eval(function.Body, extendedEnv)
-	return unwrapReturnValue(evaluated)
+}
+
+// Checks result against the annotated results of callers, innermost first, see applyFunction
+func checkResults(callers []*object.Function, result object.Object) object.Object {
+	if isError(result) {
+		return result
+	}
+	for i := len(callers) - 1; i >= 0; i-- {
+		if !conforms(result, callers[i].ReturnType) {
+			return newError("cannot use %s as %s in return", typeName(result), callers[i].ReturnType)
+		}
+	}
+	return result
 }
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
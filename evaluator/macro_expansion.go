@@ -0,0 +1,114 @@
+package evaluator
+
+import (
+	"fmt"
+	"mscript/ast"
+	"mscript/object"
+)
+
+// How often the result of a macro may expand into another macro call
+const MaxMacroDepth = 100
+
+// Removes the top level `let name = macro(...) {...};` statements from program
+// and binds the macros they define in env
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := program.Statements[:0]
+	for _, statement := range program.Statements {
+		if !isMacroDefinition(statement) {
+			statements = append(statements, statement)
+			continue
+		}
+		let := statement.(*ast.LetStatement)
+		lit := let.Value.(*ast.MacroLiteral)
+		env.Set(let.Name.Value, &object.Macro{Parameters: lit.Parameters, Body: lit.Body, Env: env})
+	}
+	program.Statements = statements
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	let, ok := node.(*ast.LetStatement)
+	if !ok {
+		return false
+	}
+	_, ok = let.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+// Replaces every call of a macro bound in env with the code the macro returns
+// The arguments are passed to the macro unevaluated as quotes
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	return expandMacros(program, env, 0)
+}
+
+func expandMacros(program ast.Node, env *object.Environment, depth int) (ast.Node, error) {
+	var err error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		if err != nil {
+			return node
+		}
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+		macro, ok := isMacroCall(call, env)
+		if !ok {
+			return node
+		}
+		if depth >= MaxMacroDepth {
+			err = fmt.Errorf("macro expansion deeper than %d", MaxMacroDepth)
+			return node
+		}
+
+		var result ast.Node
+		if result, err = applyMacro(macro, call); err != nil {
+			return node
+		}
+		if result, err = expandMacros(result, env, depth+1); err != nil {
+			return node
+		}
+		return result
+	})
+	return expanded, err
+}
+
+func isMacroCall(exp *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := exp.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func applyMacro(macro *object.Macro, call *ast.CallExpression) (ast.Node, error) {
+	if len(call.Arguments) != len(macro.Parameters) {
+		return nil, fmt.Errorf("wrong number of arguments to macro %s: want=%d, got=%d",
+			call.Function, len(macro.Parameters), len(call.Arguments))
+	}
+
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+
+	evaluated := finishTailCall(unwrapReturnValue(Eval(macro.Body, env)), env.Runtime())
+	if errObj, ok := evaluated.(*object.Error); ok {
+		return nil, fmt.Errorf("error expanding macro %s: %s", call.Function, errObj.Message)
+	}
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		return nil, fmt.Errorf("macro %s must return a quote, got %s", call.Function, typeOf(evaluated))
+	}
+	return quote.Node, nil
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
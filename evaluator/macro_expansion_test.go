@@ -0,0 +1,196 @@
+package evaluator
+
+import (
+	"mscript/ast"
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/parser"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(t, input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameters wrong. got=%v", macro.Parameters)
+	}
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("body is not %q. got=%q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); };
+			infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+			reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`let twice = macro(x) { quote(unquote(x) + unquote(x)) };
+			let quad = macro(x) { quote(twice(unquote(x)) * 2) };
+			quad(n);`,
+			`(n + n) * 2`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(t, tt.expected)
+		program := testParseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("expansion error: %s", err)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let m = macro(a) { quote(a) }; m();`,
+			"wrong number of arguments to macro m: want=1, got=0",
+		},
+		{
+			`let m = macro() { 1 }; m();`,
+			"macro m must return a quote, got INTEGER",
+		},
+		{
+			`let m = macro() { missing }; m();`,
+			"error expanding macro m: identifier not found: missing",
+		},
+		{
+			`let m = macro() { quote(m()) }; m();`,
+			"macro expansion deeper than 100",
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(t, tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected error for %q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestMacroHygiene(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// The macro's tmp must not overwrite the caller's
+		{
+			`let double = macro(x) { quote(if (true) { let tmp = unquote(x); tmp + tmp }) };
+			let tmp = 10;
+			let r = double(tmp + 1);
+			r * 100 + tmp`,
+			2210,
+		},
+		// The macro's parameter must not capture the caller's x
+		{
+			`let withOne = macro(body) { quote(fn(x) { unquote(body) }(1)) };
+			let x = 5;
+			withOne(x)`,
+			5,
+		},
+		// Expanding twice gives two independent bindings
+		{
+			`let inc = macro(v) { quote(if (true) { let n = unquote(v); n + 1 }) };
+			inc(inc(1))`,
+			3,
+		},
+		// The free x refers to the caller's, only the parameter x is renamed
+		{
+			`let plusDouble = macro(v) { quote(x + fn(x) { x * 2 }(unquote(v))) };
+			let x = 10;
+			plusDouble(3)`,
+			16,
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(t, tt.input)
+		macros := object.NewEnvironment()
+		DefineMacros(program, macros)
+		expanded, err := ExpandMacros(program, macros)
+		if err != nil {
+			t.Fatalf("expansion error: %s", err)
+		}
+		testIntegerObject(t, Eval(expanded, object.NewEnvironment()), tt.expected)
+	}
+}
+
+func testParseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
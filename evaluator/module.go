@@ -0,0 +1,172 @@
+package evaluator
+
+import (
+	"fmt"
+	"mscript/ast"
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variable holding extra module directories, separated like PATH
+const SearchPathEnv = "MSCRIPT_PATH"
+
+// ModuleLoader resolves import paths to files, evaluates each file once and caches the result
+//
+// Scripts may import files in the directory of the file doing the top level imports and in
+// the search path, other files only when the runtime grants reading them
+type ModuleLoader struct {
+	SearchPath []string
+
+	modules map[string]*object.Module //Loaded modules keyed by absolute path
+	loading []string                  //Modules currently being evaluated, used to find cycles
+	root    string                    //Directory of the file doing the top level imports
+}
+
+func NewModuleLoader(searchPath ...string) *ModuleLoader {
+	return &ModuleLoader{
+		SearchPath: searchPath,
+		modules:    make(map[string]*object.Module),
+	}
+}
+
+// Returns the directories listed in $MSCRIPT_PATH
+func DefaultSearchPath() []string {
+	value := os.Getenv(SearchPathEnv)
+	if value == "" {
+		return nil
+	}
+	return filepath.SplitList(value)
+}
+
+// Loads the module at path imported from the environment of another module or the REPL
+// The module is evaluated in the same runtime as the importing environment
+func (ml *ModuleLoader) Import(path string, from *object.Environment) (*object.Module, error) {
+	if len(ml.loading) == 0 {
+		ml.root = dirOf(from.File())
+	}
+	allowed := object.Paths{Dirs: append([]string{ml.root}, ml.SearchPath...)}
+	read := from.Runtime().Capabilities.Read
+	file, err := ml.resolve(path, from.File(), func(candidate string) bool {
+		return allowed.Allows(candidate) || read.Allows(candidate)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if module, ok := ml.modules[file]; ok {
+		return module, nil
+	}
+
+	//Importing a module that has not finished loading means we went in a circle
+	for i, loading := range ml.loading {
+		if loading == file {
+			cycle := append(append([]string{}, ml.loading[i:]...), file)
+			return nil, fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	ml.loading = append(ml.loading, file)
+	defer func() { ml.loading = ml.loading[:len(ml.loading)-1] }()
+
+	module, err := ml.load(file, from.Runtime())
+	if err != nil {
+		return nil, err
+	}
+	ml.modules[file] = module
+	return module, nil
+}
+
+// Finds the file for an import path written in the file from, which may be empty
+// Relative paths are tried next to the importing file first then in each search path directory
+func (ml *ModuleLoader) Resolve(path string, from string) (string, error) {
+	return ml.resolve(path, from, nil)
+}
+
+// Like Resolve but only looks at the candidates allowed reports true for, nil allows all
+// Candidates that are not allowed are never touched so whether they exist stays hidden
+func (ml *ModuleLoader) resolve(path string, from string, allowed func(string) bool) (string, error) {
+	var candidates []string
+	if filepath.IsAbs(path) {
+		candidates = append(candidates, path)
+	} else {
+		dir := dirOf(from)
+		candidates = append(candidates, filepath.Join(dir, path))
+		for _, dir := range ml.SearchPath {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+	}
+
+	denied := false
+	for _, candidate := range candidates {
+		if allowed != nil && !allowed(candidate) {
+			denied = true
+			continue
+		}
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		return filepath.Abs(candidate)
+	}
+	if denied {
+		return "", fmt.Errorf("import %q: permission denied, needs --allow-read", path)
+	}
+	return "", fmt.Errorf("module not found: %q", path)
+}
+
+// Directory relative imports in file start from, the working directory for code without a file
+func dirOf(file string) string {
+	if file == "" {
+		return "."
+	}
+	return filepath.Dir(file)
+}
+
+// Parses and evaluates file in a fresh environment and collects its exports
+func (ml *ModuleLoader) load(file string, rt *object.Runtime) (*object.Module, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parse errors in %s:\n\t%s", file, strings.Join(p.Errors(), "\n\t"))
+	}
+
+	macros := object.NewModuleEnvironment(file, rt)
+	DefineMacros(program, macros)
+	expanded, err := ExpandMacros(program, macros)
+	if err != nil {
+		return nil, fmt.Errorf("error in module %s: %s", file, err)
+	}
+	program = expanded.(*ast.Program)
+
+	env := object.NewModuleEnvironment(file, rt)
+	if result := Eval(program, env); isError(result) {
+		return nil, fmt.Errorf("error in module %s: %s", file, result.(*object.Error).Message)
+	}
+
+	base := filepath.Base(file)
+	module := &object.Module{
+		Name:    strings.TrimSuffix(base, filepath.Ext(base)),
+		Path:    file,
+		Exports: make(map[string]object.Object),
+	}
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		name := export.Statement.Name.Value
+		if val, ok := env.Get(name); ok {
+			module.Exports[name] = val
+		}
+	}
+	return module, nil
+}
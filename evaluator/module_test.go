@@ -0,0 +1,154 @@
+package evaluator
+
+import (
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testEvalFile(t *testing.T, file string, loader *ModuleLoader) object.Object {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	rt := object.NewRuntime()
+	rt.Importer = loader
+	return Eval(program, object.NewModuleEnvironment(file, rt))
+}
+
+func TestImportExport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.ms":        `import "lib/math.ms" as m; m.double(m.base)`,
+		"lib/math.ms":    `import "helpers.ms" as h; export let base = h.five; export let double = fn(x) { x * 2 }; let hidden = 1;`,
+		"lib/helpers.ms": `export let five = 5;`,
+	})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.ms"), NewModuleLoader())
+	testIntegerObject(t, evaluated, 10)
+}
+
+func TestImportUnexportedMember(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.ms": `import "lib.ms"; lib.hidden`,
+		"lib.ms":  `let hidden = 1;`,
+	})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.ms"), NewModuleLoader())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "module lib has no exported member hidden" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestImportSearchPath(t *testing.T) {
+	libs := writeModules(t, map[string]string{"util.ms": `export let answer = 42;`})
+	dir := writeModules(t, map[string]string{"main.ms": `import "util.ms" as u; u.answer`})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.ms"), NewModuleLoader(libs))
+	testIntegerObject(t, evaluated, 42)
+
+	evaluated = testEvalFile(t, filepath.Join(dir, "main.ms"), NewModuleLoader())
+	if _, ok := evaluated.(*object.Error); !ok {
+		t.Errorf("expected module not found error. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
+func TestImportEvaluatedOnce(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.ms":   `import "a.ms"; import "b.ms"; a.counter == b.counter`,
+		"a.ms":      `import "shared.ms"; export let counter = shared.counter;`,
+		"b.ms":      `import "shared.ms"; export let counter = shared.counter;`,
+		"shared.ms": `export let counter = fn() { 1 };`,
+	})
+
+	loader := NewModuleLoader()
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.ms"), loader)
+	testBooleanObject(t, evaluated, true)
+	if len(loader.modules) != 3 {
+		t.Errorf("wrong number of cached modules. got=%d", len(loader.modules))
+	}
+}
+
+func TestImportCycle(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.ms": `import "a.ms";`,
+		"a.ms":    `import "b.ms"; export let x = 1;`,
+		"b.ms":    `import "a.ms"; export let y = 2;`,
+	})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.ms"), NewModuleLoader())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	a, b := filepath.Join(dir, "a.ms"), filepath.Join(dir, "b.ms")
+	expected := "error in module " + a + ": error in module " + b + ": import cycle: " + a + " -> " + b + " -> " + a
+	if errObj.Message != expected {
+		t.Errorf("wrong error message.\nwant=%q\ngot=%q", expected, errObj.Message)
+	}
+}
+
+func TestImportOutsideGrantedDirectories(t *testing.T) {
+	outside := writeModules(t, map[string]string{"secret.ms": `export let secret = 42;`, "notes.txt": "top secret"})
+	dir := writeModules(t, map[string]string{"main.ms": ``})
+	secret, notes := filepath.Join(outside, "secret.ms"), filepath.Join(outside, "notes.txt")
+	relative, err := filepath.Rel(dir, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input string
+		read  object.Paths
+		error string
+	}{
+		{`import "` + secret + `"; secret.secret`, object.Paths{}, `import "` + secret + `": permission denied, needs --allow-read`},
+		{`import "` + relative + `" as s;`, object.Paths{}, `import "` + relative + `": permission denied, needs --allow-read`},
+		{`import "` + notes + `" as n;`, object.Paths{}, `import "` + notes + `": permission denied, needs --allow-read`},
+		{`import "` + filepath.Join(outside, "missing.ms") + `" as m;`, object.Paths{}, `import "` + filepath.Join(outside, "missing.ms") + `": permission denied, needs --allow-read`},
+		{`import "` + secret + `"; secret.secret`, object.Paths{Dirs: []string{outside}}, ""},
+		{`import "` + secret + `"; secret.secret`, object.Paths{All: true}, ""},
+	}
+
+	for _, tt := range tests {
+		rt := object.NewRuntime()
+		rt.Importer = NewModuleLoader()
+		rt.Capabilities.Read = tt.read
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := Eval(program, object.NewModuleEnvironment(filepath.Join(dir, "main.ms"), rt))
+
+		if tt.error == "" {
+			testIntegerObject(t, evaluated, 42)
+			continue
+		}
+		errObj, ok := evaluated.(*object.Error)
+		if !ok || errObj.Message != tt.error {
+			t.Errorf("%s: wrong result.\nwant=%q\ngot=%+v", tt.input, tt.error, evaluated)
+		}
+	}
+}
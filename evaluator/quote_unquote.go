@@ -0,0 +1,282 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"mscript/ast"
+	"mscript/object"
+	"mscript/token"
+	"strconv"
+	"sync/atomic"
+)
+
+// Counter for fresh names given to bindings introduced by quoted code
+var gensymCounter atomic.Uint64
+
+// Returns node unevaluated, with every unquote call inside it replaced by the code for its value
+// Bindings introduced by the quoted code itself are renamed to fresh names
+// so they cannot capture or clobber the names used by unquoted code
+func quote(node ast.Node, env *object.Environment) object.Object {
+	node = ast.Copy(node)
+	node = renameBindings(node)
+
+	var err *object.Error
+	node = ast.Modify(node, func(node ast.Node) ast.Node {
+		if err != nil || !isUnquoteCall(node) {
+			return node
+		}
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			err = newError("wrong number of arguments. got=%d, want=1", len(call.Arguments))
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted.(*object.Error)
+			return node
+		}
+		converted, ok := convertObjectToASTNode(unquoted)
+		if !ok {
+			err = newError("cannot unquote %s", unquoted.Type())
+			return node
+		}
+		return converted
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+func isQuoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "quote"
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
+
+// Renames the names bound by let statements, parameters and imports in node along with their uses
+// Names node does not bind keep referring to whatever they refer to where the code ends up,
+// unquoted code and member properties are left alone
+func renameBindings(node ast.Node) ast.Node {
+	r := &renamer{scope: &renameScope{}}
+	switch node := node.(type) {
+	case ast.Statement:
+		r.statement(node)
+	case ast.Expression:
+		r.expression(node)
+	}
+
+	for _, u := range r.uses {
+		if b := u.lookup(); b != nil {
+			b.uses = append(b.uses, u.ident)
+		}
+	}
+	for _, b := range r.bindings {
+		name := gensym(b.ident.Value)
+		for _, ident := range append(b.uses, b.ident) {
+			ident.Value = name
+			ident.Token.Literal = name
+		}
+	}
+	return node
+}
+
+// Finds what the names in quoted code refer to, with the scoping rules of the evaluator:
+// functions and macros open scopes, blocks do not, and a let binds its name once its value is evaluated
+type renamer struct {
+	scope    *renameScope
+	seq      int //Orders bindings and uses
+	bindings []*quotedBinding
+	uses     []quotedUse
+}
+
+type renameScope struct {
+	parent   *renameScope
+	open     int
+	bindings []*quotedBinding
+}
+
+type quotedBinding struct {
+	ident *ast.Identifier
+	seq   int
+	uses  []*ast.Identifier
+}
+
+type quotedUse struct {
+	ident *ast.Identifier
+	scope *renameScope
+	seq   int
+}
+
+func (r *renamer) next() int {
+	r.seq++
+	return r.seq
+}
+
+func (r *renamer) declare(ident *ast.Identifier) {
+	b := &quotedBinding{ident: ident, seq: r.next()}
+	r.scope.bindings = append(r.scope.bindings, b)
+	r.bindings = append(r.bindings, b)
+}
+
+func (r *renamer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		r.expression(stmt.Value)
+		r.declare(stmt.Name)
+	case *ast.ReturnStatement:
+		r.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		for _, s := range stmt.Statements {
+			r.statement(s)
+		}
+	case *ast.ImportStatement:
+		r.declare(stmt.Name)
+	case *ast.ExportStatement:
+		r.statement(stmt.Statement)
+	}
+}
+
+func (r *renamer) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		r.uses = append(r.uses, quotedUse{ident: exp, scope: r.scope, seq: r.next()})
+	case *ast.PrefixExpression:
+		r.expression(exp.Right)
+	case *ast.InfixExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Right)
+	case *ast.IfExpression:
+		r.expression(exp.Condition)
+		r.statement(exp.Consequence)
+		if exp.Alternative != nil {
+			r.statement(exp.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		r.function(exp.Parameters, exp.Body)
+	case *ast.MacroLiteral:
+		r.function(exp.Parameters, exp.Body)
+	case *ast.CallExpression:
+		//Unquoted code is evaluated where the quote is, not part of the quoted code
+		if isUnquoteCall(exp) {
+			return
+		}
+		r.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			r.expression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			r.expression(el)
+		}
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			r.expression(key)
+			r.expression(exp.Pairs[key])
+		}
+	case *ast.IndexExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Index)
+	case *ast.MemberExpression:
+		r.expression(exp.Object)
+	}
+}
+
+func (r *renamer) function(params []*ast.Identifier, body *ast.BlockStatement) {
+	outer := r.scope
+	r.scope = &renameScope{parent: outer, open: r.next()}
+	for _, param := range params {
+		r.declare(param)
+	}
+	r.statement(body)
+	r.scope = outer
+}
+
+// The binding u refers to, nil when it is bound outside the quoted code
+// Like analysis.Resolve a use sees the latest binding before it in its own scope,
+// and inside functions also a later binding in an outer scope since the function may run after it is made
+func (u quotedUse) lookup() *quotedBinding {
+	point := u.seq
+	for s := u.scope; s != nil; s = s.parent {
+		var before, after *quotedBinding
+		for _, b := range s.bindings {
+			if b.ident.Value != u.ident.Value {
+				continue
+			}
+			if b.seq < point {
+				before = b
+			} else if after == nil {
+				after = b
+			}
+		}
+		if before != nil {
+			return before
+		}
+		if s != u.scope && after != nil {
+			return after
+		}
+		point = s.open
+	}
+	return nil
+}
+
+// Fresh names contain '@' which the lexer never produces in an identifier
+func gensym(name string) string {
+	return fmt.Sprintf("%s@%d", name, gensymCounter.Add(1))
+}
+
+func convertObjectToASTNode(obj object.Object) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: strconv.FormatInt(obj.Value, 10)}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Float:
+		//Infinities and NaN have no literal
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return nil, false
+		}
+		t := token.Token{Type: token.FLOAT, Literal: obj.Inspect()}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false"}
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, true
+
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Array:
+		elements := make([]ast.Expression, len(obj.Elements))
+		for i, el := range obj.Elements {
+			node, ok := convertObjectToASTNode(el)
+			if !ok {
+				return nil, false
+			}
+			elements[i] = node.(ast.Expression)
+		}
+		t := token.Token{Type: token.LBRACKET, Literal: "["}
+		return &ast.ArrayLiteral{Token: t, Elements: elements}, true
+
+	case *object.Quote:
+		return ast.Copy(obj.Node), true
+	}
+
+	return nil, false
+}
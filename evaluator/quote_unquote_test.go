@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"mscript/object"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, tt := range tests {
+		testQuote(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfixExpression = quote(4 + 4);
+		quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+		{`quote(unquote("hi"))`, `hi`},
+		{`quote(unquote([1, "a", false]))`, `[1, a, false]`},
+	}
+
+	for _, tt := range tests {
+		testQuote(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteRenamesBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(fn(x) { x + y })`, `fn(x@) (x@ + y)`},
+		{`let x = 1; quote(fn(x) { x + unquote(x) })`, `fn(x@) (x@ + 1)`},
+		{`let x = quote(x); quote(fn(x) { x + unquote(x) })`, `fn(x@) (x@ + x)`},
+		{`quote(if (true) { let a = m.a; a })`, `iftrue let a@ = m.a;a@`},
+		{`let a = 3; quote(x + fn(x) { x * 2 }(unquote(a)))`, `(x + fn(x@) (x@ * 2)(3))`},
+		{`quote(if (true) { let y = y + 1; y })`, `iftrue let y@ = (y + 1);y@`},
+		{`quote(fn() { let f = fn() { f() }; f })`, `fn() let f@ = fn() f@();f@`},
+	}
+
+	for _, tt := range tests {
+		testQuote(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote()`, "wrong number of arguments. got=0, want=1"},
+		{`quote(unquote(1, 2))`, "wrong number of arguments. got=2, want=1"},
+		{`quote(unquote(missing))`, "identifier not found: missing"},
+		{`quote(unquote(fn() {}))`, "cannot unquote FUNCTION"},
+		{`let m = macro() { 1 }; m`, "macros can only be defined by top level let statements"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestQuoteDoesNotModifySource(t *testing.T) {
+	input := `let f = fn(n) { quote(unquote(n) + x) }; f(1); f(2)`
+	testQuote(t, testEval(input), `(2 + x)`)
+}
+
+// Fresh names are compared without their counter
+func testQuote(t *testing.T, obj object.Object, expected string) {
+	t.Helper()
+	quote, ok := obj.(*object.Quote)
+	if !ok {
+		t.Fatalf("expected *object.Quote. got=%T (%+v)", obj, obj)
+	}
+	if quote.Node == nil {
+		t.Fatalf("quote.Node is nil")
+	}
+	if got := stripGensyms(quote.Node.String()); got != expected {
+		t.Errorf("not equal. got=%q, want=%q", got, expected)
+	}
+}
+
+func stripGensyms(s string) string {
+	out := []byte{}
+	for i := 0; i < len(s); i++ {
+		out = append(out, s[i])
+		if s[i] == '@' {
+			for i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9' {
+				i++
+			}
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"mscript/ast"
+	"mscript/object"
+)
+
+// Reports whether obj is a value of type t, used when the runtime is strict
+// Unknown type names match nothing, the checker reports them before a program runs
+func conforms(obj object.Object, t *ast.TypeAnnotation) bool {
+	if obj == nil {
+		obj = NULL
+	}
+
+	switch t.Name {
+	case "any":
+		return true
+	case "int":
+		return obj.Type() == object.INTEGER_OBJ
+	case "float":
+		return obj.Type() == object.FLOAT_OBJ
+	case "string":
+		return obj.Type() == object.STRING_OBJ
+	case "bool":
+		return obj.Type() == object.BOOLEAN_OBJ
+	case "null":
+		return obj == NULL
+	case "module":
+		return obj.Type() == object.MODULE_OBJ
+	case "regex":
+		return obj.Type() == object.REGEX_OBJ
+
+	case "array":
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return false
+		}
+		if len(t.Elements) == 1 {
+			for _, el := range array.Elements {
+				if !conforms(el, t.Elements[0]) {
+					return false
+				}
+			}
+		}
+		return true
+
+	case "hash":
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return false
+		}
+		if len(t.Elements) == 2 {
+			for _, pair := range hash.Pairs {
+				if !conforms(pair.Key, t.Elements[0]) || !conforms(pair.Value, t.Elements[1]) {
+					return false
+				}
+			}
+		}
+		return true
+
+	case "fn":
+		//Only the number of parameters of a function is known before it is called
+		switch fn := obj.(type) {
+		case *object.Function:
+			return t.Elements == nil || len(t.Elements) == len(fn.Parameters)
+		case *object.Builtin, *object.Closure:
+			return true
+		}
+	}
+	return false
+}
+
+// Checks the arguments of a call to fn against the annotations of its parameters
+func checkArguments(fn *object.Function, args []object.Object) *object.Error {
+	for i, t := range fn.ParameterTypes {
+		if t != nil && !conforms(args[i], t) {
+			return newError("cannot use %s as %s for parameter %s", typeName(args[i]), t, fn.Parameters[i].Value)
+		}
+	}
+	return nil
+}
+
+func typeName(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
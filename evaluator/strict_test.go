@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/parser"
+	"strings"
+	"testing"
+)
+
+func testEvalStrict(input string, strict bool) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	env.Runtime().Strict = strict
+
+	return Eval(program, env)
+}
+
+func TestStrictAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string //The error in strict mode, "" when the program runs
+	}{
+		{`let x: int = 5; x`, ""},
+		{`let x: int = "five"; x`, "cannot use STRING as int in let x"},
+		{`let x: any = "five"; x`, ""},
+		{`let x: null = if (false) { 1 }; x`, ""},
+		{`let r: regex = re.compile("a"); 1`, ""},
+		{`let r: regex = "a"; 1`, "cannot use STRING as regex in let r"},
+		{`let xs: [int] = [1, 2, 3]; xs`, ""},
+		{`let xs: [int] = [1, "2"]; xs`, "cannot use ARRAY as [int] in let xs"},
+		{`let h: {string: int} = {"a": 1}; h`, ""},
+		{`let h: {string: int} = {1: 1}; h`, "cannot use HASH as {string: int} in let h"},
+		{`let f: fn(int): int = fn(x) { x }; 1`, ""},
+		{`let f: fn(int, int) = fn(x) { x }; 1`, "cannot use FUNCTION as fn(int, int) in let f"},
+		{`let f: fn = len; 1`, ""},
+		{`let f = fn(a: int, b: string) { a }; f(1, "b")`, ""},
+		{`let f = fn(a: int, b: string) { a }; f(1, 2)`, "cannot use INTEGER as string for parameter b"},
+		{`let f = fn(a, b: bool) { a }; f("a", true)`, ""},
+		{`let f = fn(a): int { a }; f(1)`, ""},
+		{`let f = fn(a): int { return a; }; f("a")`, "cannot use STRING as int in return"},
+		{`let f = fn(): int { }; f()`, "cannot use NULL as int in return"},
+		{`let f = fn(): int { 1 + true }; f()`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let g = fn() { "a" }; let f = fn(): int { g() }; f()`, "cannot use STRING as int in return"},
+		{`let g = fn(): string { 1 }; let f = fn(): int { return g(); }; f()`, "cannot use INTEGER as string in return"},
+		{`let f = fn(n): int { if (n == 0) { 0 } else { f(n - 1) } }; f(10)`, ""},
+	}
+
+	for _, tt := range tests {
+		//Annotations never change what a program does unless the runtime is strict
+		if loose, ok := testEvalStrict(tt.input, false).(*object.Error); ok && strings.HasPrefix(loose.Message, "cannot use") {
+			t.Errorf("%q: annotation checked without strict: %s", tt.input, loose.Message)
+		}
+
+		evaluated := testEvalStrict(tt.input, true)
+		errObj, isErr := evaluated.(*object.Error)
+		switch {
+		case tt.expected == "" && isErr:
+			t.Errorf("%q: unexpected error: %s", tt.input, errObj.Message)
+		case tt.expected != "" && !isErr:
+			t.Errorf("%q: no error returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+		case isErr && errObj.Message != tt.expected:
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
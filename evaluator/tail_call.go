@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"mscript/ast"
+	"mscript/object"
+)
+
+// A call in tail position, evaluated up to the point of calling
+// applyFunction makes it once the function it appears in has returned, so
+// recursion in tail position runs in constant stack
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType {
+	return "TAIL_CALL"
+}
+
+func (tc *tailCall) Inspect() string {
+	return "tail call"
+}
+
+// Evaluates the function and arguments of a call and returns them as a tailCall without making it
+// Quotes are evaluated right away since they do not call anything
+func evalCall(node *ast.CallExpression, env *object.Environment) object.Object {
+	if isQuoteCall(node) {
+		if len(node.Arguments) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(node.Arguments))
+		}
+		return quote(node.Arguments[0], env)
+	}
+	function := Eval(node.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	return &tailCall{fn: function, args: args}
+}
+
+// Evaluates a function body, or a branch in tail position of one, leaving the call it ends in to the caller
+// Returned values are unwrapped since the block ends the function
+func evalTailBlock(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+	for i, statement := range block.Statements {
+		if err := beforeStatement(statement, env); err != nil {
+			return err
+		}
+		if es, ok := statement.(*ast.ExpressionStatement); ok && i == len(block.Statements)-1 {
+			return evalTail(es.Expression, env)
+		}
+		result = Eval(statement, env)
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+	return result
+}
+
+// Evaluates an expression in tail position
+func evalTail(exp ast.Expression, env *object.Environment) object.Object {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		return evalCall(exp, env)
+
+	case *ast.IfExpression:
+		condition := Eval(exp.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return orNull(evalTailBlock(exp.Consequence, env))
+		}
+		if exp.Alternative != nil {
+			return orNull(evalTailBlock(exp.Alternative, env))
+		}
+		return NULL
+	}
+	return Eval(exp, env)
+}
+
+// Makes obj when it is a call left for later, for the places a return can end up outside of a function
+func finishTailCall(obj object.Object, rt *object.Runtime) object.Object {
+	if call, ok := obj.(*tailCall); ok {
+		return applyFunction(call.fn, call.args, rt)
+	}
+	return obj
+}
@@ -0,0 +1,44 @@
+package evaluator
+
+import (
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/parser"
+	"testing"
+)
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(1000000, 0)", 500000500000},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(1000000, 0)", 500000500000},
+		{"let even = fn(n) { if (n == 0) { 1 } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { 0 } else { even(n - 1) } }; even(100001)", 0},
+		{"let count = fn(n) { if (n > 0) { let m = n - 1; count(m) } else { 42 } }; count(100000)", 42},
+		{"let f = fn(x) { x * 2 }; let g = fn(x) { f(x) + 1 }; g(3)", 7},
+		{"let inc = fn(x) { x + 1 }; let twice = fn(f, x) { f(f(x)) }; twice(inc, 1)", 3},
+		{"let f = fn() { return len(\"abc\"); }; f()", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestTailCallsKeepDepth(t *testing.T) {
+	input := "let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(10000)"
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	env.Runtime().MaxDepth = 10
+
+	testIntegerObject(t, Eval(program, env), 0)
+
+	//Calls that are not in tail position still count
+	input = "let loop = fn(n) { if (n == 0) { 0 } else { 1 + loop(n - 1) } }; loop(100)"
+	program = parser.New(lexer.New(input)).ParseProgram()
+	errObj, ok := Eval(program, env).(*object.Error)
+	if !ok || errObj.Message != "maximum call depth of 10 exceeded" {
+		t.Errorf("wrong result. got=%v", errObj)
+	}
+}
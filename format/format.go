@@ -0,0 +1,578 @@
+// Package format prints mScript programs in their canonical layout.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"mscript/ast"
+	"mscript/lexer"
+	"mscript/parser"
+	"mscript/token"
+	"path/filepath"
+	"strings"
+)
+
+// Lists longer than this are split one element per line
+const MaxWidth = 80
+
+// Width a tab counts for when measuring lines
+const TabWidth = 4
+
+// Parses src and returns it in canonical form, comments are kept
+func Source(src []byte) ([]byte, error) {
+	return SourceIndented(src, "\t")
+}
+
+// Like Source but indents blocks and wrapped lists with indent instead of a tab
+func SourceIndented(src []byte, indent string) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parse errors:\n\t%s", strings.Join(p.Errors(), "\n\t"))
+	}
+
+	return printProgram(program, l.Comments(), indent), nil
+}
+
+// Prints program in canonical form with comments placed by their line numbers
+func Program(program *ast.Program, comments []lexer.Comment) []byte {
+	return printProgram(program, comments, "\t")
+}
+
+func printProgram(program *ast.Program, comments []lexer.Comment, indent string) []byte {
+	pr := &printer{comments: comments, indentText: indent}
+	end := pr.statements(program.Statements, endOfFile)
+	pr.remainingComments(end)
+
+	out := pr.buf.Bytes()
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	return out
+}
+
+// A source position, comments are placed by comparing them against positions of the ast
+type position struct {
+	line   int
+	column int
+}
+
+var endOfFile = position{line: math.MaxInt}
+
+type printer struct {
+	buf        bytes.Buffer
+	indent     int
+	indentText string //Written indent times at the start of a line
+
+	comments []lexer.Comment
+	next     int //Index of the first comment not printed yet
+}
+
+// Prints statements one per line and returns the last source line printed
+// Comments before until are printed in between
+func (pr *printer) statements(stmts []ast.Statement, until position) int {
+	prevEnd := 0
+
+	for i, stmt := range stmts {
+		start := startLine(stmt)
+
+		//Comments on their own lines before the statement
+		for pr.hasCommentBefore(position{line: start}) {
+			c := pr.comments[pr.next]
+			pr.blankLineIfGap(prevEnd, c.Line, i > 0 || c.Line > 1)
+			pr.line(c.Text)
+			prevEnd = c.Line
+			pr.next++
+		}
+
+		pr.blankLineIfGap(prevEnd, start, i > 0 || prevEnd > 0)
+		pr.writeIndent()
+		pr.statement(stmt)
+
+		//Comments inside the statement end up after it, the first on the same line
+		end := endLine(stmt)
+		first := true
+		for pr.hasCommentBefore(until) && pr.comments[pr.next].Line <= end {
+			c := pr.comments[pr.next]
+			if first {
+				pr.buf.WriteString(" " + c.Text)
+				first = false
+			} else {
+				pr.buf.WriteString("\n")
+				pr.writeIndent()
+				pr.buf.WriteString(c.Text)
+			}
+			pr.next++
+		}
+		pr.buf.WriteString("\n")
+		prevEnd = end
+	}
+
+	//Comments after the last statement of a block
+	if until != endOfFile {
+		for pr.hasCommentBefore(until) {
+			c := pr.comments[pr.next]
+			pr.blankLineIfGap(prevEnd, c.Line, prevEnd > 0)
+			pr.line(c.Text)
+			prevEnd = c.Line
+			pr.next++
+		}
+	}
+	return prevEnd
+}
+
+func (pr *printer) remainingComments(prevEnd int) {
+	for ; pr.next < len(pr.comments); pr.next++ {
+		c := pr.comments[pr.next]
+		pr.blankLineIfGap(prevEnd, c.Line, pr.buf.Len() > 0)
+		pr.line(c.Text)
+		prevEnd = c.Line
+	}
+}
+
+func (pr *printer) hasCommentBefore(p position) bool {
+	if pr.next >= len(pr.comments) {
+		return false
+	}
+	c := pr.comments[pr.next]
+	return c.Line < p.line || (c.Line == p.line && c.Column < p.column)
+}
+
+// Keeps at most one blank line where the source had one or more
+func (pr *printer) blankLineIfGap(prevEnd, line int, allowed bool) {
+	if allowed && prevEnd > 0 && line > prevEnd+1 {
+		pr.buf.WriteString("\n")
+	}
+}
+
+func (pr *printer) line(s string) {
+	pr.writeIndent()
+	pr.buf.WriteString(s)
+	pr.buf.WriteString("\n")
+}
+
+func (pr *printer) writeIndent() {
+	pr.buf.WriteString(strings.Repeat(pr.indentText, pr.indent))
+}
+
+// Width of the line currently being written
+func (pr *printer) column() int {
+	b := pr.buf.Bytes()
+	start := bytes.LastIndexByte(b, '\n') + 1
+	width := 0
+	for _, ch := range b[start:] {
+		if ch == '\t' {
+			width += TabWidth
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+func (pr *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		pr.buf.WriteString("let " + stmt.Name.Value)
+		if stmt.Type != nil {
+			pr.buf.WriteString(": " + stmt.Type.String())
+		}
+		pr.buf.WriteString(" = ")
+		pr.expression(stmt.Value, parser.LOWEST)
+		pr.buf.WriteString(";")
+
+	case *ast.ReturnStatement:
+		pr.buf.WriteString("return ")
+		pr.expression(stmt.ReturnValue, parser.LOWEST)
+		pr.buf.WriteString(";")
+
+	case *ast.ExpressionStatement:
+		pr.expression(stmt.Expression, parser.LOWEST)
+		//Statements ending in a block read better without a semicolon
+		if _, ok := stmt.Expression.(*ast.IfExpression); !ok {
+			pr.buf.WriteString(";")
+		}
+
+	case *ast.ImportStatement:
+		pr.buf.WriteString(`import "` + stmt.Path + `"`)
+		if stmt.Name.Value != defaultImportName(stmt.Path) {
+			pr.buf.WriteString(" as " + stmt.Name.Value)
+		}
+		pr.buf.WriteString(";")
+
+	case *ast.ExportStatement:
+		pr.buf.WriteString("export ")
+		pr.statement(stmt.Statement)
+
+	case *ast.BlockStatement:
+		pr.block(stmt)
+	}
+}
+
+// Name an import is bound to when it has no 'as', see parser.parseImportStatement
+func defaultImportName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func (pr *printer) block(block *ast.BlockStatement) {
+	rbrace := position{line: block.Rbrace.Line, column: block.Rbrace.Column}
+	if len(block.Statements) == 0 && !pr.hasCommentBefore(rbrace) {
+		pr.buf.WriteString("{}")
+		return
+	}
+
+	pr.buf.WriteString("{\n")
+	pr.indent++
+	pr.statements(block.Statements, rbrace)
+	pr.indent--
+	pr.writeIndent()
+	pr.buf.WriteString("}")
+}
+
+// Prints exp, wrapping it in parentheses when it binds looser than its context
+func (pr *printer) expression(exp ast.Expression, context int) {
+	if precedence(exp) < context {
+		pr.buf.WriteString("(")
+		pr.expression(exp, parser.LOWEST)
+		pr.buf.WriteString(")")
+		return
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		pr.buf.WriteString(exp.Value)
+
+	case *ast.IntegerLiteral:
+		pr.buf.WriteString(exp.Token.Literal)
+
+	case *ast.FloatLiteral:
+		pr.buf.WriteString(exp.Token.Literal)
+
+	case *ast.Boolean:
+		pr.buf.WriteString(exp.Token.Literal)
+
+	case *ast.StringLiteral:
+		pr.buf.WriteString(`"` + exp.Value + `"`)
+
+	case *ast.PrefixExpression:
+		pr.buf.WriteString(exp.Operator)
+		pr.expression(exp.Right, parser.PREFIX)
+
+	case *ast.InfixExpression:
+		//Operators are left associative so only the right side needs parentheses at equal precedence
+		prec := parser.Precedence(exp.Token.Type)
+		pr.expression(exp.Left, prec)
+		pr.buf.WriteString(" " + exp.Operator + " ")
+		pr.expression(exp.Right, prec+1)
+
+	case *ast.IfExpression:
+		pr.buf.WriteString("if (")
+		pr.expression(exp.Condition, parser.LOWEST)
+		pr.buf.WriteString(") ")
+		pr.block(exp.Consequence)
+		if exp.Alternative != nil {
+			pr.buf.WriteString(" else ")
+			pr.block(exp.Alternative)
+		}
+
+	case *ast.FunctionLiteral:
+		params := make([]ast.Expression, len(exp.Parameters))
+		for i, p := range exp.Parameters {
+			params[i] = &parameter{Identifier: p, annotation: exp.ParameterType(i)}
+		}
+		returns := ""
+		if exp.ReturnType != nil {
+			returns = ": " + exp.ReturnType.String()
+		}
+		//The parameters are wrapped when the line up to the brace opening the body is too long
+		pr.buf.WriteString("fn")
+		pr.list("(", params, ")", exp.Rparen, len(returns+" {"))
+		pr.buf.WriteString(returns + " ")
+		pr.block(exp.Body)
+
+	case *ast.MacroLiteral:
+		params := make([]ast.Expression, len(exp.Parameters))
+		for i, p := range exp.Parameters {
+			params[i] = &parameter{Identifier: p}
+		}
+		pr.buf.WriteString("macro")
+		pr.list("(", params, ")", exp.Rparen, len(" {"))
+		pr.buf.WriteString(" ")
+		pr.block(exp.Body)
+
+	case *ast.CallExpression:
+		pr.expression(exp.Function, parser.CALL)
+		pr.list("(", exp.Arguments, ")", exp.Rparen, 0)
+
+	case *ast.ArrayLiteral:
+		pr.list("[", exp.Elements, "]", exp.Rbracket, 0)
+
+	case *ast.HashLiteral:
+		pairs := make([]ast.Expression, len(exp.Keys))
+		for i, key := range exp.Keys {
+			pairs[i] = &pair{Expression: key, value: exp.Pairs[key]}
+		}
+		pr.list("{", pairs, "}", exp.Rbrace, 0)
+
+	case *ast.IndexExpression:
+		pr.expression(exp.Left, parser.INDEX)
+		pr.buf.WriteString("[")
+		pr.expression(exp.Index, parser.LOWEST)
+		pr.buf.WriteString("]")
+
+	case *ast.MemberExpression:
+		pr.expression(exp.Object, parser.CALL)
+		pr.buf.WriteString("." + exp.Property.Value)
+
+	case *parameter:
+		pr.buf.WriteString(exp.Value)
+		if exp.annotation != nil {
+			pr.buf.WriteString(": " + exp.annotation.String())
+		}
+
+	case *pair:
+		pr.expression(exp.Expression, parser.LOWEST)
+		pr.buf.WriteString(": ")
+		pr.expression(exp.value, parser.LOWEST)
+	}
+}
+
+// Prints a comma separated list on one line, or one element per line when it does not fit
+// or has comments inside, end is the closing token in the source
+// and reserve the width of what has to follow close on the same line
+func (pr *printer) list(open string, items []ast.Expression, close string, end token.Token, reserve int) {
+	until := position{line: end.Line, column: end.Column}
+	flatItems := make([]string, len(items))
+	allFlat := true
+	for i, item := range items {
+		s, ok := flat(item)
+		flatItems[i] = s
+		allFlat = allFlat && ok
+	}
+
+	inline := open + strings.Join(flatItems, ", ") + close
+	fits := !allFlat || len(items) == 0 || pr.column()+len(inline)+reserve <= MaxWidth
+	if fits && !pr.hasCommentBefore(until) {
+		pr.buf.WriteString(open)
+		for i, item := range items {
+			if i > 0 {
+				pr.buf.WriteString(", ")
+			}
+			pr.expression(item, parser.LOWEST)
+		}
+		pr.buf.WriteString(close)
+		return
+	}
+
+	pr.buf.WriteString(open + "\n")
+	pr.indent++
+	for i, item := range items {
+		//Comments on their own lines before the item
+		for pr.hasCommentBefore(start(item)) {
+			pr.line(pr.comments[pr.next].Text)
+			pr.next++
+		}
+
+		pr.writeIndent()
+		pr.expression(item, parser.LOWEST)
+		if i < len(items)-1 {
+			pr.buf.WriteString(",")
+		}
+
+		//Comments inside the item or after it on its last line follow it, the first on the same line
+		//A comment after a later item on the same line follows that item instead
+		last, next := endLine(item), until
+		if i < len(items)-1 {
+			next = start(items[i+1])
+		}
+		for first := true; pr.hasCommentBefore(next) && pr.comments[pr.next].Line <= last; first = false {
+			if first {
+				pr.buf.WriteString(" " + pr.comments[pr.next].Text)
+			} else {
+				pr.buf.WriteString("\n")
+				pr.writeIndent()
+				pr.buf.WriteString(pr.comments[pr.next].Text)
+			}
+			pr.next++
+		}
+		pr.buf.WriteString("\n")
+	}
+	//Comments after the last item
+	for pr.hasCommentBefore(until) {
+		pr.line(pr.comments[pr.next].Text)
+		pr.next++
+	}
+	pr.indent--
+	pr.writeIndent()
+	pr.buf.WriteString(close)
+}
+
+// Renders exp on a single line, false if it contains a block and so spans lines
+func flat(exp ast.Expression) (string, bool) {
+	if containsBlock(exp) {
+		return "", false
+	}
+	pr := &printer{}
+	pr.expression(exp, parser.LOWEST)
+	return pr.buf.String(), true
+}
+
+func containsBlock(exp ast.Expression) bool {
+	if p, ok := exp.(*pair); ok {
+		return containsBlock(p.Expression) || containsBlock(p.value)
+	}
+	found := false
+	ast.Inspect(exp, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.IfExpression, *ast.FunctionLiteral, *ast.MacroLiteral:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// How tightly exp binds, compared against the context it is printed in
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	default:
+		return parser.INDEX + 1
+	}
+}
+
+// A key: value pair of a hash literal, lets hashes share the list printing of arrays and calls
+// Embedding the key makes it an ast.Expression
+type pair struct {
+	ast.Expression
+	value ast.Expression
+}
+
+// A parameter of a function or macro literal with its annotation, lets parameters share the list printing
+type parameter struct {
+	*ast.Identifier
+	annotation *ast.TypeAnnotation //nil when not annotated
+}
+
+// First source line of a statement
+func startLine(stmt ast.Statement) int {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Line
+	case *ast.ReturnStatement:
+		return stmt.Token.Line
+	case *ast.ExpressionStatement:
+		return stmt.Token.Line
+	case *ast.ImportStatement:
+		return stmt.Token.Line
+	case *ast.ExportStatement:
+		return stmt.Token.Line
+	case *ast.BlockStatement:
+		return stmt.Token.Line
+	}
+	return 0
+}
+
+// Last source line a node reaches
+func endLine(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return max(node.Token.Line, endLine(node.Value))
+	case *ast.ReturnStatement:
+		return max(node.Token.Line, endLine(node.ReturnValue))
+	case *ast.ExpressionStatement:
+		return max(node.Token.Line, endLine(node.Expression))
+	case *ast.ImportStatement:
+		return node.Token.Line
+	case *ast.ExportStatement:
+		return endLine(node.Statement)
+	case *ast.BlockStatement:
+		return node.Rbrace.Line
+	case *ast.PrefixExpression:
+		return endLine(node.Right)
+	case *ast.InfixExpression:
+		return endLine(node.Right)
+	case *ast.IfExpression:
+		if node.Alternative != nil {
+			return endLine(node.Alternative)
+		}
+		return endLine(node.Consequence)
+	case *ast.FunctionLiteral:
+		return endLine(node.Body)
+	case *ast.MacroLiteral:
+		return endLine(node.Body)
+	case *ast.CallExpression:
+		return node.Rparen.Line
+	case *ast.ArrayLiteral:
+		return node.Rbracket.Line
+	case *ast.HashLiteral:
+		return node.Rbrace.Line
+	case *ast.IndexExpression:
+		return node.Rbracket.Line
+	case *ast.MemberExpression:
+		return node.Property.Token.Line
+	case *ast.Identifier:
+		return node.Token.Line
+	case *ast.IntegerLiteral:
+		return node.Token.Line
+	case *ast.FloatLiteral:
+		return node.Token.Line
+	case *ast.StringLiteral:
+		return node.Token.Line
+	case *ast.Boolean:
+		return node.Token.Line
+	case *pair:
+		return endLine(node.value)
+	case *parameter:
+		return node.Token.Line
+	}
+	return 0
+}
+
+// Where exp starts in the source, comments before it belong in front of it
+func start(exp ast.Expression) position {
+	var tok token.Token
+	switch exp := exp.(type) {
+	case *pair:
+		return start(exp.Expression)
+	case *parameter:
+		tok = exp.Token
+	case *ast.InfixExpression:
+		return start(exp.Left)
+	case *ast.CallExpression:
+		return start(exp.Function)
+	case *ast.IndexExpression:
+		return start(exp.Left)
+	case *ast.MemberExpression:
+		return start(exp.Object)
+	case *ast.Identifier:
+		tok = exp.Token
+	case *ast.IntegerLiteral:
+		tok = exp.Token
+	case *ast.FloatLiteral:
+		tok = exp.Token
+	case *ast.StringLiteral:
+		tok = exp.Token
+	case *ast.Boolean:
+		tok = exp.Token
+	case *ast.PrefixExpression:
+		tok = exp.Token
+	case *ast.IfExpression:
+		tok = exp.Token
+	case *ast.FunctionLiteral:
+		tok = exp.Token
+	case *ast.MacroLiteral:
+		tok = exp.Token
+	case *ast.ArrayLiteral:
+		tok = exp.Token
+	case *ast.HashLiteral:
+		tok = exp.Token
+	}
+	return position{line: tok.Line, column: tok.Column}
+}
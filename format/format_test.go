@@ -0,0 +1,144 @@
+package format
+
+import (
+	"mscript/lexer"
+	"mscript/parser"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let   x = (1 + 2) * 3 - (4 - 5);let y=x",
+			"let x = (1 + 2) * 3 - (4 - 5);\nlet y = x;\n",
+		},
+		{
+			"a - (b - c); (a - b) - c; a * (b + c); -(a + b); -(-a); !(a == b); (-a) * b",
+			"a - (b - c);\na - b - c;\na * (b + c);\n-(a + b);\n--a;\n!(a == b);\n-a * b;\n",
+		},
+		{
+			"let add = fn(a,b){a+b}",
+			"let add = fn(a, b) {\n\ta + b;\n};\n",
+		},
+		{
+			"let add:fn(int,int):int = fn(a:int,b :int):int{a+b}",
+			"let add: fn(int, int): int = fn(a: int, b: int): int {\n\ta + b;\n};\n",
+		},
+		{
+			"if (x < 10) { puts(\"small\") } else { puts(\"big\") ; }",
+			"if (x < 10) {\n\tputs(\"small\");\n} else {\n\tputs(\"big\");\n}\n",
+		},
+		{
+			"let unless = macro(c, a){quote(if (!(unquote(c))) { unquote(a) })}",
+			"let unless = macro(c, a) {\n\tquote(if (!unquote(c)) {\n\t\tunquote(a);\n\t});\n};\n",
+		},
+		{
+			"let f = fn() {}; f()[0].name(1)",
+			"let f = fn() {};\nf()[0].name(1);\n",
+		},
+		{
+			`let h = {"a": 1, "b": [1,2,3]}`,
+			"let h = {\"a\": 1, \"b\": [1, 2, 3]};\n",
+		},
+		{
+			`import "lib/strings.ms" as strings; import "lib/x.ms" as y; export let z = 1`,
+			"import \"lib/strings.ms\";\nimport \"lib/x.ms\" as y;\nexport let z = 1;\n",
+		},
+		{
+			"let long = someFunction(aVeryLongArgumentName, anotherVeryLongArgumentName, yetAnotherLongArgument)",
+			"let long = someFunction(\n\taVeryLongArgumentName,\n\tanotherVeryLongArgumentName,\n\tyetAnotherLongArgument\n);\n",
+		},
+		{
+			"// header\n\nlet a = 1; // one\n\n\n// about b\nlet b = fn() {\n// inside\n\n  2 // two\n  // end\n}\n// footer",
+			"// header\n\nlet a = 1; // one\n\n// about b\nlet b = fn() {\n\t// inside\n\n\t2; // two\n\t// end\n};\n// footer\n",
+		},
+		{
+			"let add = fn(a,b){a+b} // adds\nif (x) { 1 } else { // big\n2 }",
+			"let add = fn(a, b) {\n\ta + b;\n}; // adds\nif (x) {\n\t1;\n} else {\n\t// big\n\t2;\n}\n",
+		},
+		{
+			"let empty = fn() {\n  // todo\n}",
+			"let empty = fn() {\n\t// todo\n};\n",
+		},
+		{
+			"let h = {\"a\": 1, // first\n \"b\": 2};\nadd(1, // one\n 2);\nlet y = 1;",
+			"let h = {\n\t\"a\": 1, // first\n\t\"b\": 2\n};\nadd(\n\t1, // one\n\t2\n);\nlet y = 1;\n",
+		},
+		{
+			"let xs = [\n  // first\n  1,\n  2 // two\n  // last\n]; // xs",
+			"let xs = [\n\t// first\n\t1,\n\t2 // two\n\t// last\n]; // xs\n",
+		},
+		{
+			"let arr = [1,   2, // two\n  3];",
+			"let arr = [\n\t1,\n\t2, // two\n\t3\n];\n",
+		},
+		{
+			"let configure = fn(firstArgumentName: int, secondArgumentName: string, third): bool { true }",
+			"let configure = fn(\n\tfirstArgumentName: int,\n\tsecondArgumentName: string,\n\tthird\n): bool {\n\ttrue;\n};\n",
+		},
+		{
+			"let m = macro(aVeryLongParameterName, anotherVeryLongParameterName, yetAnotherOne) { 1 }",
+			"let m = macro(\n\taVeryLongParameterName,\n\tanotherVeryLongParameterName,\n\tyetAnotherOne\n) {\n\t1;\n};\n",
+		},
+		{
+			"let f = fn(a, // first\n b) { a }",
+			"let f = fn(\n\ta, // first\n\tb\n) {\n\ta;\n};\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("format error: %s", err)
+		}
+		if string(out) != tt.expected {
+			t.Errorf("wrong output for %q.\nwant=\n%s\ngot=\n%s", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestIdempotentAndRoundTrip(t *testing.T) {
+	inputs := []string{
+		"let x = 1 + 2 * 3 - -4 / (5 - 6) == !true != (1 < 2) > false",
+		"let f = fn(a, b) { if (a > b) { return a; } else { let c = b; c } }; f(1, 2)",
+		`let h = {"k": [1, 2, {"n": fn(x) { x }}], 2: true}["k"][2]["n"](3)`,
+		"import \"lib.ms\"; lib.items[0].name(1, 2)",
+		"let wide = [111111111, 222222222, 333333333, 444444444, 555555555, 666666666, 777777777, 888888888]",
+		"let a = 1; // one\n// two\nlet b = [1, // inside\n 2]; // after\n\n\n// last",
+		"(fn(x) { x })(1); -f(2); -(a.b); (-a).b; (a + b)[0]",
+		"let xs: [{string: any}] = []; let f = fn(a, b: fn): [int] { [] }",
+		"let add = fn(a,b){a+b} // adds\nif (x) { 1 } else { // big\n2 ; \n// end of else\n}",
+		"let xs = [111111111, 222222222, 333333333, 444444444, 555555555, 666666666, 777777777, 888888888];\n// c\nlet y = 1;",
+		"let arr = [1,   2, // two\n  3];",
+		"let h = {\"aaaaaaaaaaaaaaaaaaaa\": 1, \"bbbbbbbbbbbbbbbbbbbbbb\": 2, \"cccccccccccccccccccccccc\": 3}; // h\nf(xs[\n0]);\nlet y = 1;",
+	}
+
+	for _, input := range inputs {
+		first, err := Source([]byte(input))
+		if err != nil {
+			t.Fatalf("format error for %q: %s", input, err)
+		}
+		second, err := Source(first)
+		if err != nil {
+			t.Fatalf("formatted output does not parse: %s\n%s", err, first)
+		}
+		if string(first) != string(second) {
+			t.Errorf("formatting is not idempotent.\nfirst=\n%s\nsecond=\n%s", first, second)
+		}
+
+		original := parser.New(lexer.New(input)).ParseProgram()
+		formatted := parser.New(lexer.New(string(first))).ParseProgram()
+		if original.String() != formatted.String() {
+			t.Errorf("formatting changed the program.\nwant=%s\ngot=%s", original.String(), formatted.String())
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	if _, err := Source([]byte("let = 5;")); err == nil {
+		t.Errorf("expected a parse error")
+	}
+}
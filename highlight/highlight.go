@@ -0,0 +1,184 @@
+// Package highlight colors mscript source by the class of each token, for
+// terminals with ANSI escape codes and for HTML pages.
+package highlight
+
+import (
+	"html"
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/token"
+	"sort"
+	"strings"
+)
+
+// What a piece of source is, decides its color
+type Class int
+
+const (
+	Plain Class = iota //Whitespace and anything not highlighted
+	Keyword
+	Identifier
+	Builtin
+	Number
+	String
+	Operator
+	Punctuation
+	Comment
+	Illegal
+)
+
+var classNames = map[Class]string{
+	Plain:       "plain",
+	Keyword:     "keyword",
+	Identifier:  "identifier",
+	Builtin:     "builtin",
+	Number:      "number",
+	String:      "string",
+	Operator:    "operator",
+	Punctuation: "punctuation",
+	Comment:     "comment",
+	Illegal:     "illegal",
+}
+
+func (c Class) String() string {
+	return classNames[c]
+}
+
+// ANSI escape codes
+const (
+	Reset   = "\x1b[0m"
+	Bold    = "\x1b[1m"
+	Red     = "\x1b[31m"
+	Green   = "\x1b[32m"
+	Yellow  = "\x1b[33m"
+	Blue    = "\x1b[34m"
+	Magenta = "\x1b[35m"
+	Cyan    = "\x1b[36m"
+	Gray    = "\x1b[90m"
+)
+
+var classColors = map[Class]string{
+	Keyword:  Magenta,
+	Builtin:  Blue,
+	Number:   Cyan,
+	String:   Green,
+	Operator: Yellow,
+	Comment:  Gray,
+	Illegal:  Red,
+}
+
+// Wraps s in color, returns s as it is when color is empty
+func Color(color, s string) string {
+	if color == "" || s == "" {
+		return s
+	}
+	return color + s + Reset
+}
+
+// A classified piece of source, Start and End are byte offsets
+type Span struct {
+	Class      Class
+	Start, End int
+}
+
+// Splits src into spans covering every byte in order
+// Gaps between tokens are Plain so joining the spans gives back src exactly
+func Spans(src string) []Span {
+	lineStarts := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offset := func(line, column int) int {
+		return lineStarts[line-1] + column - 1
+	}
+
+	var spans []Span
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		start := offset(tok.Line, tok.Column)
+		spans = append(spans, Span{Class: classify(tok), Start: start, End: min(start+sourceLength(tok), len(src))})
+	}
+	for _, c := range l.Comments() {
+		start := offset(c.Line, c.Column)
+		spans = append(spans, Span{Class: Comment, Start: start, End: start + len(c.Text)})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	//Fill the gaps so the spans cover all of src
+	covered := []Span{}
+	pos := 0
+	for _, s := range spans {
+		if s.Start > pos {
+			covered = append(covered, Span{Class: Plain, Start: pos, End: s.Start})
+		}
+		covered = append(covered, s)
+		pos = s.End
+	}
+	if pos < len(src) {
+		covered = append(covered, Span{Class: Plain, Start: pos, End: len(src)})
+	}
+	return covered
+}
+
+// How many bytes of source tok was read from
+func sourceLength(tok token.Token) int {
+	if tok.Type == token.STRING {
+		//The quotes, an unterminated string only has the opening one
+		return len(tok.Literal) + 2
+	}
+	return len(tok.Literal)
+}
+
+func classify(tok token.Token) Class {
+	switch tok.Type {
+	case token.IDENT:
+		if object.GetBuiltinByName(tok.Literal) != nil {
+			return Builtin
+		}
+		return Identifier
+	case token.INT, token.FLOAT:
+		return Number
+	case token.STRING:
+		return String
+	case token.ILLEGAL:
+		return Illegal
+	case token.ASSIGN, token.PLUS, token.MINUS, token.BANG, token.ASTERISK, token.SLASH,
+		token.LT, token.GT, token.EQ, token.NOT_EQ:
+		return Operator
+	case token.COMMA, token.SEMICOLON, token.COLON, token.DOT,
+		token.LPAREN, token.RPAREN, token.LBRACE, token.RBRACE, token.LBRACKET, token.RBRACKET:
+		return Punctuation
+	}
+	if token.LookupIdent(tok.Literal) != token.IDENT {
+		return Keyword
+	}
+	return Plain
+}
+
+// Returns src with ANSI color codes around each token
+func ANSI(src string) string {
+	var out strings.Builder
+	for _, s := range Spans(src) {
+		out.WriteString(Color(classColors[s.Class], src[s.Start:s.End]))
+	}
+	return out.String()
+}
+
+// Returns src as an HTML fragment, each token in a span with class "ms-<class>"
+// The colors are left to the stylesheet of the page
+func HTML(src string) string {
+	var out strings.Builder
+	out.WriteString(`<pre class="mscript"><code>`)
+	for _, s := range Spans(src) {
+		text := html.EscapeString(src[s.Start:s.End])
+		if s.Class == Plain {
+			out.WriteString(text)
+			continue
+		}
+		out.WriteString(`<span class="ms-` + s.Class.String() + `">` + text + `</span>`)
+	}
+	out.WriteString("</code></pre>\n")
+	return out.String()
+}
@@ -0,0 +1,74 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSpans(t *testing.T) {
+	input := "let s = \"hé\"; // note\nputs(s + 1 == x);\n@"
+
+	expected := []struct {
+		class Class
+		text  string
+	}{
+		{Keyword, "let"}, {Plain, " "}, {Identifier, "s"}, {Plain, " "}, {Operator, "="}, {Plain, " "},
+		{String, "\"hé\""}, {Punctuation, ";"}, {Plain, " "}, {Comment, "// note"}, {Plain, "\n"},
+		{Builtin, "puts"}, {Punctuation, "("}, {Identifier, "s"}, {Plain, " "}, {Operator, "+"}, {Plain, " "},
+		{Number, "1"}, {Plain, " "}, {Operator, "=="}, {Plain, " "}, {Identifier, "x"},
+		{Punctuation, ")"}, {Punctuation, ";"}, {Plain, "\n"}, {Illegal, "@"},
+	}
+
+	spans := Spans(input)
+	if len(spans) != len(expected) {
+		t.Fatalf("wrong number of spans. got=%d, want=%d: %v", len(spans), len(expected), spans)
+	}
+	for i, want := range expected {
+		got := spans[i]
+		if got.Class != want.class || input[got.Start:got.End] != want.text {
+			t.Errorf("spans[%d]: got=%s %q, want=%s %q", i, got.Class, input[got.Start:got.End], want.class, want.text)
+		}
+	}
+}
+
+func TestSpansCoverSource(t *testing.T) {
+	inputs := []string{
+		"",
+		"  \n\t",
+		"\"unterminated",
+		"// only a comment",
+		"let a = [1, 2];\n\n  // trailing   \nfn(x) { x }",
+		"macro(a) { quote(unquote(a)) }",
+	}
+
+	for _, input := range inputs {
+		var joined strings.Builder
+		pos := 0
+		for _, s := range Spans(input) {
+			if s.Start != pos {
+				t.Errorf("%q: span starts at %d, want %d", input, s.Start, pos)
+			}
+			joined.WriteString(input[s.Start:s.End])
+			pos = s.End
+		}
+		if joined.String() != input {
+			t.Errorf("spans do not join to the source. got=%q, want=%q", joined.String(), input)
+		}
+	}
+}
+
+func TestANSI(t *testing.T) {
+	got := ANSI("let x = \"a\"; // c")
+	expected := Magenta + "let" + Reset + " x " + Yellow + "=" + Reset + " " + Green + "\"a\"" + Reset + "; " + Gray + "// c" + Reset
+	if got != expected {
+		t.Errorf("wrong output.\ngot= %q\nwant=%q", got, expected)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	got := HTML("1 < \"&\"")
+	expected := `<pre class="mscript"><code><span class="ms-number">1</span> <span class="ms-operator">&lt;</span> <span class="ms-string">&#34;&amp;&#34;</span></code></pre>` + "\n"
+	if got != expected {
+		t.Errorf("wrong output.\ngot= %q\nwant=%q", got, expected)
+	}
+}
@@ -0,0 +1,351 @@
+// Package mscript embeds the mScript interpreter in Go programs.
+//
+//	in := mscript.New(mscript.WithStdout(&buf), mscript.WithMaxSteps(100000))
+//	if _, err := in.Run(`let add = fn(a, b) { a + b };`); err != nil {
+//		return err
+//	}
+//	sum, err := in.Call("add", &object.Integer{Value: 1}, &object.Integer{Value: 2})
+package mscript
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mscript/ast"
+	"mscript/compiler"
+	"mscript/evaluator"
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/optimizer"
+	"mscript/parser"
+	"mscript/vm"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backend selects how programs are executed
+type Backend int
+
+const (
+	//Walks the ast directly, supports the whole language
+	TreeWalker Backend = iota
+	//Compiles to bytecode and runs it on the vm, imports are not supported
+	VM
+)
+
+func (b Backend) String() string {
+	switch b {
+	case TreeWalker:
+		return "tree-walker"
+	case VM:
+		return "vm"
+	default:
+		return fmt.Sprintf("Backend(%d)", int(b))
+	}
+}
+
+// Returned when the source does not parse
+type ParseError struct {
+	Messages []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse errors:\n\t" + strings.Join(e.Messages, "\n\t")
+}
+
+// Returned when a program fails while running, including exceeded limits
+type RuntimeError struct {
+	Message string
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message
+}
+
+// Returned when a program ends itself with os.exit
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Interpreter runs programs one after another in a shared set of globals
+// An Interpreter is not safe for concurrent use
+type Interpreter struct {
+	backend  Backend
+	ctx      context.Context
+	timeout  time.Duration
+	runtime  *object.Runtime
+	optimize bool
+
+	//Macros defined by earlier runs, expanded before either backend sees the program
+	macros *object.Environment
+
+	//Tree walker state
+	env *object.Environment
+
+	//VM state
+	symbols   *compiler.SymbolTable
+	constants []object.Object
+	globals   []object.Object
+}
+
+type Option func(*Interpreter)
+
+// Writes the output of puts to w instead of os.Stdout
+func WithStdout(w io.Writer) Option {
+	return func(in *Interpreter) { in.runtime.Stdout = w }
+}
+
+// Reads the input of readline from r instead of os.Stdin
+func WithStdin(r io.Reader) Option {
+	return func(in *Interpreter) { in.runtime.Stdin = r }
+}
+
+func WithBackend(b Backend) Option {
+	return func(in *Interpreter) { in.backend = b }
+}
+
+// Stops each run after n steps, a step is a statement for the tree walker and an instruction for the vm
+func WithMaxSteps(n int) Option {
+	return func(in *Interpreter) { in.runtime.MaxSteps = n }
+}
+
+// Limits how deeply function calls may nest
+func WithMaxDepth(n int) Option {
+	return func(in *Interpreter) { in.runtime.MaxDepth = n }
+}
+
+// Cancels each run that takes longer than d
+func WithTimeout(d time.Duration) Option {
+	return func(in *Interpreter) { in.timeout = d }
+}
+
+// Cancels every run once ctx is done
+func WithContext(ctx context.Context) Option {
+	return func(in *Interpreter) { in.ctx = ctx }
+}
+
+// Calls hook before each statement the tree walker runs, see object.Runtime.Debug
+func WithDebugHook(hook func(stmt ast.Statement, env *object.Environment) error) Option {
+	return func(in *Interpreter) { in.runtime.Debug = hook }
+}
+
+// Checks lets, arguments and results against their type annotations while running
+// Only the tree walker checks annotations, the vm ignores them
+func WithStrict(strict bool) Option {
+	return func(in *Interpreter) { in.runtime.Strict = strict }
+}
+
+// Folds constants and removes dead code before running, see package optimizer
+func WithOptimize(optimize bool) Option {
+	return func(in *Interpreter) { in.optimize = optimize }
+}
+
+// Seeds the generator of the random module so runs draw the same numbers
+func WithSeed(seed int64) Option {
+	return func(in *Interpreter) { in.runtime.Seed(seed) }
+}
+
+// Grants scripts the capabilities of the fs and os modules, by default they have none
+func WithCapabilities(caps object.Capabilities) Option {
+	return func(in *Interpreter) { in.runtime.Capabilities = caps }
+}
+
+// Sets the arguments scripts get from os.args
+func WithArgs(args ...string) Option {
+	return func(in *Interpreter) { in.runtime.Args = args }
+}
+
+// Directories searched for imports not found next to the importing file
+func WithSearchPath(dirs ...string) Option {
+	return func(in *Interpreter) { in.runtime.Importer = evaluator.NewModuleLoader(dirs...) }
+}
+
+func New(opts ...Option) *Interpreter {
+	rt := object.NewRuntime()
+	rt.Importer = evaluator.NewModuleLoader(evaluator.DefaultSearchPath()...)
+
+	in := &Interpreter{
+		backend: TreeWalker,
+		ctx:     context.Background(),
+		runtime: rt,
+	}
+	for _, opt := range opts {
+		opt(in)
+	}
+
+	in.macros = object.NewModuleEnvironment("", rt)
+	in.env = object.NewModuleEnvironment("", rt)
+	in.symbols = compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		in.symbols.DefineBuiltin(i, v.Name)
+	}
+	in.constants = []object.Object{}
+	in.globals = make([]object.Object, vm.GlobalsSize)
+
+	return in
+}
+
+// Runs src and returns the value of its last statement
+// The result is nil when the program ends in a statement without a value
+func (in *Interpreter) Run(src string) (object.Object, error) {
+	return in.run(src, "")
+}
+
+// Runs the file at path, its imports are resolved relative to it
+func (in *Interpreter) RunFile(path string) (object.Object, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return in.run(string(src), file)
+}
+
+func (in *Interpreter) run(src string, file string) (result object.Object, err error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Messages: p.Errors()}
+	}
+
+	cancel := in.begin()
+	defer cancel()
+	defer in.checkExit(&err)
+	defer recoverRuntimeError(&err)
+
+	evaluator.DefineMacros(program, in.macros)
+	expanded, err := evaluator.ExpandMacros(program, in.macros)
+	if err != nil {
+		return nil, &RuntimeError{Message: err.Error()}
+	}
+	program = expanded.(*ast.Program)
+	if in.optimize {
+		program = optimizer.Optimize(program)
+	}
+
+	switch in.backend {
+	case VM:
+		comp := compiler.NewWithState(in.symbols, in.constants)
+		if err := comp.Compile(program); err != nil {
+			return nil, &RuntimeError{Message: err.Error()}
+		}
+		bytecode := comp.Bytecode()
+		in.constants = bytecode.Constants
+
+		machine := vm.NewWithGlobalsState(bytecode, in.globals, in.runtime)
+		if err := machine.Run(); err != nil {
+			return nil, &RuntimeError{Message: err.Error()}
+		}
+		return machine.LastPoppedStackElem(), nil
+
+	default:
+		env := in.env
+		if file != "" {
+			env = env.InFile(file)
+		}
+		return unwrapError(evaluator.Eval(program, env))
+	}
+}
+
+// Calls the global function name with args
+func (in *Interpreter) Call(name string, args ...object.Object) (result object.Object, err error) {
+	fn, ok := in.GetGlobal(name)
+	if !ok {
+		return nil, &RuntimeError{Message: "identifier not found: " + name}
+	}
+
+	cancel := in.begin()
+	defer cancel()
+	defer in.checkExit(&err)
+	defer recoverRuntimeError(&err)
+
+	switch in.backend {
+	case VM:
+		machine := vm.NewWithGlobalsState(&compiler.Bytecode{Constants: in.constants}, in.globals, in.runtime)
+		result, err := machine.Call(fn, args...)
+		if err != nil {
+			return nil, &RuntimeError{Message: err.Error()}
+		}
+		return result, nil
+
+	default:
+		return unwrapError(evaluator.Apply(in.env, fn, args...))
+	}
+}
+
+// Binds name in the globals, replacing any earlier binding
+func (in *Interpreter) SetGlobal(name string, val object.Object) {
+	if in.backend == VM {
+		symbol := in.symbols.Define(name)
+		in.globals[symbol.Index] = val
+		return
+	}
+	in.env.Set(name, val)
+}
+
+// Binds the Go value v to name after converting it with object.FromGo
+// Functions become builtins that check and convert their arguments
+//
+//	in.Register("lookup", func(id int, field string) (bool, error) { ... })
+func (in *Interpreter) Register(name string, v any) error {
+	obj, err := object.FromGo(v)
+	if err != nil {
+		return err
+	}
+	in.SetGlobal(name, obj)
+	return nil
+}
+
+// Returns the global bound to name, builtins are not included
+func (in *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	if in.backend == VM {
+		symbol, ok := in.symbols.Resolve(name)
+		if !ok || symbol.Scope != compiler.GlobalScope || in.globals[symbol.Index] == nil {
+			return nil, false
+		}
+		return in.globals[symbol.Index], true
+	}
+	return in.env.Get(name)
+}
+
+// Prepares the runtime for a run and returns the function ending it
+func (in *Interpreter) begin() context.CancelFunc {
+	in.runtime.Reset()
+
+	ctx, cancel := in.ctx, context.CancelFunc(func() {})
+	if in.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, in.timeout)
+	}
+	in.runtime.Context = ctx
+	return cancel
+}
+
+func unwrapError(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Message: errObj.Message}
+	}
+	return obj, nil
+}
+
+// Replaces the error stopping a run that called os.exit by an ExitError
+func (in *Interpreter) checkExit(err *error) {
+	if code, ok := in.runtime.Exited(); ok && *err != nil {
+		*err = &ExitError{Code: code}
+	}
+}
+
+// Turns a panic inside the interpreter into an error so a bad script cannot crash the host
+func recoverRuntimeError(err *error) {
+	if r := recover(); r != nil {
+		*err = &RuntimeError{Message: fmt.Sprintf("internal error: %v", r)}
+	}
+}
@@ -0,0 +1,483 @@
+package mscript
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mscript/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var backends = []Backend{TreeWalker, VM}
+
+func TestRunAndGlobals(t *testing.T) {
+	for _, backend := range backends {
+		in := New(WithBackend(backend))
+
+		if _, err := in.Run(`let base = 10;`); err != nil {
+			t.Fatalf("%s: run error: %s", backend, err)
+		}
+		result, err := in.Run(`base * 2`)
+		if err != nil {
+			t.Fatalf("%s: run error: %s", backend, err)
+		}
+		testInteger(t, backend, result, 20)
+
+		in.SetGlobal("offset", &object.Integer{Value: 5})
+		result, err = in.Run(`base + offset`)
+		if err != nil {
+			t.Fatalf("%s: run error: %s", backend, err)
+		}
+		testInteger(t, backend, result, 15)
+
+		val, ok := in.GetGlobal("base")
+		if !ok {
+			t.Fatalf("%s: global base not found", backend)
+		}
+		testInteger(t, backend, val, 10)
+
+		if _, ok := in.GetGlobal("missing"); ok {
+			t.Errorf("%s: found global that was never set", backend)
+		}
+	}
+}
+
+// Constants pile up over the runs of a vm interpreter, one past what an instruction can index is an error
+func TestConstantLimit(t *testing.T) {
+	in := New(WithBackend(VM))
+	elements := make([]string, 32768)
+	for i := range elements {
+		elements[i] = fmt.Sprint(i)
+	}
+	for run := 0; run < 2; run++ {
+		if _, err := in.Run("[" + strings.Join(elements, ", ") + "];"); err != nil {
+			t.Fatalf("run %d: %s", run, err)
+		}
+	}
+
+	result, err := in.Run("32768 + 0")
+	if err == nil || !strings.Contains(err.Error(), "program too large") {
+		t.Errorf("run past the constant limit gave %v, %v", result, err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	for _, backend := range backends {
+		in := New(WithBackend(backend))
+
+		if _, err := in.Run(`let greeting = "hello "; let greet = fn(name) { greeting + name };`); err != nil {
+			t.Fatalf("%s: run error: %s", backend, err)
+		}
+		result, err := in.Call("greet", &object.String{Value: "world"})
+		if err != nil {
+			t.Fatalf("%s: call error: %s", backend, err)
+		}
+		str, ok := result.(*object.String)
+		if !ok || str.Value != "hello world" {
+			t.Errorf("%s: wrong result. got=%T (%+v)", backend, result, result)
+		}
+
+		if _, err := in.Call("nope"); err == nil {
+			t.Errorf("%s: expected error calling undefined function", backend)
+		}
+	}
+}
+
+// Builtins called by hosts use the runtime of the interpreter
+func TestCallBuiltin(t *testing.T) {
+	for _, backend := range backends {
+		var out bytes.Buffer
+		in := New(WithBackend(backend), WithStdout(&out))
+		if _, err := in.Run(`let log = puts;`); err != nil {
+			t.Fatalf("%s: run error: %s", backend, err)
+		}
+		if _, err := in.Call("log", &object.String{Value: "hi"}); err != nil {
+			t.Fatalf("%s: call error: %s", backend, err)
+		}
+		if out.String() != "hi\n" {
+			t.Errorf("%s: wrong output. got=%q", backend, out.String())
+		}
+	}
+}
+
+// Functions can call functions declared after them on both backends
+func TestForwardReferences(t *testing.T) {
+	src := `
+	let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+	let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+	[isEven(10), isOdd(7)]`
+	for _, backend := range backends {
+		result, err := New(WithBackend(backend)).Run(src)
+		if got := describe(result, err); got != "[true, true]" {
+			t.Errorf("%s: wrong result. got=%s", backend, got)
+		}
+
+		//Calling before the later function is bound is still an error
+		_, err = New(WithBackend(backend)).Run(`let f = fn() { g() }; f(); let g = fn() { 1 };`)
+		if err == nil {
+			t.Errorf("%s: no error calling a function before its let", backend)
+		}
+	}
+}
+
+func TestStdoutAndStdin(t *testing.T) {
+	for _, backend := range backends {
+		var out bytes.Buffer
+		in := New(WithBackend(backend), WithStdout(&out), WithStdin(strings.NewReader("first\nsecond\n")))
+
+		if _, err := in.Run(`puts(readline()); puts(readline() + "!"); puts(readline())`); err != nil {
+			t.Fatalf("%s: run error: %s", backend, err)
+		}
+		if out.String() != "first\nsecond!\nnull\n" {
+			t.Errorf("%s: wrong output. got=%q", backend, out.String())
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, backend := range backends {
+		in := New(WithBackend(backend))
+
+		_, err := in.Run(`let x = ;`)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: expected ParseError. got=%T (%v)", backend, err, err)
+		}
+
+		_, err = in.Run(`1 + "a"`)
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("%s: expected RuntimeError. got=%T (%v)", backend, err, err)
+		}
+		if runtimeErr.Message != "type mismatch: INTEGER + STRING" {
+			t.Errorf("%s: wrong message. got=%q", backend, runtimeErr.Message)
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	src := `let n: int = "one"; n`
+	for _, backend := range backends {
+		result, err := New(WithBackend(backend)).Run(src)
+		if err != nil || result.Inspect() != "one" {
+			t.Errorf("%s: annotations checked without strict. got=%v, %v", backend, result, err)
+		}
+	}
+
+	_, err := New(WithStrict(true)).Run(src)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Message != "cannot use STRING as int in let n" {
+		t.Errorf("wrong strict error. got=%v", err)
+	}
+}
+
+// Programs must produce the same results and errors on both backends with and without optimizing
+func TestOptimize(t *testing.T) {
+	inputs := []string{
+		"2 * 3 + 1",
+		"9223372036854775807 + 1",
+		"-(-9223372036854775807 - 1)",
+		"1 + 2 * (3 / 0)",
+		"1 + true",
+		"let f = fn(x) { if (true) { return x * (4 / 2); } x }; f(5)",
+		"let f = fn() { 5; if (true) {} }; f()",
+		"let f = fn() { 5; if (false) { 1 } }; f()",
+		"let f = fn() { if (false) { 1 }; 5 }; f()",
+		"if (1 > 2) { 1 } else { let z = 3; z * 2 }",
+		"let h = {1 + 1: !true}; h[2]",
+		"[1 < 2, 1 == 1, 2 != 2, !0]",
+		"[0.1 + 0.2, 1 / 3.0, 1.0 == 1, -0.0, 1e308 * 10]",
+	}
+
+	for _, backend := range backends {
+		for _, input := range inputs {
+			want, wantErr := New(WithBackend(backend)).Run(input)
+			got, gotErr := New(WithBackend(backend), WithOptimize(true)).Run(input)
+			if describe(got, gotErr) != describe(want, wantErr) {
+				t.Errorf("%s: %q: optimized=%s, unoptimized=%s", backend, input, describe(got, gotErr), describe(want, wantErr))
+			}
+		}
+	}
+}
+
+func describe(result object.Object, err error) string {
+	if err != nil {
+		return "error " + err.Error()
+	}
+	if result == nil {
+		return "nil"
+	}
+	return result.Inspect()
+}
+
+func TestLimits(t *testing.T) {
+	recursion := `let f = fn(n) { 1 + f(n + 1) }; f(0);`
+	exponential := `let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) + f(n - 1) } }; f(40);`
+
+	tests := []struct {
+		opt      Option
+		input    string
+		expected string
+	}{
+		{WithMaxDepth(100), recursion, "maximum call depth of 100 exceeded"},
+		{WithMaxSteps(1000), recursion, "step limit of 1000 exceeded"},
+		{WithTimeout(20 * time.Millisecond), exponential, "execution cancelled: context deadline exceeded"},
+	}
+
+	for _, backend := range backends {
+		for _, tt := range tests {
+			in := New(WithBackend(backend), tt.opt)
+			_, err := in.Run(tt.input)
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("%s: wrong error. want=%q, got=%v", backend, tt.expected, err)
+			}
+		}
+	}
+}
+
+// Builtin modules are globals on both backends
+func TestBuiltinModules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`strings.join(strings.split("a b c", " "), ",")`, "a,b,c"},
+		{`let upper = strings.upper; upper("straße")`, "STRAßE"},
+		{`strings.format("%s has %d runes", "héllo", len("héllo"))`, "héllo has 5 runes"},
+		{`let strings = {"a": 1}; strings["a"]`, "1"},
+		{`strings.nope`, "error module strings has no exported member nope"},
+		{`math.pow(2, 10) + math.floor(2.7)`, "1026"},
+		{`let area = fn(r) { math.pi * r * r }; math.round(area(2))`, "13"},
+		{`math.max(1, 2.5) / 2`, "1.25"},
+		{`json.stringify({"a": [1, 2.0, if (false) { 1 }]})`, `{"a":[1,2.0,null]}`},
+		{`json.stringify({"f": len})`, "error json.stringify: cannot encode BUILTIN"},
+		{`let m = re.match(re.compile("(?P<level>[A-Z]+): (.*)"), "12:00 WARN: disk full"); [m["named"]["level"], m["groups"][1]]`, "[WARN, disk full]"},
+		{`re.replace(re.compile("(\w+)@(\w+)"), "bob@host", "$2:$1")`, "host:bob"},
+	}
+
+	for _, backend := range backends {
+		for _, tt := range tests {
+			if got := describe(New(WithBackend(backend)).Run(tt.input)); got != tt.expected {
+				t.Errorf("%s: %q: got %s, want %s", backend, tt.input, got, tt.expected)
+			}
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	input := `{"port": 8080, "ratio": 0.5, "hosts": ["a", "b"]}` + "\n"
+	src := `let config = json.parse(readline()); [config["port"] + 1, config["ratio"] * 2, json.stringify(config["hosts"])]`
+	for _, backend := range backends {
+		result, err := New(WithBackend(backend), WithStdin(strings.NewReader(input))).Run(src)
+		if got := describe(result, err); got != `[8081, 1.0, ["a","b"]]` {
+			t.Errorf("%s: wrong result. got=%s", backend, got)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	src := `[random.int(1000), random.float(), random.shuffle([1, 2, 3, 4, 5])]`
+	var results []string
+	for _, backend := range backends {
+		for i := 0; i < 2; i++ {
+			results = append(results, describe(New(WithBackend(backend), WithSeed(7)).Run(src)))
+		}
+	}
+	for _, result := range results[1:] {
+		if result != results[0] {
+			t.Errorf("seeded runs differ: %s and %s", results[0], result)
+		}
+	}
+
+	//Seeding from the script restarts the sequence
+	result, err := New().Run(`random.seed(7); let a = random.int(1000); random.seed(7); a == random.int(1000)`)
+	if err != nil || result != object.TRUE {
+		t.Errorf("random.seed did not restart the sequence. got=%v, %v", result, err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "out.txt")
+	src := fmt.Sprintf(`fs.write(%q, strings.join(os.args(), " ")); fs.read(%q)`, file, file)
+	for _, backend := range backends {
+		_, err := New(WithBackend(backend)).Run(src)
+		want := fmt.Sprintf("fs.write: permission denied for %q, needs --allow-write", file)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: sandboxed run. got=%v, want %q", backend, err, want)
+		}
+
+		caps := object.Capabilities{Read: object.Paths{Dirs: []string{dir}}, Write: object.Paths{Dirs: []string{dir}}}
+		result, err := New(WithBackend(backend), WithCapabilities(caps), WithArgs("hi", "there")).Run(src)
+		if got := describe(result, err); got != "hi there" {
+			t.Errorf("%s: granted run. got=%s", backend, got)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	src := `let f = fn(n) { if (n > 2) { os.exit(n) } f(n + 1) }; f(0); puts("unreachable")`
+	for _, backend := range backends {
+		var out bytes.Buffer
+		in := New(WithBackend(backend), WithStdout(&out))
+		_, err := in.Run(src)
+		var exit *ExitError
+		if !errors.As(err, &exit) || exit.Code != 3 {
+			t.Errorf("%s: wrong exit. got=%v", backend, err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("%s: ran past os.exit, printed %q", backend, out.String())
+		}
+
+		//The next run starts over
+		result, err := in.Run(`1`)
+		testInteger(t, backend, result, 1)
+		if err != nil {
+			t.Errorf("%s: run after exit failed: %s", backend, err)
+		}
+	}
+}
+
+func TestRunFileImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.ms": `import "lib.ms"; lib.answer`,
+		"lib.ms":  `export let answer = 42;`,
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := New().RunFile(filepath.Join(dir, "main.ms"))
+	if err != nil {
+		t.Fatalf("run error: %s", err)
+	}
+	testInteger(t, TreeWalker, result, 42)
+}
+
+func testInteger(t *testing.T, backend Backend, obj object.Object, expected int64) {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("%s: object is not Integer. got=%T (%+v)", backend, obj, obj)
+		return
+	}
+	if result.Value != expected {
+		t.Errorf("%s: object has wrong value. got=%d, want=%d", backend, result.Value, expected)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	type point struct {
+		X int `mscript:"x"`
+		Y int `mscript:"y"`
+	}
+
+	for _, backend := range backends {
+		in := New(WithBackend(backend))
+
+		err := in.Register("scale", func(p point, by int) point {
+			return point{X: p.X * by, Y: p.Y * by}
+		})
+		if err != nil {
+			t.Fatalf("%s: register error: %s", backend, err)
+		}
+		if err := in.Register("origin", point{X: 1, Y: 2}); err != nil {
+			t.Fatalf("%s: register error: %s", backend, err)
+		}
+
+		result, err := in.Run(`let p = scale(origin, 3); p["x"] + p["y"]`)
+		if err != nil {
+			t.Fatalf("%s: run error: %s", backend, err)
+		}
+		testInteger(t, backend, result, 9)
+
+		_, err = in.Run(`scale(1, 2)`)
+		if err == nil || err.Error() != "argument 1: cannot convert INTEGER to mscript.point" {
+			t.Errorf("%s: wrong error. got=%v", backend, err)
+		}
+	}
+}
+
+// Script functions passed to Go funcs are called back on both backends
+func TestRegisterCallback(t *testing.T) {
+	for _, backend := range backends {
+		in := New(WithBackend(backend))
+		err := in.Register("mapInts", func(xs []int, f func(int) (int, error)) ([]int, error) {
+			out := make([]int, len(xs))
+			for i, x := range xs {
+				y, err := f(x)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = y
+			}
+			return out, nil
+		})
+		if err != nil {
+			t.Fatalf("%s: register error: %s", backend, err)
+		}
+
+		tests := []struct {
+			input    string
+			expected string
+		}{
+			{`let k = 10; mapInts([1, 2, 3], fn(x) { x * k })`, "[10, 20, 30]"},
+			{`mapInts([1, 2], len)`, "error argument to `len` not supported, got INTEGER"},
+			{`mapInts([1], fn(x) { x + "a" })`, "error type mismatch: INTEGER + STRING"},
+			{`mapInts([1], fn(x) { "a" })`, "error result: cannot convert STRING to int"},
+		}
+		for _, tt := range tests {
+			if got := describe(in.Run(tt.input)); got != tt.expected {
+				t.Errorf("%s: %q: got %s, want %s", backend, tt.input, got, tt.expected)
+			}
+		}
+	}
+
+	//Functions the tree walker made carry their runtime, so hosts can convert them too
+	in := New()
+	if _, err := in.Run(`let inc = fn(x) { x + 1 };`); err != nil {
+		t.Fatal(err)
+	}
+	fn, _ := in.GetGlobal("inc")
+	var inc func(int) int
+	if err := object.ToGo(fn, &inc); err != nil {
+		t.Fatalf("ToGo error: %s", err)
+	}
+	if got := inc(1); got != 2 {
+		t.Errorf("wrong result. got=%d", got)
+	}
+}
+
+func TestMacros(t *testing.T) {
+	for _, backend := range backends {
+		in := New(WithBackend(backend))
+
+		_, err := in.Run(`let unless = macro(cond, then, otherwise) {
+			quote(if (!(unquote(cond))) { unquote(then) } else { unquote(otherwise) })
+		};`)
+		if err != nil {
+			t.Fatalf("%s: run error: %s", backend, err)
+		}
+
+		// Macros stay defined for later runs
+		result, err := in.Run(`unless(1 > 2, 10, 20)`)
+		if err != nil {
+			t.Fatalf("%s: run error: %s", backend, err)
+		}
+		testInteger(t, backend, result, 10)
+
+		_, err = in.Run(`unless(true)`)
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("%s: expected RuntimeError. got=%T (%v)", backend, err, err)
+		}
+	}
+}
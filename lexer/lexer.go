@@ -1,23 +1,40 @@
 package lexer
 
-import "mscript/token"
+import (
+	"mscript/token"
+	"strings"
+)
 
 /*Creating a new type Lexer that is a struct
 input: a string of text to be converted into tokens
 position: the current char index currently at
 readPosition: the next char index (position + 1)
 ch: The char itself
+line, column: where ch is in the input
 */
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
 	ch           byte
+	line         int
+	column       int
+
+	comments []Comment
+	lastLine int //Line of the last token returned, 0 before the first
+}
+
+// A // comment, comments are skipped by NextToken but kept for tools like the formatter
+type Comment struct {
+	Text     string //Including the leading //
+	Line     int
+	Column   int
+	Trailing bool //A token comes before the comment on the same line
 }
 
 //Creating a lexer struct
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 
 	//Load first char and advance readPosition to position + 1
 	l.readChar()
@@ -25,6 +42,12 @@ func New(input string) *Lexer {
 }
 
 func (l *Lexer) readChar() {
+	//Moving past a new line starts the next line
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
 	//If at the end of input return 0 (EOF)
 	//Else advance to next char and increment both indexes
 	if l.readPosition >= len(l.input) {
@@ -34,13 +57,33 @@ func (l *Lexer) readChar() {
 	}
 	l.position = l.readPosition
 	l.readPosition += 1
+	l.column += 1
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	tok := l.nextToken()
+	l.lastLine = tok.Line
+	return tok
+}
 
-	//Skip all white spaces (tabs, new lines, carriage returns, etc...)
+// Returns every comment read so far
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
+func (l *Lexer) nextToken() (tok token.Token) {
+	//Skip all white spaces (tabs, new lines, carriage returns, etc...) and comments
 	l.skipWhiteSpace()
+	for l.ch == '/' && l.peekChar() == '/' {
+		l.readComment()
+		l.skipWhiteSpace()
+	}
+
+	line, column := l.line, l.column
+	defer func() {
+		tok.Line = line
+		tok.Column = column
+	}()
 
 	switch l.ch {
 
@@ -55,12 +98,16 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
 	case '-':
@@ -86,6 +133,10 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
@@ -99,8 +150,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -121,14 +171,40 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-//Reurn the string of the number
-func (l *Lexer) readNumber() string {
+//Reurn the string of the number and whether it is an integer or a float
+//Floats have digits on both sides of the point, so 1.name stays member access
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	//While a continious stream of numbers increment the position
 	position := l.position
+	l.readDigits()
+	tokenType := token.TokenType(token.INT)
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	//An exponent needs digits after the e and its sign
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.readPosition
+		if next < len(l.input) && (l.input[next] == '+' || l.input[next] == '-') {
+			next++
+		}
+		if next < len(l.input) && isDigit(l.input[next]) {
+			tokenType = token.FLOAT
+			for l.readPosition < next {
+				l.readChar()
+			}
+			l.readChar()
+			l.readDigits()
+		}
+	}
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
 func (l *Lexer) readString() string {
@@ -143,6 +219,17 @@ func (l *Lexer) readString() string {
 
 }
 
+// Reads a comment up to the end of the line
+func (l *Lexer) readComment() {
+	comment := Comment{Line: l.line, Column: l.column, Trailing: l.lastLine == l.line}
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	comment.Text = strings.TrimRight(l.input[position:l.position], " \t\r")
+	l.comments = append(l.comments, comment)
+}
+
 //Skip all whitespace
 func (l *Lexer) skipWhiteSpace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
@@ -25,6 +25,9 @@ func TestNextToken(t *testing.T) {
 	10 != 9;	
 	"foobar"
 	"foo bar"
+	[1, 2];
+	{"foo": "bar"}
+	mod.name
 	`
 
 	tests := []struct {
@@ -106,6 +109,20 @@ func TestNextToken(t *testing.T) {
 		{token.SEMICOLON, ";"},
 		{token.STRING, "foobar"},
 		{token.STRING, "foo bar"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "mod"},
+		{token.DOT, "."},
+		{token.IDENT, "name"},
 		{token.EOF, ""},
 	}
 	l := New(input)
@@ -122,3 +139,83 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestPositionsAndComments(t *testing.T) {
+	input := `// leading
+let x = 5; // trailing
+  x
+// last`
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 2, 1},
+		{token.IDENT, 2, 5},
+		{token.ASSIGN, 2, 7},
+		{token.INT, 2, 9},
+		{token.SEMICOLON, 2, 10},
+		{token.IDENT, 3, 3},
+		{token.EOF, 4, 8},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - Tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+
+	expected := []Comment{
+		{Text: "// leading", Line: 1, Column: 1, Trailing: false},
+		{Text: "// trailing", Line: 2, Column: 12, Trailing: true},
+		{Text: "// last", Line: 4, Column: 1, Trailing: false},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expected), len(comments))
+	}
+	for i, c := range expected {
+		if comments[i] != c {
+			t.Errorf("comments[%d] wrong. expected=%+v, got=%+v", i, c, comments[i])
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `3.14 0.5e3 1e-2 2E+8 7 1.name 5e 1..2`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5e3"},
+		{token.FLOAT, "1e-2"},
+		{token.FLOAT, "2E+8"},
+		{token.INT, "7"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENT, "name"},
+		{token.INT, "5"},
+		{token.IDENT, "e"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
@@ -0,0 +1,495 @@
+// Package lineedit reads lines from a terminal with cursor movement, history,
+// reverse search and tab completion. When the input is not a terminal lines
+// are read as they are, so the same code serves pipes and tests.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// How many lines of history are kept in memory and in the history file
+const MaxHistory = 1000
+
+// Completes the word ending at pos in line, pos counts runes
+// Returns the rune index where the completed word starts and the full words it may become
+type Completer func(line string, pos int) (start int, candidates []string)
+
+type Editor struct {
+	out    io.Writer
+	reader *bufio.Reader
+	fd     int //File descriptor of the terminal, -1 when the input is not one
+
+	history     []string
+	historyFile string
+
+	//Called on Tab, nil disables completion
+	Complete Completer
+	//Returns the line as drawn, for coloring it, it must not change what is visible
+	Highlight func(line string) string
+}
+
+// Creates an editor reading keys from in and drawing on out
+// Editing is only enabled when in is a terminal
+func New(in io.Reader, out io.Writer) *Editor {
+	e := &Editor{out: out, reader: bufio.NewReader(in), fd: -1}
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		e.fd = int(f.Fd())
+	}
+	return e
+}
+
+// Reports whether f is a terminal
+func IsTerminal(f *os.File) bool {
+	return isTerminal(int(f.Fd()))
+}
+
+// Reports whether lines are edited in a terminal rather than read from a pipe or file
+func (e *Editor) Interactive() bool {
+	return e.fd >= 0
+}
+
+// Loads the history saved in path, lines added later are appended to it
+func (e *Editor) SetHistoryFile(path string) error {
+	e.historyFile = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	if len(lines) > MaxHistory {
+		lines = lines[len(lines)-MaxHistory:]
+		//Keep the file from growing forever
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+			return err
+		}
+	}
+	e.history = lines
+	return nil
+}
+
+// Adds line to the history unless it is blank or repeats the previous entry
+// Failing to write the history file is not worth interrupting the user for and is ignored
+func (e *Editor) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" || strings.ContainsAny(line, "\r\n") {
+		return
+	}
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+	}
+
+	if e.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// Returns the history, oldest first
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// Shows prompt and returns the line the user enters, without its newline
+// Returns io.EOF at the end of the input or on Ctrl-D in an empty line,
+// and ErrInterrupted on Ctrl-C
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !e.Interactive() {
+		return e.readPlain(prompt)
+	}
+
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlain(prompt)
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+func (e *Editor) readPlain(prompt string) (string, error) {
+	io.WriteString(e.out, prompt)
+	line, err := e.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// Keys that are not characters, below zero so they never clash with a rune
+const (
+	keyUp rune = -1 - iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlG     = 7
+	ctrlH     = 8
+	tab       = 9
+	ctrlJ     = 10
+	ctrlK     = 11
+	ctrlL     = 12
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlR     = 18
+	ctrlU     = 21
+	ctrlW     = 23
+	escape    = 27
+	backspace = 127
+)
+
+// Reads one key, turning escape sequences into the key constants above
+func (e *Editor) readKey() (rune, error) {
+	r, _, err := e.reader.ReadRune()
+	if err != nil || r != escape {
+		return r, err
+	}
+
+	r, _, err = e.reader.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r != '[' && r != 'O' {
+		return keyUnknown, nil
+	}
+
+	//CSI sequences are parameters followed by a final letter or '~'
+	var params strings.Builder
+	for {
+		r, _, err = e.reader.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if (r >= '0' && r <= '9') || r == ';' {
+			params.WriteRune(r)
+			continue
+		}
+		break
+	}
+
+	switch r {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '~':
+		switch params.String() {
+		case "1", "7":
+			return keyHome, nil
+		case "4", "8":
+			return keyEnd, nil
+		case "3":
+			return keyDelete, nil
+		}
+	}
+	return keyUnknown, nil
+}
+
+// The line being edited
+type state struct {
+	prompt string
+	buf    []rune
+	pos    int
+
+	historyIndex int    //len(history) while editing a new line
+	saved        []rune //The new line while browsing the history
+}
+
+// Redraws the prompt and line and puts the cursor back in place
+// Moving left from the end keeps this independent of how wide the prompt is on screen
+func (e *Editor) refresh(s *state) {
+	line := string(s.buf)
+	if e.Highlight != nil {
+		line = e.Highlight(line)
+	}
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", s.prompt, line)
+	if back := len(s.buf) - s.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *Editor) edit(prompt string) (string, error) {
+	s := &state{prompt: prompt, historyIndex: len(e.history)}
+	e.refresh(s)
+
+	lastWasTab := false
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			if err == io.EOF && len(s.buf) > 0 {
+				io.WriteString(e.out, "\r\n")
+				return string(s.buf), nil
+			}
+			return "", err
+		}
+
+		if key == ctrlR {
+			line, done, err := e.search(s)
+			if done || err != nil {
+				return line, err
+			}
+			e.refresh(s)
+			continue
+		}
+
+		isTab := key == tab
+		line, done, err := e.handle(s, key, lastWasTab)
+		if done {
+			return line, err
+		}
+		lastWasTab = isTab
+	}
+}
+
+// Applies key to the line, done reports that the line is finished
+func (e *Editor) handle(s *state, key rune, lastWasTab bool) (line string, done bool, err error) {
+	switch key {
+	case enter, ctrlJ:
+		io.WriteString(e.out, "\r\n")
+		return string(s.buf), true, nil
+
+	case ctrlC:
+		io.WriteString(e.out, "^C\r\n")
+		return "", true, ErrInterrupted
+
+	case ctrlD:
+		if len(s.buf) == 0 {
+			io.WriteString(e.out, "\r\n")
+			return "", true, io.EOF
+		}
+		e.deleteAt(s, s.pos)
+
+	case keyDelete:
+		e.deleteAt(s, s.pos)
+
+	case backspace, ctrlH:
+		if s.pos > 0 {
+			s.pos--
+			e.deleteAt(s, s.pos)
+		}
+
+	case keyLeft, ctrlB:
+		if s.pos > 0 {
+			s.pos--
+		}
+
+	case keyRight, ctrlF:
+		if s.pos < len(s.buf) {
+			s.pos++
+		}
+
+	case keyHome, ctrlA:
+		s.pos = 0
+
+	case keyEnd, ctrlE:
+		s.pos = len(s.buf)
+
+	case ctrlK:
+		s.buf = s.buf[:s.pos]
+
+	case ctrlU:
+		s.buf = append([]rune{}, s.buf[s.pos:]...)
+		s.pos = 0
+
+	case ctrlW:
+		start := s.pos
+		for start > 0 && unicode.IsSpace(s.buf[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(s.buf[start-1]) {
+			start--
+		}
+		s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+		s.pos = start
+
+	case ctrlL:
+		io.WriteString(e.out, "\x1b[H\x1b[2J")
+
+	case keyUp, ctrlP:
+		e.moveHistory(s, -1)
+
+	case keyDown, ctrlN:
+		e.moveHistory(s, 1)
+
+	case tab:
+		e.complete(s, lastWasTab)
+
+	default:
+		//Other control characters and unknown keys are ignored
+		if key < ' ' {
+			return "", false, nil
+		}
+		s.buf = append(s.buf[:s.pos], append([]rune{key}, s.buf[s.pos:]...)...)
+		s.pos++
+	}
+
+	e.refresh(s)
+	return "", false, nil
+}
+
+func (e *Editor) deleteAt(s *state, i int) {
+	if i < len(s.buf) {
+		s.buf = append(s.buf[:i], s.buf[i+1:]...)
+	}
+}
+
+func (e *Editor) moveHistory(s *state, delta int) {
+	next := s.historyIndex + delta
+	if next < 0 || next > len(e.history) {
+		return
+	}
+	if s.historyIndex == len(e.history) {
+		s.saved = append([]rune{}, s.buf...)
+	}
+	s.historyIndex = next
+	if next == len(e.history) {
+		s.buf = s.saved
+	} else {
+		s.buf = []rune(e.history[next])
+	}
+	s.pos = len(s.buf)
+}
+
+// Completes the word before the cursor as far as all candidates agree
+// A second Tab in a row lists the candidates
+func (e *Editor) complete(s *state, lastWasTab bool) {
+	if e.Complete == nil {
+		return
+	}
+	start, candidates := e.Complete(string(s.buf), s.pos)
+	if len(candidates) == 0 || start < 0 || start > s.pos {
+		io.WriteString(e.out, "\a")
+		return
+	}
+
+	prefix := []rune(candidates[0])
+	for _, c := range candidates[1:] {
+		prefix = commonPrefix(prefix, []rune(c))
+	}
+	if len(prefix) > s.pos-start {
+		rest := append([]rune{}, s.buf[s.pos:]...)
+		s.buf = append(append(s.buf[:start], prefix...), rest...)
+		s.pos = start + len(prefix)
+		return
+	}
+
+	if len(candidates) > 1 && lastWasTab {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		return
+	}
+	io.WriteString(e.out, "\a")
+}
+
+func commonPrefix(a, b []rune) []rune {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+// Incremental reverse search through the history, entered with Ctrl-R
+// Enter runs the match, Ctrl-G and Ctrl-C give up and any other key edits the match
+func (e *Editor) search(s *state) (line string, done bool, err error) {
+	var query []rune
+	original, originalPos := append([]rune{}, s.buf...), s.pos
+	match := len(e.history)
+	found := ""
+
+	//Finds the newest entry before from containing the query
+	find := func(from int) {
+		for i := from - 1; i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				match, found = i, e.history[i]
+				return
+			}
+		}
+	}
+	draw := func() {
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", string(query), found)
+	}
+
+	draw()
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			return "", true, err
+		}
+
+		switch {
+		case key == ctrlR:
+			find(match)
+		case key == backspace || key == ctrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match, found = len(e.history), ""
+				if len(query) > 0 {
+					find(match)
+				}
+			}
+		case key == ctrlG || key == ctrlC:
+			s.buf, s.pos = original, originalPos
+			return "", false, nil
+		case key >= ' ' && key != backspace:
+			query = append(query, key)
+			//The current match may still fit the longer query
+			find(min(match+1, len(e.history)))
+		default:
+			if found != "" {
+				s.buf = []rune(found)
+				s.pos = len(s.buf)
+				s.historyIndex = match
+			}
+			if key == enter || key == ctrlJ {
+				io.WriteString(e.out, "\r\n")
+				return string(s.buf), true, nil
+			}
+			e.refresh(s)
+			return e.handle(s, key, false)
+		}
+		draw()
+	}
+}
@@ -0,0 +1,254 @@
+package lineedit
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	up    = "\x1b[A"
+	down  = "\x1b[B"
+	right = "\x1b[C"
+	left  = "\x1b[D"
+	home  = "\x1b[H"
+	end   = "\x1b[F"
+	del   = "\x1b[3~"
+)
+
+func editLine(t *testing.T, e *Editor, input string) (string, error) {
+	t.Helper()
+	e.reader.Reset(strings.NewReader(input))
+	return e.edit("> ")
+}
+
+func TestEditing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"hello\r", "hello"},
+		{"helo" + left + "l\r", "hello"},
+		{"ello" + home + "h" + end + "!\r", "hello!"},
+		{"ello\x01h\x05!\r", "hello!"},
+		{"hellox\x7f\r", "hello"},
+		{"xhello" + home + del + "\r", "hello"},
+		{"hello world\x17\r", "hello "},
+		{"hello world" + home + right + right + right + right + right + "\x0b\r", "hello"},
+		{"junk hello" + left + left + left + left + left + "\x15\r", "hello"},
+		{"héllo\x7f\x7f\x7f\x7fi\r", "hi"},
+		{"ab\x02\x02c\x06\x06d\r", "cabd"},
+		{"a\x1b[5~b\r", "ab"},
+		{"abc", "abc"},
+	}
+
+	for _, tt := range tests {
+		e := New(nil, io.Discard)
+		line, err := editLine(t, e, tt.input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", tt.input, err)
+		}
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. got=%q, want=%q", tt.input, line, tt.expected)
+		}
+	}
+}
+
+func TestInterruptAndEOF(t *testing.T) {
+	e := New(nil, io.Discard)
+	if _, err := editLine(t, e, "abc\x03"); err != ErrInterrupted {
+		t.Errorf("Ctrl-C: expected ErrInterrupted. got=%v", err)
+	}
+	if _, err := editLine(t, e, "\x04"); err != io.EOF {
+		t.Errorf("Ctrl-D: expected io.EOF. got=%v", err)
+	}
+	if line, err := editLine(t, e, "ab\x01\x04\r"); err != nil || line != "b" {
+		t.Errorf("Ctrl-D in a line should delete. got=%q, %v", line, err)
+	}
+	if _, err := editLine(t, e, ""); err != io.EOF {
+		t.Errorf("end of input: expected io.EOF. got=%v", err)
+	}
+}
+
+func TestHistoryNavigation(t *testing.T) {
+	e := New(nil, io.Discard)
+	e.AddHistory("first")
+	e.AddHistory("second")
+	e.AddHistory("second")
+	e.AddHistory("   ")
+
+	if len(e.History()) != 2 {
+		t.Fatalf("blank and repeated lines should not be kept. got=%q", e.History())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{up + "\r", "second"},
+		{up + up + "\r", "first"},
+		{up + up + up + up + "\r", "first"},
+		{"new" + up + down + "\r", "new"},
+		{up + up + down + "!\r", "second!"},
+	}
+	for _, tt := range tests {
+		line, err := editLine(t, e, tt.input)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. got=%q, want=%q", tt.input, line, tt.expected)
+		}
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	e := New(nil, io.Discard)
+	for _, line := range []string{"let apple = 1;", "let banana = 2;", "puts(apple)", "len(banana)"} {
+		e.AddHistory(line)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"\x12app\r", "puts(apple)"},
+		{"\x12app\x12\r", "let apple = 1;"},
+		{"\x12ban" + end + "!\r", "len(banana)!"},
+		{"\x12bann\x7f\x7f\x7fa\x12\r", "let banana = 2;"},
+		{"keep\x12zzz\x07\r", "keep"},
+		{"\x12let\x12\x12\x12\r", "let apple = 1;"},
+	}
+	for _, tt := range tests {
+		line, err := editLine(t, e, tt.input)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. got=%q, want=%q", tt.input, line, tt.expected)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	words := []string{"len", "let", "puts", "print"}
+	var out bytes.Buffer
+	e := New(nil, &out)
+	e.Complete = func(line string, pos int) (int, []string) {
+		runes := []rune(line)
+		start := pos
+		for start > 0 && runes[start-1] != ' ' {
+			start--
+		}
+		prefix := string(runes[start:pos])
+		matches := []string{}
+		for _, w := range words {
+			if strings.HasPrefix(w, prefix) {
+				matches = append(matches, w)
+			}
+		}
+		return start, matches
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"pu\t(1)\r", "puts(1)"},
+		{"x = le\tt\r", "x = let"},
+		{"p\t\r", "p"},
+		{"pr\t" + home + "a \r", "a print"},
+		{"q\t\r", "q"},
+	}
+	for _, tt := range tests {
+		line, err := editLine(t, e, tt.input)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. got=%q, want=%q", tt.input, line, tt.expected)
+		}
+	}
+
+	out.Reset()
+	editLine(t, e, "l\t\t\r")
+	if !strings.Contains(out.String(), "\r\nlen  let\r\n") {
+		t.Errorf("second tab should list candidates. got=%q", out.String())
+	}
+}
+
+func TestPlainInput(t *testing.T) {
+	var out bytes.Buffer
+	e := New(strings.NewReader("one\r\ntwo"), &out)
+	if e.Interactive() {
+		t.Fatalf("a reader is not a terminal")
+	}
+
+	for _, expected := range []string{"one", "two"} {
+		line, err := e.ReadLine("> ")
+		if err != nil || line != expected {
+			t.Errorf("wrong line. got=%q, %v, want=%q", line, err, expected)
+		}
+	}
+	if _, err := e.ReadLine("> "); err != io.EOF {
+		t.Errorf("expected io.EOF. got=%v", err)
+	}
+	if out.String() != "> > > " {
+		t.Errorf("prompts not written to out. got=%q", out.String())
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	e := New(nil, io.Discard)
+	if err := e.SetHistoryFile(path); err != nil {
+		t.Fatalf("missing history file should not be an error: %s", err)
+	}
+	e.AddHistory("let a = 1;")
+	e.AddHistory("a + 1")
+
+	e = New(nil, io.Discard)
+	if err := e.SetHistoryFile(path); err != nil {
+		t.Fatalf("load error: %s", err)
+	}
+	if strings.Join(e.History(), "|") != "let a = 1;|a + 1" {
+		t.Errorf("history not restored. got=%q", e.History())
+	}
+
+	lines := make([]string, MaxHistory+10)
+	for i := range lines {
+		lines[i] = strings.Repeat("x", i+1)
+	}
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	e = New(nil, io.Discard)
+	if err := e.SetHistoryFile(path); err != nil {
+		t.Fatalf("load error: %s", err)
+	}
+	if len(e.History()) != MaxHistory || e.History()[0] != lines[10] {
+		t.Errorf("history not trimmed to %d lines. got=%d", MaxHistory, len(e.History()))
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), "\n") != MaxHistory {
+		t.Errorf("history file not trimmed. got=%d lines", strings.Count(string(data), "\n"))
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	var out bytes.Buffer
+	e := New(nil, &out)
+	e.Highlight = strings.ToUpper
+	line, err := editLine(t, e, "ab"+left+"\r")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if line != "ab" {
+		t.Errorf("Highlight changed the line. got=%q", line)
+	}
+	if !strings.Contains(out.String(), "\r> AB\x1b[K\x1b[1D") {
+		t.Errorf("line not drawn highlighted. got=%q", out.String())
+	}
+}
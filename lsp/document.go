@@ -0,0 +1,116 @@
+package lsp
+
+import (
+	"mscript/analysis"
+	"mscript/ast"
+	"mscript/lexer"
+	"mscript/parser"
+	"strings"
+	"unicode/utf8"
+)
+
+// An open file and what is known about its current text
+type document struct {
+	uri     string
+	version int
+	text    string
+	lines   []string
+
+	program  *ast.Program //What parsed, statements with errors are left out
+	comments []lexer.Comment
+	errors   []parser.Error
+	imports  []*ast.ImportStatement
+	info     *analysis.Info //Of program, so navigation keeps working on the rest of the file while a line is broken
+	lastInfo *analysis.Info //From the last text that parsed, for completing while typing
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri}
+	d.update(version, text)
+	return d
+}
+
+// Replaces the text and analyzes it again
+func (d *document) update(version int, text string) {
+	d.version = version
+	d.text = text
+	d.lines = strings.Split(text, "\n")
+
+	l := lexer.New(text)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	d.errors = p.ErrorList()
+	d.comments = l.Comments()
+	d.imports = nil
+	for _, stmt := range program.Statements {
+		if imp, ok := stmt.(*ast.ImportStatement); ok {
+			d.imports = append(d.imports, imp)
+		}
+	}
+	d.program = program
+	d.info = analysis.Resolve(program)
+	if len(d.errors) == 0 {
+		d.lastInfo = d.info
+	}
+}
+
+// Converts a position of the lexer to one of the protocol, positions past the text are clamped
+func (d *document) toProtocol(pos analysis.Position) Position {
+	line := min(max(pos.Line-1, 0), len(d.lines)-1)
+	text := d.lines[line]
+	column := min(max(pos.Column-1, 0), len(text))
+	return Position{Line: line, Character: utf16Len(text[:column])}
+}
+
+func (d *document) fromProtocol(pos Position) analysis.Position {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return analysis.Position{Line: pos.Line + 1, Column: 1}
+	}
+	text := d.lines[pos.Line]
+	units := 0
+	for i, r := range text {
+		if units >= pos.Character {
+			return analysis.Position{Line: pos.Line + 1, Column: i + 1}
+		}
+		units += utf16RuneLen(r)
+	}
+	return analysis.Position{Line: pos.Line + 1, Column: len(text) + 1}
+}
+
+// The range of length bytes starting at pos
+func (d *document) span(pos analysis.Position, length int) Range {
+	return Range{
+		Start: d.toProtocol(pos),
+		End:   d.toProtocol(analysis.Position{Line: pos.Line, Column: pos.Column + length}),
+	}
+}
+
+// The range of the whole text
+func (d *document) fullRange() Range {
+	last := len(d.lines) - 1
+	return Range{End: Position{Line: last, Character: utf16Len(d.lines[last])}}
+}
+
+// The text of line before pos, for completion
+func (d *document) linePrefix(pos analysis.Position) string {
+	if pos.Line < 1 || pos.Line > len(d.lines) {
+		return ""
+	}
+	text := d.lines[pos.Line-1]
+	return text[:min(pos.Column-1, len(text))]
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,549 @@
+// Package lsp implements a Language Server Protocol server for mScript over a pair of streams.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mscript/analysis"
+	"mscript/ast"
+	"mscript/checker"
+	"mscript/evaluator"
+	"mscript/format"
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/parser"
+	"mscript/token"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Signatures shown when hovering over builtins
+var builtinSignatures = map[string]string{
+	"puts":     "puts(values...)",
+	"readline": "readline()",
+	"len":      "len(value)",
+	"strings":  "strings",
+	"math":     "math",
+	"random":   "random",
+	"fs":       "fs",
+	"os":       "os",
+	"json":     "json",
+	"re":       "re",
+}
+
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	docs        map[string]*document //Open documents by URI
+	initialized bool
+	shutdown    bool
+}
+
+// Creates a server reading requests from in and writing responses and notifications to out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: map[string]*document{}}
+}
+
+type handler func(s *Server, params json.RawMessage) (any, error)
+
+var requests = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"shutdown":                    (*Server).shutdownRequest,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/references":     (*Server).references,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/completion":     (*Server).completion,
+	"textDocument/formatting":     (*Server).formatting,
+}
+
+var notifications = map[string]func(s *Server, params json.RawMessage) error{
+	"initialized":            func(*Server, json.RawMessage) error { return nil },
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// Handles messages until the exit notification or the end of the input
+// Returns nil when the client asked for a shutdown before exiting
+func (s *Server) Serve() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return fmt.Errorf("input closed without exit")
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.respond(nil, nil, &ResponseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+		if msg.ID == nil {
+			s.notification(msg.Method, msg.Params)
+			continue
+		}
+		s.request(msg.ID, msg.Method, msg.Params)
+	}
+}
+
+func (s *Server) request(id *json.RawMessage, method string, params json.RawMessage) {
+	handle, ok := requests[method]
+	switch {
+	case !ok:
+		s.respond(id, nil, &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + method})
+	case !s.initialized && method != "initialize":
+		s.respond(id, nil, &ResponseError{Code: codeNotInitialized, Message: "server not initialized"})
+	case s.shutdown:
+		s.respond(id, nil, &ResponseError{Code: codeInvalidRequest, Message: "server is shutting down"})
+	default:
+		result, err := handle(s, params)
+		if err != nil {
+			respErr, ok := err.(*ResponseError)
+			if !ok {
+				respErr = &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+			}
+			s.respond(id, nil, respErr)
+			return
+		}
+		s.respond(id, result, nil)
+	}
+}
+
+// Notifications have no response, unknown ones and those that fail are dropped
+func (s *Server) notification(method string, params json.RawMessage) {
+	if handle, ok := notifications[method]; ok && s.initialized {
+		handle(s, params)
+	}
+}
+
+func (s *Server) respond(id *json.RawMessage, result any, respErr *ResponseError) {
+	msg := &message{ID: id, Error: respErr}
+	if id == nil {
+		null := json.RawMessage("null")
+		msg.ID = &null
+	}
+	if respErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			data = []byte("null")
+		}
+		msg.Result = data
+	}
+	writeMessage(s.out, msg)
+}
+
+func (s *Server) notify(method string, params any) {
+	data, _ := json.Marshal(params)
+	writeMessage(s.out, &message{Method: method, Params: data})
+}
+
+func (s *Server) initialize(json.RawMessage) (any, error) {
+	s.initialized = true
+	var result InitializeResult
+	result.ServerInfo.Name = "mscript"
+	caps := &result.Capabilities
+	caps.TextDocumentSync = textDocumentSyncFull
+	caps.DefinitionProvider = true
+	caps.ReferencesProvider = true
+	caps.HoverProvider = true
+	caps.DocumentSymbolProvider = true
+	caps.DocumentFormattingProvider = true
+	caps.CompletionProvider.TriggerCharacters = []string{"."}
+	return result, nil
+}
+
+func (s *Server) shutdownRequest(json.RawMessage) (any, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p DidOpenTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	doc := newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text)
+	s.docs[doc.uri] = doc
+	s.publishDiagnostics(doc)
+	return nil
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p DidChangeTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok || len(p.ContentChanges) == 0 {
+		return nil
+	}
+	doc.update(p.TextDocument.Version, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	s.publishDiagnostics(doc)
+	return nil
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p DidCloseTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	return nil
+}
+
+// Sends the parser errors of doc, or what the checks find when it parses
+func (s *Server) publishDiagnostics(doc *document) {
+	diagnostics := []Diagnostic{}
+	for _, err := range doc.errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    doc.span(analysis.Position{Line: err.Line, Column: err.Column}, 1),
+			Severity: SeverityError,
+			Code:     "syntax",
+			Source:   "mscript",
+			Message:  err.Message,
+		})
+	}
+	if len(doc.errors) == 0 {
+		for _, d := range append(analysis.Check(doc.program), checker.Check(doc.program)...) {
+			severity := SeverityError
+			if d.Severity == analysis.Warning {
+				severity = SeverityWarning
+			}
+			diagnostics = append(diagnostics, Diagnostic{
+				Range:    Range{Start: doc.toProtocol(d.Start), End: doc.toProtocol(d.End)},
+				Severity: severity,
+				Code:     d.Check,
+				Source:   "mscript",
+				Message:  d.Message,
+			})
+		}
+	}
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: doc.uri, Version: doc.version, Diagnostics: diagnostics})
+}
+
+// Decodes params holding a document and a position
+// Returns the document and the identifier at the position with its binding, if any
+func (s *Server) lookup(params json.RawMessage, p *TextDocumentPositionParams) (*document, *ast.Identifier, *analysis.Binding, error) {
+	if err := json.Unmarshal(params, p); err != nil {
+		return nil, nil, nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("document not open: %s", p.TextDocument.URI)
+	}
+	ident, b := doc.info.At(doc.fromProtocol(p.Position))
+	return doc, ident, b, nil
+}
+
+// The range of the name of b, for imports without a name the import keyword
+func bindingRange(doc *document, b *analysis.Binding) Range {
+	if b.Ident.Token.Line == 0 {
+		return doc.span(b.Pos, len(b.Import.TokenLiteral()))
+	}
+	return doc.span(b.Pos, len(b.Name))
+}
+
+func identRange(doc *document, ident *ast.Identifier) Range {
+	return doc.span(analysis.Position{Line: ident.Token.Line, Column: ident.Token.Column}, len(ident.Value))
+}
+
+func (s *Server) definition(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	doc, _, b, err := s.lookup(params, &p)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return Location{URI: doc.uri, Range: bindingRange(doc, b)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (any, error) {
+	var p ReferenceParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, _, b, err := s.lookup(params, &p.TextDocumentPositionParams)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	locations := []Location{}
+	if p.Context.IncludeDeclaration {
+		locations = append(locations, Location{URI: doc.uri, Range: bindingRange(doc, b)})
+	}
+	for _, use := range b.Uses {
+		locations = append(locations, Location{URI: doc.uri, Range: identRange(doc, use)})
+	}
+	return locations, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	doc, ident, b, err := s.lookup(params, &p)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+
+	var text string
+	switch {
+	case b == nil:
+		signature, ok := builtinSignatures[ident.Value]
+		if !ok {
+			if object.GetBuiltinByName(ident.Value) == nil {
+				return nil, nil
+			}
+			signature = ident.Value + "(...)"
+		}
+		text = "```mscript\n" + signature + "\n```\nbuiltin"
+	default:
+		text = "```mscript\n" + describe(b) + "\n```"
+		if docs := docComment(doc, b); docs != "" && b.Kind != analysis.Parameter {
+			text += "\n" + docs
+		}
+	}
+	r := identRange(doc, ident)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}, nil
+}
+
+// A one line summary of b, with the signature for functions
+func describe(b *analysis.Binding) string {
+	switch b.Kind {
+	case analysis.Parameter:
+		return "parameter " + b.Name
+	case analysis.Import:
+		return b.Import.String()
+	}
+	prefix := "let "
+	if b.Exported {
+		prefix = "export let "
+	}
+	switch value := b.Value.(type) {
+	case *ast.FunctionLiteral:
+		return prefix + b.Name + " = fn(" + paramList(value.Parameters) + ")"
+	case *ast.MacroLiteral:
+		return prefix + b.Name + " = macro(" + paramList(value.Parameters) + ")"
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean:
+		return prefix + b.Name + " = " + value.String()
+	}
+	return prefix + b.Name
+}
+
+func paramList(params []*ast.Identifier) string {
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+// The comment lines right above the binding, without the slashes
+func docComment(doc *document, b *analysis.Binding) string {
+	byLine := map[int]string{}
+	for _, c := range doc.comments {
+		if !c.Trailing {
+			byLine[c.Line] = strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		}
+	}
+	var lines []string
+	for line := b.Pos.Line - 1; ; line-- {
+		text, ok := byLine[line]
+		if !ok {
+			break
+		}
+		lines = append([]string{text}, lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (any, error) {
+	var p DocumentSymbolParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, fmt.Errorf("document not open: %s", p.TextDocument.URI)
+	}
+	return symbols(doc, doc.program), nil
+}
+
+// The lets and imports in node, those inside functions are children of the let of the function
+func symbols(doc *document, node ast.Node) []DocumentSymbol {
+	result := []DocumentSymbol{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			name := identRange(doc, n.Name)
+			sym := DocumentSymbol{Name: n.Name.Value, Kind: SymbolVariable, Range: name, SelectionRange: name}
+			var body *ast.BlockStatement
+			switch value := n.Value.(type) {
+			case *ast.FunctionLiteral:
+				sym.Kind, sym.Detail, body = SymbolFunction, "fn("+paramList(value.Parameters)+")", value.Body
+			case *ast.MacroLiteral:
+				sym.Kind, sym.Detail, body = SymbolFunction, "macro("+paramList(value.Parameters)+")", value.Body
+			}
+			if body != nil {
+				start := analysis.Position{Line: n.Token.Line, Column: n.Token.Column}
+				end := analysis.Position{Line: body.Rbrace.Line, Column: body.Rbrace.Column + 1}
+				sym.Range = Range{Start: doc.toProtocol(start), End: doc.toProtocol(end)}
+			}
+			sym.Children = symbols(doc, n.Value)
+			result = append(result, sym)
+			return false
+
+		case *ast.ImportStatement:
+			pos := analysis.Position{Line: n.Token.Line, Column: n.Token.Column}
+			sym := DocumentSymbol{Name: n.Name.Value, Detail: n.Path, Kind: SymbolModule, Range: doc.span(pos, len(n.TokenLiteral()))}
+			if n.Name.Token.Line != 0 {
+				sym.Range = identRange(doc, n.Name)
+			}
+			sym.SelectionRange = sym.Range
+			result = append(result, sym)
+			return false
+		}
+		return true
+	})
+	return result
+}
+
+func (s *Server) completion(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, fmt.Errorf("document not open: %s", p.TextDocument.URI)
+	}
+	pos := doc.fromProtocol(p.Position)
+	prefix := doc.linePrefix(pos)
+	word := prefix[len(strings.TrimRightFunc(prefix, isWordRune)):]
+
+	items := []CompletionItem{}
+	add := func(item CompletionItem) {
+		if strings.HasPrefix(item.Label, word) {
+			items = append(items, item)
+		}
+	}
+
+	//After module. only the exports of the module make sense
+	if before := strings.TrimSuffix(prefix, word); strings.HasSuffix(before, ".") {
+		module := before[len(strings.TrimRightFunc(before[:len(before)-1], isWordRune)) : len(before)-1]
+		for _, name := range s.exports(doc, module) {
+			add(CompletionItem{Label: name, Kind: CompletionVariable, Detail: "export of " + module})
+		}
+		return items, nil
+	}
+
+	if info := doc.lastInfo; info != nil {
+		for _, b := range info.ScopeAt(pos).Visible() {
+			item := CompletionItem{Label: b.Name, Kind: CompletionVariable, Detail: describe(b)}
+			switch b.Value.(type) {
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				item.Kind = CompletionFunction
+			}
+			if b.Kind == analysis.Import {
+				item.Kind = CompletionModule
+			}
+			add(item)
+		}
+	}
+	for _, def := range object.Builtins {
+		kind := CompletionFunction
+		if _, ok := def.Value.(*object.Module); ok {
+			kind = CompletionModule
+		}
+		add(CompletionItem{Label: def.Name, Kind: kind, Detail: "builtin"})
+	}
+	for _, keyword := range token.Keywords() {
+		add(CompletionItem{Label: keyword, Kind: CompletionKeyword})
+	}
+	return items, nil
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9'
+}
+
+// The names exported by the module imported as name in doc, read from the file of the module
+func (s *Server) exports(doc *document, name string) []string {
+	var imp *ast.ImportStatement
+	for _, i := range doc.imports {
+		if i.Name.Value == name {
+			imp = i
+		}
+	}
+	if imp == nil {
+		//Builtin modules are there without an import
+		module, ok := object.GetBuiltinByName(name).(*object.Module)
+		if !ok {
+			return nil
+		}
+		var names []string
+		for name := range module.Exports {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	from := ""
+	if u, err := url.Parse(doc.uri); err == nil && u.Scheme == "file" {
+		from = filepath.FromSlash(u.Path)
+	}
+	loader := evaluator.NewModuleLoader(evaluator.DefaultSearchPath()...)
+	file, err := loader.Resolve(imp.Path, from)
+	if err != nil {
+		return nil
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	program := parser.New(lexer.New(string(src))).ParseProgram()
+
+	var names []string
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			names = append(names, export.Statement.Name.Value)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) formatting(params json.RawMessage) (any, error) {
+	var p DocumentFormattingParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, fmt.Errorf("document not open: %s", p.TextDocument.URI)
+	}
+	//Tabs unless the client asks for spaces
+	indent := "\t"
+	if p.Options.InsertSpaces && p.Options.TabSize > 0 {
+		indent = strings.Repeat(" ", p.Options.TabSize)
+	}
+	formatted, err := format.SourceIndented([]byte(doc.text), indent)
+	if err != nil || string(formatted) == doc.text {
+		//Text that does not parse is left alone, the diagnostics already say why
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: doc.fullRange(), NewText: string(formatted)}}, nil
+}
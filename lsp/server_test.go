@@ -0,0 +1,385 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mscript/analysis"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///tmp/test.ms"
+
+// Frames each message as a client would send it
+func frame(t *testing.T, messages ...map[string]any) io.Reader {
+	t.Helper()
+	var in bytes.Buffer
+	for _, msg := range messages {
+		msg["jsonrpc"] = "2.0"
+		body, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	return &in
+}
+
+func request(id int, method string, params any) map[string]any {
+	return map[string]any{"id": id, "method": method, "params": params}
+}
+
+func notification(method string, params any) map[string]any {
+	return map[string]any{"method": method, "params": params}
+}
+
+func position(line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": testURI},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+// Runs a session that opens text, sends messages and exits
+// Returns the responses by id and the diagnostics published last
+func session(t *testing.T, text string, messages ...map[string]any) (map[int]json.RawMessage, []Diagnostic) {
+	t.Helper()
+	all := []map[string]any{
+		request(0, "initialize", map[string]any{}),
+		notification("initialized", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": testURI, "languageId": "mscript", "version": 1, "text": text},
+		}),
+	}
+	all = append(all, messages...)
+	all = append(all, request(-1, "shutdown", nil), notification("exit", nil))
+
+	var out bytes.Buffer
+	if err := NewServer(frame(t, all...), &out).Serve(); err != nil {
+		t.Fatalf("Serve: %s", err)
+	}
+
+	responses := map[int]json.RawMessage{}
+	var diagnostics []Diagnostic
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readMessage(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading output: %s", err)
+		}
+		var msg struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+			Error  *ResponseError  `json:"error"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("invalid output %s: %s", body, err)
+		}
+		if msg.Error != nil {
+			t.Fatalf("error response: %s", msg.Error.Message)
+		}
+		if msg.Method == "textDocument/publishDiagnostics" {
+			var p PublishDiagnosticsParams
+			json.Unmarshal(msg.Params, &p)
+			diagnostics = p.Diagnostics
+			continue
+		}
+		responses[*msg.ID] = msg.Result
+	}
+	return responses, diagnostics
+}
+
+func decode[T any](t *testing.T, data json.RawMessage) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("cannot decode %s: %s", data, err)
+	}
+	return v
+}
+
+func TestInitialize(t *testing.T) {
+	responses, _ := session(t, "")
+	result := decode[InitializeResult](t, responses[0])
+	caps := result.Capabilities
+	if caps.TextDocumentSync != textDocumentSyncFull || !caps.DefinitionProvider || !caps.HoverProvider ||
+		!caps.ReferencesProvider || !caps.DocumentSymbolProvider || !caps.DocumentFormattingProvider {
+		t.Errorf("missing capabilities: %+v", caps)
+	}
+	if string(responses[-1]) != "null" {
+		t.Errorf("shutdown result = %s, want null", responses[-1])
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1;\nputs(x);", nil},
+		{"let x = ;", []string{"0:8 syntax: no prefix parse function for ;"}},
+		{"let s = \"é\"; puts(s, y);", []string{"0:21 undefined: identifier not found: y"}},
+		{"let n: int = \"1\";\nputs(n + 1);", []string{"0:13 type: cannot use string as int in let n"}},
+	}
+
+	for _, tt := range tests {
+		_, diagnostics := session(t, tt.input)
+		var got []string
+		for _, d := range diagnostics {
+			got = append(got, fmt.Sprintf("%d:%d %s: %s", d.Range.Start.Line, d.Range.Start.Character, d.Code, d.Message))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: wrong diagnostics.\ngot= %q\nwant=%q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestDiagnosticsOnChange(t *testing.T) {
+	_, diagnostics := session(t, "let x = 1;", notification("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": testURI, "version": 2},
+		"contentChanges": []map[string]any{{"text": "z"}},
+	}))
+	if len(diagnostics) != 1 || diagnostics[0].Message != "identifier not found: z" {
+		t.Errorf("wrong diagnostics after change: %+v", diagnostics)
+	}
+}
+
+const program = `// Adds two numbers
+let add = fn(a, b) {
+  a + b
+};
+let x = add(1, 2);
+puts(add(x, x));
+`
+
+func TestDefinitionAndReferences(t *testing.T) {
+	refs := position(4, 9)
+	refs["context"] = map[string]any{"includeDeclaration": true}
+	responses, _ := session(t, program,
+		request(1, "textDocument/definition", position(5, 6)),
+		request(2, "textDocument/definition", position(2, 6)),
+		request(3, "textDocument/references", refs),
+		request(4, "textDocument/definition", position(5, 0)),
+	)
+
+	def := decode[Location](t, responses[1])
+	if def.URI != testURI || def.Range != (Range{Position{1, 4}, Position{1, 7}}) {
+		t.Errorf("definition of add: %+v", def)
+	}
+	def = decode[Location](t, responses[2])
+	if def.Range != (Range{Position{1, 16}, Position{1, 17}}) {
+		t.Errorf("definition of b: %+v", def)
+	}
+
+	locations := decode[[]Location](t, responses[3])
+	var got []Position
+	for _, l := range locations {
+		got = append(got, l.Range.Start)
+	}
+	expected := []Position{{1, 4}, {4, 8}, {5, 5}}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("references of add: got=%v, want=%v", got, expected)
+	}
+
+	if string(responses[4]) != "null" {
+		t.Errorf("definition of a builtin = %s, want null", responses[4])
+	}
+}
+
+func TestHover(t *testing.T) {
+	responses, _ := session(t, program,
+		request(1, "textDocument/hover", position(4, 9)),
+		request(2, "textDocument/hover", position(5, 1)),
+		request(3, "textDocument/hover", position(2, 2)),
+		request(4, "textDocument/hover", position(0, 3)),
+	)
+
+	tests := []struct {
+		id       int
+		expected string
+	}{
+		{1, "```mscript\nlet add = fn(a, b)\n```\nAdds two numbers"},
+		{2, "```mscript\nputs(values...)\n```\nbuiltin"},
+		{3, "```mscript\nparameter a\n```"},
+	}
+	for _, tt := range tests {
+		hover := decode[Hover](t, responses[tt.id])
+		if hover.Contents.Value != tt.expected {
+			t.Errorf("hover %d: got=%q, want=%q", tt.id, hover.Contents.Value, tt.expected)
+		}
+	}
+	if string(responses[4]) != "null" {
+		t.Errorf("hover over a comment = %s, want null", responses[4])
+	}
+}
+
+func TestNavigationWithSyntaxError(t *testing.T) {
+	refs := position(0, 4)
+	refs["context"] = map[string]any{"includeDeclaration": true}
+	responses, diagnostics := session(t, "let add = fn(a, b) { a + b };\nlet y = ;\nputs(add(1, 2));",
+		request(1, "textDocument/definition", position(2, 6)),
+		request(2, "textDocument/references", refs),
+		request(3, "textDocument/hover", position(2, 6)),
+	)
+
+	if len(diagnostics) == 0 {
+		t.Errorf("no diagnostics for the broken line")
+	}
+	def := decode[Location](t, responses[1])
+	if def.Range != (Range{Position{0, 4}, Position{0, 7}}) {
+		t.Errorf("definition of add: %+v", def)
+	}
+	if locations := decode[[]Location](t, responses[2]); len(locations) != 2 {
+		t.Errorf("references of add: %+v", locations)
+	}
+	if hover := decode[Hover](t, responses[3]); !strings.Contains(hover.Contents.Value, "let add = fn(a, b)") {
+		t.Errorf("hover over add: %q", hover.Contents.Value)
+	}
+}
+
+func TestTruncatedDocuments(t *testing.T) {
+	symbols := map[string]any{"textDocument": map[string]any{"uri": testURI}}
+	for i := range program {
+		session(t, program[:i],
+			request(1, "textDocument/hover", position(2, 2)),
+			request(2, "textDocument/documentSymbol", symbols),
+		)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	responses, _ := session(t, "import \"lib.ms\" as lib;\nlet f = fn(n) {\n  let double = n * 2;\n  double\n};\nlet y = 1;",
+		request(1, "textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": testURI}}))
+
+	symbols := decode[[]DocumentSymbol](t, responses[1])
+	if len(symbols) != 3 {
+		t.Fatalf("wrong number of symbols: %+v", symbols)
+	}
+	if symbols[0].Name != "lib" || symbols[0].Kind != SymbolModule {
+		t.Errorf("symbols[0] = %+v", symbols[0])
+	}
+	f := symbols[1]
+	if f.Name != "f" || f.Kind != SymbolFunction || f.Detail != "fn(n)" || f.Range.End != (Position{4, 1}) {
+		t.Errorf("symbols[1] = %+v", f)
+	}
+	if len(f.Children) != 1 || f.Children[0].Name != "double" || f.Children[0].Kind != SymbolVariable {
+		t.Errorf("children of f = %+v", f.Children)
+	}
+	if symbols[2].Name != "y" {
+		t.Errorf("symbols[2] = %+v", symbols[2])
+	}
+}
+
+func labels(t *testing.T, data json.RawMessage) []string {
+	var got []string
+	for _, item := range decode[[]CompletionItem](t, data) {
+		got = append(got, item.Label)
+	}
+	return got
+}
+
+func TestCompletion(t *testing.T) {
+	responses, _ := session(t, "let length = 1;\nlet f = fn(lemma) {\n  le\n};\nle",
+		request(1, "textDocument/completion", position(2, 4)),
+		request(2, "textDocument/completion", position(4, 2)),
+	)
+
+	if got := labels(t, responses[1]); fmt.Sprint(got) != "[lemma length len let]" {
+		t.Errorf("completions in f: %v", got)
+	}
+	if got := labels(t, responses[2]); fmt.Sprint(got) != "[length len let]" {
+		t.Errorf("completions at the top level: %v", got)
+	}
+}
+
+func TestCompleteModuleExports(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "lib.ms"), []byte("export let area = 1;\nlet hidden = 2;\nexport let add = fn() {};"), 0o644)
+
+	uri := "file://" + filepath.ToSlash(filepath.Join(dir, "main.ms"))
+	p := position(1, 4)
+	p["textDocument"] = map[string]any{"uri": uri}
+	var out bytes.Buffer
+	server := NewServer(frame(t,
+		request(0, "initialize", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "version": 1, "text": "import \"lib.ms\";\nlib."},
+		}),
+		request(1, "textDocument/completion", p),
+	), &out)
+	server.Serve()
+
+	result := out.String()[strings.LastIndex(out.String(), "Content-Length"):]
+	body, err := readMessage(bufio.NewReader(strings.NewReader(result)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg message
+	json.Unmarshal(body, &msg)
+	if got := labels(t, msg.Result); fmt.Sprint(got) != "[add area]" {
+		t.Errorf("completions after lib.: %v", got)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	params := map[string]any{"textDocument": map[string]any{"uri": testURI}}
+	responses, _ := session(t, "let x=1;\nputs( x )", request(1, "textDocument/formatting", params))
+
+	edits := decode[[]TextEdit](t, responses[1])
+	if len(edits) != 1 {
+		t.Fatalf("wrong number of edits: %+v", edits)
+	}
+	if edits[0].NewText != "let x = 1;\nputs(x);\n" || edits[0].Range.End != (Position{1, 9}) {
+		t.Errorf("wrong edit: %+v", edits[0])
+	}
+
+	params["options"] = map[string]any{"tabSize": 2, "insertSpaces": true}
+	responses, _ = session(t, "let f = fn() {\n\tif (true) { 1 }\n};", request(1, "textDocument/formatting", params))
+	edits = decode[[]TextEdit](t, responses[1])
+	if len(edits) != 1 || edits[0].NewText != "let f = fn() {\n  if (true) {\n    1;\n  }\n};\n" {
+		t.Errorf("edits with spaces: %+v", edits)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	var out bytes.Buffer
+	err := NewServer(frame(t,
+		request(1, "textDocument/hover", position(0, 0)),
+		request(2, "initialize", map[string]any{}),
+		request(3, "workspace/unknown", nil),
+		notification("exit", nil),
+	), &out).Serve()
+	if err == nil {
+		t.Errorf("exit without shutdown returned no error")
+	}
+	for _, code := range []string{`"code":-32002`, `"code":-32601`} {
+		if !strings.Contains(out.String(), code) {
+			t.Errorf("no error with %s in %s", code, out.String())
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	doc := newDocument(testURI, 1, "let s = \"a😀b\";\nx")
+	//The emoji is 4 bytes and 2 UTF-16 units
+	pos := doc.toProtocol(analysis.Position{Line: 1, Column: 15})
+	if pos != (Position{0, 12}) {
+		t.Errorf("toProtocol = %+v", pos)
+	}
+	if back := doc.fromProtocol(pos); back != (analysis.Position{Line: 1, Column: 15}) {
+		t.Errorf("fromProtocol = %+v", back)
+	}
+	if pos := doc.toProtocol(analysis.Position{Line: 9, Column: 9}); pos != (Position{1, 1}) {
+		t.Errorf("clamped toProtocol = %+v", pos)
+	}
+}
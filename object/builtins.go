@@ -0,0 +1,75 @@
+package object
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Builtins shared by the evaluator and the vm, functions and modules grouping more of them
+// The vm refers to them by index so new builtins must be appended at the end
+var Builtins = []struct {
+	Name  string
+	Value Object //A *Builtin or a *Module
+}{
+	{
+		"puts",
+		&Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			for _, arg := range args {
+				fmt.Fprintln(rt.Stdout, arg.Inspect())
+			}
+			return nil
+		}},
+	},
+	{
+		"readline",
+		&Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			line, err := rt.ReadLine()
+			if err != nil {
+				return nil
+			}
+			return &String{Value: line}
+		}},
+	},
+	{
+		"len",
+		&Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *String:
+				return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *Array:
+				return &Integer{Value: int64(len(arg.Elements))}
+			case *Hash:
+				return &Integer{Value: int64(len(arg.Pairs))}
+			default:
+				return newError("argument to `len` not supported, got %s", args[0].Type())
+			}
+		}},
+	},
+	{"strings", stringsModule},
+	{"math", mathModule},
+	{"random", randomModule},
+	{"fs", fsModule},
+	{"os", osModule},
+	{"json", jsonModule},
+	{"re", reModule},
+}
+
+// Returns the builtin function or module called name or nil
+func GetBuiltinByName(name string) Object {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Value
+		}
+	}
+	return nil
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
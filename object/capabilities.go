@@ -0,0 +1,60 @@
+package object
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Capabilities are what the fs and os modules may do, the zero value allows nothing
+// Hosts grant them, scripts cannot change them
+type Capabilities struct {
+	Read  Paths //Files fs.read, fs.exists and fs.list may look at
+	Write Paths //Files fs.write and fs.remove may change
+	Env   bool  //Reading environment variables with os.env
+	Exec  bool  //Running programs with os.exec
+}
+
+// Paths grants access to every file inside one of Dirs, or to every file when All is set
+type Paths struct {
+	All  bool
+	Dirs []string
+}
+
+// Reports whether path is inside one of the granted directories
+// Symbolic links are followed first so a link cannot point out of a directory
+func (p Paths) Allows(path string) bool {
+	if p.All {
+		return true
+	}
+	target, err := realPath(path)
+	if err != nil {
+		return false
+	}
+	for _, dir := range p.Dirs {
+		root, err := realPath(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, target)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// The absolute path with links resolved, a file that does not exist yet is resolved through its directory
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
@@ -0,0 +1,559 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Struct tag naming the hash key of a field, "-" skips the field
+//
+//	type User struct {
+//		Name  string `mscript:"name"`
+//		Token string `mscript:"-"`
+//	}
+const StructTag = "mscript"
+
+var (
+	objectType  = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	runtimeType = reflect.TypeOf((*Runtime)(nil))
+)
+
+// Converts a Go value to an object
+//
+// Integers become INTEGER, floats FLOAT, slices and arrays ARRAY, maps and structs HASH
+// and funcs builtins wrapped with WrapFunc. nil and nil pointers become null, objects are
+// returned unchanged.
+func FromGo(v any) (Object, error) {
+	if v == nil {
+		return NULL, nil
+	}
+	return fromValue(reflect.ValueOf(v))
+}
+
+func fromValue(v reflect.Value) (Object, error) {
+	if v.Type().Implements(objectType) {
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return TRUE, nil
+		}
+		return FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER: out of range", v.Uint())
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return &String{Value: string(v.Bytes())}, nil
+		}
+		return fromSequence(v)
+
+	case reflect.Array:
+		return fromSequence(v)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		hash := NewHash()
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := fromValue(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			hashKey, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			value, err := fromValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(hashKey, value)
+		}
+		return hash, nil
+
+	case reflect.Struct:
+		hash := NewHash()
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			value, err := fromValue(v.Field(i))
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			hash.Set(&String{Value: name}, value)
+		}
+		return hash, nil
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromValue(v.Elem())
+
+	case reflect.Func:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return WrapFunc(v.Interface())
+
+	default:
+		return nil, fmt.Errorf("cannot convert Go value of type %s", v.Type())
+	}
+}
+
+func fromSequence(v reflect.Value) (Object, error) {
+	elements := make([]Object, v.Len())
+	for i := range elements {
+		el, err := fromValue(v.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %w", i, err)
+		}
+		elements[i] = el
+	}
+	return &Array{Elements: elements}, nil
+}
+
+// Returns the hash key used for a struct field, false if the field is skipped
+func fieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag := field.Tag.Get(StructTag)
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
+
+// Stores obj in the Go value target points to, converting it to the target type
+//
+// Targets of type any receive int64, float64, string, bool, nil, []any and
+// map[string]any (map[any]any when a hash has keys other than strings).
+//
+// Builtins and functions convert to func targets whose results are (), (T), (error) or
+// (T, error). Calling the func converts its arguments with FromGo and calls the script
+// through Runtime.Call, an error of the script is returned as the error result or, when
+// the func has none, panics. Functions of the tree walker carry their runtime, compiled
+// functions of the vm only convert as arguments of a WrapFunc builtin, which gets one.
+func ToGo(obj Object, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return errors.New("ToGo target must be a non-nil pointer")
+	}
+	return toValue(obj, v.Elem(), nil)
+}
+
+func toValue(obj Object, v reflect.Value, rt *Runtime) error {
+	if obj == nil {
+		obj = NULL
+	}
+
+	//Objects are stored as they are when the target can hold them
+	if v.Type().Implements(objectType) || v.Type() == objectType {
+		if !reflect.TypeOf(obj).AssignableTo(v.Type()) {
+			return mismatch(obj, v.Type())
+		}
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if obj.Type() == NULL_OBJ {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return mismatch(obj, v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return mismatch(obj, v.Type())
+		}
+		v.SetBool(b.Value)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(obj, v.Type())
+		}
+		if v.OverflowInt(i.Value) {
+			return fmt.Errorf("cannot convert %d to %s: out of range", i.Value, v.Type())
+		}
+		v.SetInt(i.Value)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(obj, v.Type())
+		}
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return fmt.Errorf("cannot convert %d to %s: out of range", i.Value, v.Type())
+		}
+		v.SetUint(uint64(i.Value))
+
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *Float:
+			v.SetFloat(n.Value)
+		case *Integer:
+			v.SetFloat(float64(n.Value))
+		default:
+			return mismatch(obj, v.Type())
+		}
+
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch(obj, v.Type())
+		}
+		v.SetString(s.Value)
+
+	case reflect.Slice:
+		if s, ok := obj.(*String); ok && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s.Value))
+			return nil
+		}
+		array, ok := obj.(*Array)
+		if !ok {
+			return mismatch(obj, v.Type())
+		}
+		slice := reflect.MakeSlice(v.Type(), len(array.Elements), len(array.Elements))
+		for i, el := range array.Elements {
+			if err := toValue(el, slice.Index(i), rt); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+
+	case reflect.Array:
+		array, ok := obj.(*Array)
+		if !ok {
+			return mismatch(obj, v.Type())
+		}
+		if len(array.Elements) != v.Len() {
+			return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(array.Elements), v.Type())
+		}
+		for i, el := range array.Elements {
+			if err := toValue(el, v.Index(i), rt); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(obj, v.Type())
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(hash.Pairs))
+		for _, pair := range hash.SortedPairs() {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := toValue(pair.Key, key, rt); err != nil {
+				return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := toValue(pair.Value, value, rt); err != nil {
+				return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+
+	case reflect.Struct:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(obj, v.Type())
+		}
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			value, ok := hash.Get(&String{Value: name})
+			if !ok {
+				continue
+			}
+			if err := toValue(value, v.Field(i), rt); err != nil {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+		}
+
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := toValue(obj, elem.Elem(), rt); err != nil {
+			return err
+		}
+		v.Set(elem)
+
+	case reflect.Func:
+		return toFunc(obj, v, rt)
+
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return mismatch(obj, v.Type())
+		}
+		value, err := toNative(obj)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(value))
+		}
+
+	default:
+		return mismatch(obj, v.Type())
+	}
+
+	return nil
+}
+
+// Sets v to a Go func calling the builtin or function obj
+func toFunc(obj Object, v reflect.Value, rt *Runtime) error {
+	t := v.Type()
+	if t.NumOut() > 2 || (t.NumOut() == 2 && t.Out(1) != errorType) {
+		return fmt.Errorf("cannot convert %s to %s: results must be (), (T), (error) or (T, error)", obj.Type(), t)
+	}
+
+	var call func(args []Object) Object
+	switch fn := obj.(type) {
+	case *Builtin:
+		if rt == nil {
+			rt = NewRuntime()
+		}
+		call = func(args []Object) Object { return fn.Fn(rt, args...) }
+	case *Function, *Closure:
+		if function, ok := fn.(*Function); ok && rt == nil {
+			rt = function.Env.Runtime()
+		}
+		if rt == nil || rt.Call == nil {
+			return fmt.Errorf("cannot convert %s to %s: no runtime to call it with", obj.Type(), t)
+		}
+		call = func(args []Object) Object { return rt.Call(fn, args...) }
+	default:
+		return mismatch(obj, t)
+	}
+
+	v.Set(reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		var args []Object
+		for i, value := range in {
+			values := []reflect.Value{value}
+			if t.IsVariadic() && i == len(in)-1 {
+				values = values[:0]
+				for j := 0; j < value.Len(); j++ {
+					values = append(values, value.Index(j))
+				}
+			}
+			for _, value := range values {
+				arg, err := fromValue(value)
+				if err != nil {
+					return funcResults(t, nil, fmt.Errorf("argument %d: %w", len(args)+1, err), rt)
+				}
+				args = append(args, arg)
+			}
+		}
+
+		result := call(args)
+		if errObj, ok := result.(*Error); ok {
+			return funcResults(t, nil, errors.New(errObj.Message), rt)
+		}
+		return funcResults(t, result, nil, rt)
+	}))
+	return nil
+}
+
+// The results of a func made by toFunc, result converted to the first unless err is set
+func funcResults(t reflect.Type, result Object, err error, rt *Runtime) []reflect.Value {
+	out := make([]reflect.Value, t.NumOut())
+	for i := range out {
+		out[i] = reflect.New(t.Out(i)).Elem()
+	}
+	if err == nil && t.NumOut() > 0 && t.Out(0) != errorType {
+		if convErr := toValue(result, out[0], rt); convErr != nil {
+			err = fmt.Errorf("result: %w", convErr)
+		}
+	}
+	if err != nil {
+		if t.NumOut() == 0 || t.Out(t.NumOut()-1) != errorType {
+			panic(err)
+		}
+		out[len(out)-1].Set(reflect.ValueOf(&err).Elem())
+	}
+	return out
+}
+
+// Converts obj to the Go value it is closest to, used for targets of type any
+func toNative(obj Object) (any, error) {
+	switch obj := obj.(type) {
+	case *Null:
+		return nil, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Array:
+		elements := make([]any, len(obj.Elements))
+		for i, el := range obj.Elements {
+			value, err := toNative(el)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *Hash:
+		stringKeys := true
+		for _, pair := range obj.Pairs {
+			if pair.Key.Type() != STRING_OBJ {
+				stringKeys = false
+			}
+		}
+		if stringKeys {
+			m := make(map[string]any, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				value, err := toNative(pair.Value)
+				if err != nil {
+					return nil, err
+				}
+				m[pair.Key.(*String).Value] = value
+			}
+			return m, nil
+		}
+		m := make(map[any]any, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, _ := toNative(pair.Key)
+			value, err := toNative(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Go value", obj.Type())
+	}
+}
+
+func mismatch(obj Object, t reflect.Type) error {
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+}
+
+// Turns a Go function into a builtin
+//
+// Arguments are converted with ToGo and checked against the parameter types, variadic
+// functions are supported. The function may return nothing, a value, an error or a
+// value and an error. A non-nil error is returned to the script as an error object.
+// A first parameter of type *Runtime receives the runtime of the calling script.
+func WrapFunc(fn any) (*Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("WrapFunc needs a function, got %T", fn)
+	}
+	t := v.Type()
+
+	numOut := t.NumOut()
+	if numOut > 2 || (numOut == 2 && t.Out(1) != errorType) {
+		return nil, fmt.Errorf("cannot wrap %s: results must be (), (T), (error) or (T, error)", t)
+	}
+
+	withRuntime := t.NumIn() > 0 && t.In(0) == runtimeType
+	params := t.NumIn()
+	if withRuntime {
+		params--
+	}
+
+	return &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+		if t.IsVariadic() {
+			if len(args) < params-1 {
+				return newError("wrong number of arguments. got=%d, want at least %d", len(args), params-1)
+			}
+		} else if len(args) != params {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), params)
+		}
+
+		in := make([]reflect.Value, 0, t.NumIn())
+		if withRuntime {
+			in = append(in, reflect.ValueOf(rt))
+		}
+		for i, arg := range args {
+			paramType := paramTypeAt(t, len(in))
+			value := reflect.New(paramType).Elem()
+			if err := toValue(arg, value, rt); err != nil {
+				return newError("argument %d: %s", i+1, err)
+			}
+			in = append(in, value)
+		}
+
+		return fromResults(v.Call(in))
+	}}, nil
+}
+
+// Type of the i'th argument, arguments past the last parameter of a variadic function use its element type
+func paramTypeAt(t reflect.Type, i int) reflect.Type {
+	if t.IsVariadic() && i >= t.NumIn()-1 {
+		return t.In(t.NumIn() - 1).Elem()
+	}
+	return t.In(i)
+}
+
+func fromResults(out []reflect.Value) Object {
+	if len(out) == 0 {
+		return nil
+	}
+
+	last := out[len(out)-1]
+	if last.Type() == errorType {
+		if !last.IsNil() {
+			return newError("%s", last.Interface().(error))
+		}
+		if len(out) == 1 {
+			return nil
+		}
+	}
+
+	result, err := fromValue(out[0])
+	if err != nil {
+		return newError("%s", err)
+	}
+	return result
+}
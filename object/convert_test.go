@@ -0,0 +1,233 @@
+package object
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testUser struct {
+	Name   string `mscript:"name"`
+	Age    int    `mscript:"age"`
+	Tags   []string
+	Secret string `mscript:"-"`
+	hidden int
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.5, "2.5"},
+		{float32(2), "2.0"},
+		{"hi", "hi"},
+		{true, "true"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{map[int]string{2: "two", 1: "one"}, "{1: one, 2: two}"},
+		{testUser{Name: "ann", Age: 30, Tags: []string{"x"}, Secret: "s", hidden: 1}, "{Tags: [x], age: 30, name: ann}"},
+		{&testUser{Name: "bob"}, "{Tags: null, age: 0, name: bob}"},
+		{(*testUser)(nil), "null"},
+		{&Integer{Value: 5}, "5"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("FromGo(%#v) error: %s", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) wrong. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	if obj, _ := FromGo(true); obj != TRUE {
+		t.Errorf("FromGo(true) is not the TRUE singleton")
+	}
+	if _, err := FromGo(make(chan int)); err == nil {
+		t.Errorf("expected error converting a channel")
+	}
+	if _, err := FromGo(uint64(1 << 63)); err == nil {
+		t.Errorf("expected error converting an out of range uint64")
+	}
+}
+
+func TestToGo(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&String{Value: "name"}, &String{Value: "ann"})
+	hash.Set(&String{Value: "age"}, &Integer{Value: 30})
+	hash.Set(&String{Value: "Tags"}, &Array{Elements: []Object{&String{Value: "x"}}})
+
+	var user testUser
+	if err := ToGo(hash, &user); err != nil {
+		t.Fatalf("ToGo error: %s", err)
+	}
+	if !reflect.DeepEqual(user, testUser{Name: "ann", Age: 30, Tags: []string{"x"}}) {
+		t.Errorf("wrong struct. got=%+v", user)
+	}
+
+	var ints []int
+	if err := ToGo(&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, &ints); err != nil {
+		t.Fatalf("ToGo error: %s", err)
+	}
+	if !reflect.DeepEqual(ints, []int{1, 2}) {
+		t.Errorf("wrong slice. got=%v", ints)
+	}
+
+	var f float64
+	if err := ToGo(&Integer{Value: 3}, &f); err != nil || f != 3 {
+		t.Errorf("wrong float. got=%v err=%v", f, err)
+	}
+
+	var native any
+	if err := ToGo(hash, &native); err != nil {
+		t.Fatalf("ToGo error: %s", err)
+	}
+	want := map[string]any{"name": "ann", "age": int64(30), "Tags": []any{"x"}}
+	if !reflect.DeepEqual(native, want) {
+		t.Errorf("wrong native value. got=%#v", native)
+	}
+
+	var ptr *int
+	if err := ToGo(NULL, &ptr); err != nil || ptr != nil {
+		t.Errorf("null should convert to a nil pointer. got=%v err=%v", ptr, err)
+	}
+
+	var small int8
+	if err := ToGo(&Integer{Value: 300}, &small); err == nil {
+		t.Errorf("expected out of range error")
+	}
+
+	var s string
+	err := ToGo(&Integer{Value: 1}, &s)
+	if err == nil || err.Error() != "cannot convert INTEGER to string" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	if err := ToGo(&Integer{Value: 1}, s); err == nil {
+		t.Errorf("expected error for non pointer target")
+	}
+}
+
+func TestToGoFunc(t *testing.T) {
+	double := &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+		n, ok := args[0].(*Integer)
+		if !ok {
+			return newError("not a number: %s", args[0].Inspect())
+		}
+		return &Integer{Value: n.Value * 2}
+	}}
+
+	var f func(int) (int, error)
+	if err := ToGo(double, &f); err != nil {
+		t.Fatalf("ToGo error: %s", err)
+	}
+	if n, err := f(21); n != 42 || err != nil {
+		t.Errorf("wrong result. got=%d, %v", n, err)
+	}
+
+	var g func(string) (int, error)
+	if err := ToGo(double, &g); err != nil {
+		t.Fatalf("ToGo error: %s", err)
+	}
+	if _, err := g("a"); err == nil || err.Error() != "not a number: a" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	//Without an error result the error panics
+	var h func(string) int
+	if err := ToGo(double, &h); err != nil {
+		t.Fatalf("ToGo error: %s", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("no panic for an error without an error result")
+			}
+		}()
+		h("a")
+	}()
+
+	//Functions are called through the runtime of their environment
+	env := NewEnvironment()
+	var called []Object
+	env.Runtime().Call = func(fn Object, args ...Object) Object {
+		called = args
+		return &String{Value: "done"}
+	}
+	var sum func(...int) string
+	if err := ToGo(&Function{Env: env}, &sum); err != nil {
+		t.Fatalf("ToGo error: %s", err)
+	}
+	if got := sum(1, 2); got != "done" || len(called) != 2 {
+		t.Errorf("wrong call. got=%q with %v", got, called)
+	}
+
+	if err := ToGo(&Closure{}, &sum); err == nil || err.Error() != "cannot convert CLOSURE to func(...int) string: no runtime to call it with" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+	var bad func() (int, int)
+	if err := ToGo(double, &bad); err == nil {
+		t.Errorf("expected error for func with two values")
+	}
+}
+
+func TestWrapFunc(t *testing.T) {
+	check, err := WrapFunc(func(n int, s string) (bool, error) {
+		if n < 0 {
+			return false, errors.New("negative")
+		}
+		return len(s) == n, nil
+	})
+	if err != nil {
+		t.Fatalf("WrapFunc error: %s", err)
+	}
+
+	sum, _ := WrapFunc(func(rt *Runtime, nums ...float64) float64 {
+		if rt == nil {
+			return -1
+		}
+		total := 0.0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	})
+
+	noResult, _ := WrapFunc(func() {})
+
+	tests := []struct {
+		fn       *Builtin
+		args     []Object
+		expected string
+	}{
+		{check, []Object{&Integer{Value: 2}, &String{Value: "ab"}}, "true"},
+		{check, []Object{&Integer{Value: 1}, &String{Value: "ab"}}, "false"},
+		{check, []Object{&Integer{Value: -1}, &String{Value: "ab"}}, "ERROR: negative"},
+		{check, []Object{&String{Value: "ab"}, &String{Value: "ab"}}, "ERROR: argument 1: cannot convert STRING to int"},
+		{check, []Object{&Integer{Value: 2}}, "ERROR: wrong number of arguments. got=1, want=2"},
+		{sum, []Object{&Integer{Value: 1}, &Float{Value: 1.5}}, "2.5"},
+		{sum, []Object{}, "0.0"},
+	}
+
+	for _, tt := range tests {
+		result := tt.fn.Fn(NewRuntime(), tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result. want=%q, got=%q", tt.expected, result.Inspect())
+		}
+	}
+
+	if result := noResult.Fn(NewRuntime()); result != nil {
+		t.Errorf("function without results should return nil. got=%v", result)
+	}
+
+	if _, err := WrapFunc(func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("expected error wrapping a function with two non error results")
+	}
+}
@@ -0,0 +1,236 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The json module, integers and floats stay apart in both directions
+var jsonModule = &Module{
+	Name: "json",
+	Exports: map[string]Object{
+		//Numbers without a fraction or exponent become integers, those too big for one are an error
+		"parse": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			src, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `json.parse` must be STRING, got %s", args[0].Type())
+			}
+			dec := json.NewDecoder(strings.NewReader(src.Value))
+			dec.UseNumber()
+			var value any
+			if err := dec.Decode(&value); err != nil {
+				return jsonError(err)
+			}
+			if _, err := dec.Token(); err != io.EOF {
+				return newError("json.parse: unexpected data after the value at offset %d", dec.InputOffset())
+			}
+			obj, errObj := fromJSON(value)
+			if errObj != nil {
+				return errObj
+			}
+			return obj
+		}},
+		//stringify(value, indent) puts every element on its own line, indented by indent spaces or by the string indent
+		//Hash keys are sorted, integer and boolean keys are written as strings and are an error when
+		//another key is written the same, like 1 and "1"
+		"stringify": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			e := &jsonEncoder{visiting: make(map[Object]bool)}
+			if len(args) == 2 {
+				switch indent := args[1].(type) {
+				case *Integer:
+					if indent.Value < 0 || indent.Value > 16 {
+						return newError("indent to `json.stringify` must be between 0 and 16, got %d", indent.Value)
+					}
+					e.indent = strings.Repeat(" ", int(indent.Value))
+				case *String:
+					e.indent = indent.Value
+				default:
+					return newError("second argument to `json.stringify` must be INTEGER or STRING, got %s", args[1].Type())
+				}
+			}
+			if err := e.encode(args[0], 0); err != nil {
+				return err
+			}
+			return &String{Value: e.buf.String()}
+		}},
+	},
+}
+
+// Converts a value decoded with UseNumber to an object
+func fromJSON(value any) (Object, *Error) {
+	switch value := value.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		if value {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case string:
+		return &String{Value: value}, nil
+	case json.Number:
+		if !strings.ContainsAny(value.String(), ".eE") {
+			n, err := strconv.ParseInt(value.String(), 10, 64)
+			if err != nil {
+				return nil, newError("json.parse: integer %s out of range", value)
+			}
+			return &Integer{Value: n}, nil
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return nil, newError("json.parse: number %s out of range", value)
+		}
+		return &Float{Value: f}, nil
+	case []any:
+		elements := make([]Object, len(value))
+		for i, el := range value {
+			obj, err := fromJSON(el)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		return &Array{Elements: elements}, nil
+	case map[string]any:
+		hash := NewHash()
+		for key, el := range value {
+			obj, err := fromJSON(el)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(&String{Value: key}, obj)
+		}
+		return hash, nil
+	}
+	return nil, newError("json.parse: unexpected %T", value)
+}
+
+func jsonError(err error) *Error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return newError("json.parse: %s at offset %d", syntaxErr, syntaxErr.Offset)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return newError("json.parse: unexpected end of input")
+	}
+	return newError("json.parse: %s", err)
+}
+
+type jsonEncoder struct {
+	buf      bytes.Buffer
+	indent   string          //Empty for compact output
+	visiting map[Object]bool //Arrays and hashes being encoded, to find cycles
+}
+
+func (e *jsonEncoder) encode(obj Object, depth int) *Error {
+	switch obj := obj.(type) {
+	case nil, *Null:
+		e.buf.WriteString("null")
+	case *Boolean:
+		e.buf.WriteString(strconv.FormatBool(obj.Value))
+	case *Integer:
+		e.buf.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return newError("json.stringify: cannot encode %s", obj.Inspect())
+		}
+		//A fraction or exponent is always written so the number parses back as a float
+		s := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		e.buf.WriteString(s)
+	case *String:
+		e.writeString(obj.Value)
+	case *Array:
+		if e.visiting[obj] {
+			return newError("json.stringify: cyclic structure")
+		}
+		e.visiting[obj] = true
+		defer delete(e.visiting, obj)
+
+		e.buf.WriteByte('[')
+		for i, el := range obj.Elements {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			if err := e.encode(el, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(obj.Elements) > 0 {
+			e.newline(depth)
+		}
+		e.buf.WriteByte(']')
+	case *Hash:
+		if e.visiting[obj] {
+			return newError("json.stringify: cyclic structure")
+		}
+		e.visiting[obj] = true
+		defer delete(e.visiting, obj)
+
+		e.buf.WriteByte('{')
+		keys := make(map[string]bool, len(obj.Pairs))
+		for i, pair := range obj.SortedPairs() {
+			key := pair.Key.Inspect()
+			if s, ok := pair.Key.(*String); ok {
+				key = s.Value
+			}
+			if keys[key] {
+				return newError("json.stringify: duplicate key %q", key)
+			}
+			keys[key] = true
+
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			e.writeString(key)
+			e.buf.WriteByte(':')
+			if e.indent != "" {
+				e.buf.WriteByte(' ')
+			}
+			if err := e.encode(pair.Value, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(obj.Pairs) > 0 {
+			e.newline(depth)
+		}
+		e.buf.WriteByte('}')
+	default:
+		return newError("json.stringify: cannot encode %s", obj.Type())
+	}
+	return nil
+}
+
+// Starts a line indented depth times, nothing for compact output
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.buf.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		e.buf.WriteString(e.indent)
+	}
+}
+
+// Writes s quoted, leaving <, > and & as they are
+func (e *jsonEncoder) writeString(s string) {
+	enc := json.NewEncoder(&e.buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	e.buf.Truncate(e.buf.Len() - 1) //Encode ends with a newline
+}
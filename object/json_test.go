@@ -0,0 +1,89 @@
+package object
+
+import "testing"
+
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a": [1, 2.5, "x", true, null], "b": {"c": -3}}`, `{a: [1, 2.5, x, true, null], b: {c: -3}}`},
+		{`1`, "1"},
+		{`1.0`, "1.0"},
+		{`1e3`, "1000.0"},
+		{`"hé\n"`, "hé\n"},
+		{`[]`, "[]"},
+		{`9223372036854775807`, "9223372036854775807"},
+		{`9223372036854775808`, "ERROR: json.parse: integer 9223372036854775808 out of range"},
+		{`1e400`, "ERROR: json.parse: number 1e400 out of range"},
+		{`{"a": 1,}`, "ERROR: json.parse: invalid character '}' looking for beginning of object key string at offset 9"},
+		{`[1, 2`, "ERROR: json.parse: unexpected end of input"},
+		{`1 2`, "ERROR: json.parse: unexpected data after the value at offset 3"},
+		{``, "ERROR: json.parse: unexpected end of input"},
+	}
+
+	for _, tt := range tests {
+		got := callMember("json", "parse", str(tt.input))
+		if got.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. got=%q, want=%q", tt.input, got.Inspect(), tt.expected)
+		}
+	}
+
+	if got := callMember("json", "parse", str("2")); got.Type() != INTEGER_OBJ {
+		t.Errorf("2 parsed to %s", got.Type())
+	}
+	if got := callMember("json", "parse", str("2.0")); got.Type() != FLOAT_OBJ {
+		t.Errorf("2.0 parsed to %s", got.Type())
+	}
+}
+
+func TestJSONStringify(t *testing.T) {
+	hash := NewHash()
+	hash.Set(str("b"), &Array{Elements: []Object{num(1), &Float{Value: 2}, &Float{Value: 0.5}, NULL}})
+	hash.Set(str("a"), str("<é\">"))
+	hash.Set(num(3), TRUE)
+	collision := NewHash()
+	collision.Set(num(1), str("a"))
+	collision.Set(str("1"), str("b"))
+
+	tests := []struct {
+		args     []Object
+		expected string
+	}{
+		{[]Object{hash}, `{"3":true,"a":"<é\">","b":[1,2.0,0.5,null]}`},
+		{[]Object{hash, num(2)}, "{\n  \"3\": true,\n  \"a\": \"<é\\\">\",\n  \"b\": [\n    1,\n    2.0,\n    0.5,\n    null\n  ]\n}"},
+		{[]Object{&Array{Elements: []Object{&Array{}, NewHash()}}, str("\t")}, "[\n\t[],\n\t{}\n]"},
+		{[]Object{&Float{Value: 1e21}}, "1e+21"},
+		{[]Object{str("x"), TRUE}, "ERROR: second argument to `json.stringify` must be INTEGER or STRING, got BOOLEAN"},
+		{[]Object{&Builtin{}}, "ERROR: json.stringify: cannot encode BUILTIN"},
+		{[]Object{collision}, "ERROR: json.stringify: duplicate key \"1\""},
+		{[]Object{&Array{Elements: []Object{&Function{}}}}, "ERROR: json.stringify: cannot encode FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		got := callMember("json", "stringify", tt.args...)
+		if got.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. got=%q, want=%q", tt.args[0].Inspect(), got.Inspect(), tt.expected)
+		}
+	}
+
+	cyclic := &Array{}
+	inner := NewHash()
+	inner.Set(str("back"), cyclic)
+	cyclic.Elements = []Object{inner}
+	if got := callMember("json", "stringify", cyclic).Inspect(); got != "ERROR: json.stringify: cyclic structure" {
+		t.Errorf("cyclic structure. got=%q", got)
+	}
+
+	//Shared values that are not cycles are written each time
+	shared := &Array{Elements: []Object{num(1)}}
+	if got := callMember("json", "stringify", &Array{Elements: []Object{shared, shared}}).Inspect(); got != "[[1],[1]]" {
+		t.Errorf("shared value. got=%q", got)
+	}
+
+	//Writing and parsing back keeps integers and floats apart
+	parsed := callMember("json", "parse", callMember("json", "stringify", hash))
+	if got := parsed.Inspect(); got != `{3: true, a: <é">, b: [1, 2.0, 0.5, null]}` {
+		t.Errorf("round trip. got=%q", got)
+	}
+}
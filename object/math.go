@@ -0,0 +1,146 @@
+package object
+
+import "math"
+
+// The math module, integers stay integers where the result is exact
+var mathModule = &Module{
+	Name: "math",
+	Exports: map[string]Object{
+		"pi": &Float{Value: math.Pi},
+		"e":  &Float{Value: math.E},
+		"abs": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Integer:
+				if arg.Value < 0 {
+					return &Integer{Value: -arg.Value}
+				}
+				return arg
+			case *Float:
+				return &Float{Value: math.Abs(arg.Value)}
+			}
+			return newError("argument to `math.abs` must be a number, got %s", args[0].Type())
+		}},
+		"min": extremum("min", func(a, b float64) bool { return a < b }),
+		"max": extremum("max", func(a, b float64) bool { return a > b }),
+		"pow": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			base, baseOk := args[0].(*Integer)
+			exp, expOk := args[1].(*Integer)
+			if baseOk && expOk && exp.Value >= 0 {
+				return &Integer{Value: intPow(base.Value, exp.Value)}
+			}
+			values, err := numberArgs("pow", args)
+			if err != nil {
+				return err
+			}
+			return &Float{Value: math.Pow(values[0], values[1])}
+		}},
+		"sqrt":  floatFunction("sqrt", math.Sqrt),
+		"floor": rounding("floor", math.Floor),
+		"ceil":  rounding("ceil", math.Ceil),
+		"round": rounding("round", math.Round),
+		"sin":   floatFunction("sin", math.Sin),
+		"cos":   floatFunction("cos", math.Cos),
+		"tan":   floatFunction("tan", math.Tan),
+		"asin":  floatFunction("asin", math.Asin),
+		"acos":  floatFunction("acos", math.Acos),
+		"atan":  floatFunction("atan", math.Atan),
+		"atan2": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			values, err := numberArgs("atan2", args)
+			if err != nil {
+				return err
+			}
+			return &Float{Value: math.Atan2(values[0], values[1])}
+		}},
+	},
+}
+
+// Raises base to exp by squaring, overflowing like integer multiplication does
+func intPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+// Returns the argument for which better holds against every other, as it was passed
+func extremum(name string, better func(a, b float64) bool) *Builtin {
+	return &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+		if len(args) == 0 {
+			return newError("wrong number of arguments. got=0, want=1 or more")
+		}
+		values, err := numberArgs(name, args)
+		if err != nil {
+			return err
+		}
+		best := 0
+		for i := range values {
+			if better(values[i], values[best]) {
+				best = i
+			}
+		}
+		return args[best]
+	}}
+}
+
+func floatFunction(name string, fn func(float64) float64) *Builtin {
+	return &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		values, err := numberArgs(name, args)
+		if err != nil {
+			return err
+		}
+		return &Float{Value: fn(values[0])}
+	}}
+}
+
+// Rounds a float to an integer with fn, integers are returned as they are
+func rounding(name string, fn func(float64) float64) *Builtin {
+	return &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		switch arg := args[0].(type) {
+		case *Integer:
+			return arg
+		case *Float:
+			rounded := fn(arg.Value)
+			if math.IsNaN(rounded) || rounded < math.MinInt64 || rounded >= math.MaxInt64 {
+				return newError("cannot convert %s to INTEGER: out of range", arg.Inspect())
+			}
+			return &Integer{Value: int64(rounded)}
+		}
+		return newError("argument to `math.%s` must be a number, got %s", name, args[0].Type())
+	}}
+}
+
+// Checks that every argument of the builtin name is a number and returns them as floats
+func numberArgs(name string, args []Object) ([]float64, *Error) {
+	values := make([]float64, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case *Integer:
+			values[i] = float64(arg.Value)
+		case *Float:
+			values[i] = arg.Value
+		default:
+			return nil, newError("argument %d to `math.%s` must be a number, got %s", i+1, name, arg.Type())
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,99 @@
+package object
+
+import "testing"
+
+func flt(f float64) *Float { return &Float{Value: f} }
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"abs", []Object{num(-3)}, "3"},
+		{"abs", []Object{flt(-2.5)}, "2.5"},
+		{"abs", []Object{str("a")}, "ERROR: argument to `math.abs` must be a number, got STRING"},
+		{"min", []Object{num(3), flt(1.5), num(2)}, "1.5"},
+		{"max", []Object{num(3), flt(1.5), num(2)}, "3"},
+		{"min", []Object{}, "ERROR: wrong number of arguments. got=0, want=1 or more"},
+		{"pow", []Object{num(2), num(10)}, "1024"},
+		{"pow", []Object{num(2), num(-1)}, "0.5"},
+		{"pow", []Object{flt(2.5), num(2)}, "6.25"},
+		{"pow", []Object{num(3), num(0)}, "1"},
+		{"sqrt", []Object{num(16)}, "4.0"},
+		{"floor", []Object{flt(-2.5)}, "-3"},
+		{"ceil", []Object{flt(2.1)}, "3"},
+		{"round", []Object{flt(2.5)}, "3"},
+		{"round", []Object{num(7)}, "7"},
+		{"round", []Object{flt(1e300)}, "ERROR: cannot convert 1e+300 to INTEGER: out of range"},
+		{"sin", []Object{num(0)}, "0.0"},
+		{"cos", []Object{num(0)}, "1.0"},
+		{"atan2", []Object{num(1), num(1)}, "0.7853981633974483"},
+		{"sqrt", []Object{TRUE}, "ERROR: argument 1 to `math.sqrt` must be a number, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		got := callMember("math", tt.name, tt.args...)
+		if got.Inspect() != tt.expected {
+			t.Errorf("math.%s: wrong result. got=%q, want=%q", tt.name, got.Inspect(), tt.expected)
+		}
+	}
+
+	module := GetBuiltinByName("math").(*Module)
+	if module.Exports["pi"].Inspect() != "3.141592653589793" || module.Exports["e"].Inspect() != "2.718281828459045" {
+		t.Errorf("wrong constants. pi=%s, e=%s", module.Exports["pi"].Inspect(), module.Exports["e"].Inspect())
+	}
+}
+
+func TestRandomModule(t *testing.T) {
+	random := GetBuiltinByName("random").(*Module)
+	call := func(rt *Runtime, name string, args ...Object) Object {
+		return random.Exports[name].(*Builtin).Fn(rt, args...)
+	}
+	draw := func(rt *Runtime) string {
+		arr := &Array{Elements: []Object{num(1), num(2), num(3), num(4)}}
+		out := ""
+		for i := 0; i < 5; i++ {
+			out += call(rt, "int", num(100)).Inspect() + " "
+		}
+		out += call(rt, "float").Inspect() + " "
+		out += call(rt, "choice", arr).Inspect() + " "
+		return out + call(rt, "shuffle", arr).Inspect()
+	}
+
+	//The same seed gives the same numbers
+	first, second := NewRuntime(), NewRuntime()
+	call(first, "seed", num(42))
+	call(second, "seed", num(42))
+	if a, b := draw(first), draw(second); a != b {
+		t.Errorf("seeded runtimes differ.\n%s\n%s", a, b)
+	}
+
+	rt := NewRuntime()
+	for i := 0; i < 100; i++ {
+		n := call(rt, "int", num(-2), num(3)).(*Integer).Value
+		if n < -2 || n >= 3 {
+			t.Fatalf("random.int(-2, 3) out of range: %d", n)
+		}
+		f := call(rt, "float").(*Float).Value
+		if f < 0 || f >= 1 {
+			t.Fatalf("random.float() out of range: %g", f)
+		}
+	}
+
+	errors := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"int", []Object{num(0)}, "ERROR: empty range [0, 0) in `random.int`"},
+		{"int", []Object{str("a")}, "ERROR: argument 1 to `random.int` must be INTEGER, got STRING"},
+		{"choice", []Object{&Array{}}, "ERROR: `random.choice` of an empty array"},
+		{"seed", []Object{flt(1.5)}, "ERROR: argument to `random.seed` must be INTEGER, got FLOAT"},
+	}
+	for _, tt := range errors {
+		if got := call(rt, tt.name, tt.args...).Inspect(); got != tt.expected {
+			t.Errorf("random.%s: wrong result. got=%q, want=%q", tt.name, got, tt.expected)
+		}
+	}
+}
@@ -3,7 +3,12 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"mscript/Code"
 	"mscript/ast"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -17,6 +22,24 @@ const (
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
+	MODULE_OBJ       = "MODULE"
+	BUILTIN_OBJ      = "BUILTIN"
+	FLOAT_OBJ        = "FLOAT"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+	REGEX_OBJ        = "REGEX"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
+)
+
+// Shared by every backend so booleans and null can be compared by identity
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+	NULL  = &Null{}
 )
 
 type Object interface {
@@ -24,6 +47,16 @@ type Object interface {
 	Inspect() string
 }
 
+// Objects that can be used as hash keys
+type Hashable interface {
+	HashKey() HashKey
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
 type Integer struct {
 	Value int64
 }
@@ -44,6 +77,10 @@ type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+
+	//The annotations of the literal, only checked when the runtime is strict
+	ParameterTypes []*ast.TypeAnnotation
+	ReturnType     *ast.TypeAnnotation
 }
 
 type String struct {
@@ -52,20 +89,80 @@ type String struct {
 
 type Null struct{}
 
+type Float struct {
+	Value float64
+}
+
+type Array struct {
+	Elements []Object
+}
+
+// The original key is kept next to the value so hashes can be printed and iterated
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+// A function implemented in Go, a nil result means null
+type BuiltinFunction func(rt *Runtime, args ...Object) Object
+
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+// A function body compiled to bytecode by the compiler
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+}
+
+// A compiled function together with the free variables it closed over
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+// A loaded module, Exports holds every top level binding declared with export
+type Module struct {
+	Name    string
+	Path    string
+	Exports map[string]Object
+}
+
+// Importer resolves and loads the module at path as seen from the environment doing the importing
+type Importer interface {
+	Import(path string, from *Environment) (*Module, error)
+}
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	//Shared with every enclosed environment
+	file    string
+	runtime *Runtime
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, runtime: NewRuntime()}
+}
+
+// Creates the top level environment of a module loaded from file
+// Imports inside the module are resolved by rt.Importer relative to file
+func NewModuleEnvironment(file string, rt *Runtime) *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: nil, file: file, runtime: rt}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: outer, file: outer.file, runtime: outer.runtime}
 }
 
 func (i *Integer) Type() ObjectType {
@@ -112,13 +209,21 @@ func (f *Function) Type() ObjectType {
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range f.Parameters {
+	for i, p := range f.Parameters {
+		if i < len(f.ParameterTypes) && f.ParameterTypes[i] != nil {
+			params = append(params, p.String()+": "+f.ParameterTypes[i].String())
+			continue
+		}
 		params = append(params, p.String())
 	}
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(")")
+	if f.ReturnType != nil {
+		out.WriteString(": " + f.ReturnType.String())
+	}
+	out.WriteString(" {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
 	return out.String()
@@ -136,6 +241,36 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Returns the environment e is enclosed in, nil for the top level environment of a module
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Returns the names bound directly in e, not in its outer environments, in sorted order
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns a view of e sharing its bindings whose imports resolve relative to file
+func (e *Environment) InFile(file string) *Environment {
+	return &Environment{store: e.store, outer: e.outer, file: file, runtime: e.runtime}
+}
+
+// Returns the file of the module this environment belongs to, "" when not loaded from a file
+func (e *Environment) File() string {
+	return e.file
+}
+
+// Returns the runtime this environment is evaluated in
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
+
 func (s *String) Type() ObjectType {
 	return STRING_OBJ
 }
@@ -143,3 +278,170 @@ func (s *String) Type() ObjectType {
 func (s *String) Inspect() string {
 	return s.Value
 }
+
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
+
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("<module %s>", m.Name)
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin function"
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+func (c *Closure) Type() ObjectType {
+	return CLOSURE_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Always prints a decimal point or exponent so floats can be told apart from integers
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if math.IsInf(f.Value, 0) || math.IsNaN(f.Value) || strings.ContainsAny(s, ".e") {
+		return s
+	}
+	return s + ".0"
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// Creates an empty hash
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Adds or replaces the value stored under key
+func (h *Hash) Set(key Hashable, value Object) {
+	h.Pairs[key.HashKey()] = HashPair{Key: key.(Object), Value: value}
+}
+
+// Returns the value stored under key
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+// Returns the pairs ordered by key so output does not depend on map order
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Key.Type() != pairs[j].Key.Type() {
+			return pairs[i].Key.Type() < pairs[j].Key.Type()
+		}
+		if a, ok := pairs[i].Key.(*Integer); ok {
+			return a.Value < pairs[j].Key.(*Integer).Value
+		}
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+	return pairs
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.SortedPairs() {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// Unevaluated code produced by quote
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType {
+	return QUOTE_OBJ
+}
+
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// Called with quoted arguments during macro expansion, returns the code that replaces the call
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType {
+	return MACRO_OBJ
+}
+
+func (m *Macro) Inspect() string {
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+	return "macro(" + strings.Join(params, ", ") + ") {\n" + m.Body.String() + "\n}"
+}
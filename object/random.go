@@ -0,0 +1,75 @@
+package object
+
+// The random module, drawing from the generator of the runtime so seeding it makes runs repeatable
+var randomModule = &Module{
+	Name: "random",
+	Exports: map[string]Object{
+		"seed": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			seed, ok := args[0].(*Integer)
+			if !ok {
+				return newError("argument to `random.seed` must be INTEGER, got %s", args[0].Type())
+			}
+			rt.Seed(seed.Value)
+			return nil
+		}},
+		"float": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return &Float{Value: rt.Rand().Float64()}
+		}},
+		//int(n) is in [0, n) and int(min, max) in [min, max)
+		"int": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				n, ok := arg.(*Integer)
+				if !ok {
+					return newError("argument %d to `random.int` must be INTEGER, got %s", i+1, arg.Type())
+				}
+				bounds[i] = n.Value
+			}
+			lo, hi := int64(0), bounds[0]
+			if len(bounds) == 2 {
+				lo, hi = bounds[0], bounds[1]
+			}
+			if hi <= lo {
+				return newError("empty range [%d, %d) in `random.int`", lo, hi)
+			}
+			return &Integer{Value: lo + int64(rt.Rand().Uint64N(uint64(hi-lo)))}
+		}},
+		"choice": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `random.choice` must be ARRAY, got %s", args[0].Type())
+			}
+			if len(arr.Elements) == 0 {
+				return newError("`random.choice` of an empty array")
+			}
+			return arr.Elements[rt.Rand().IntN(len(arr.Elements))]
+		}},
+		//Returns a shuffled copy, the array passed is left as it is
+		"shuffle": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `random.shuffle` must be ARRAY, got %s", args[0].Type())
+			}
+			elements := append([]Object{}, arr.Elements...)
+			rt.Rand().Shuffle(len(elements), func(i, j int) {
+				elements[i], elements[j] = elements[j], elements[i]
+			})
+			return &Array{Elements: elements}
+		}},
+	},
+}
@@ -0,0 +1,149 @@
+package object
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// A compiled regular expression, made by re.compile
+type Regex struct {
+	Value *regexp.Regexp
+}
+
+func (r *Regex) Type() ObjectType {
+	return REGEX_OBJ
+}
+
+func (r *Regex) Inspect() string {
+	return "/" + r.Value.String() + "/"
+}
+
+// The re module, built on RE2 so matching takes time linear in the input whatever the pattern
+//
+// A match is a hash with the keys
+//
+//	text    the matched text
+//	start   the index of its first rune, end the index after its last
+//	groups  an array of the text of every capture group, null for groups that did not take part
+//	named   a hash of the named groups by name
+var reModule = &Module{
+	Name: "re",
+	Exports: map[string]Object{
+		"compile": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			pattern, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `re.compile` must be STRING, got %s", args[0].Type())
+			}
+			re, err := regexp.Compile(pattern.Value)
+			if err != nil {
+				return newError("re.compile: %s", err)
+			}
+			return &Regex{Value: re}
+		}},
+		//The first match or null
+		"match": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			re, s, err := regexArgs("match", args, 2)
+			if err != nil {
+				return err
+			}
+			loc := re.FindStringSubmatchIndex(s[0])
+			if loc == nil {
+				return nil
+			}
+			return newMatch(re, s[0], loc, &runeCounter{})
+		}},
+		//Every match not overlapping an earlier one
+		"findAll": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			re, s, err := regexArgs("findAll", args, 2)
+			if err != nil {
+				return err
+			}
+			counter := &runeCounter{}
+			locs := re.FindAllStringSubmatchIndex(s[0], -1)
+			matches := make([]Object, len(locs))
+			for i, loc := range locs {
+				matches[i] = newMatch(re, s[0], loc, counter)
+			}
+			return &Array{Elements: matches}
+		}},
+		//replace(regex, s, replacement) replaces every match, $1 or ${name} in replacement stand for a group
+		//A name runs as far as letters, digits and _ go, so write ${1}x for group 1 followed by x
+		"replace": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			re, s, err := regexArgs("replace", args, 3)
+			if err != nil {
+				return err
+			}
+			return &String{Value: re.ReplaceAllString(s[0], s[1])}
+		}},
+		//Splits s around every match
+		"split": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			re, s, err := regexArgs("split", args, 2)
+			if err != nil {
+				return err
+			}
+			return stringArray(re.Split(s[0], -1))
+		}},
+	},
+}
+
+// Checks that the builtin re.name got a regex followed by strings, n arguments in all
+func regexArgs(name string, args []Object, n int) (*regexp.Regexp, []string, *Error) {
+	if len(args) != n {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	re, ok := args[0].(*Regex)
+	if !ok {
+		return nil, nil, newError("argument 1 to `re.%s` must be REGEX, got %s", name, args[0].Type())
+	}
+	values := make([]string, n-1)
+	for i, arg := range args[1:] {
+		s, ok := arg.(*String)
+		if !ok {
+			return nil, nil, newError("argument %d to `re.%s` must be STRING, got %s", i+2, name, arg.Type())
+		}
+		values[i] = s.Value
+	}
+	return re.Value, values, nil
+}
+
+// Builds the hash of a match from the byte offsets in loc
+func newMatch(re *regexp.Regexp, s string, loc []int, counter *runeCounter) *Hash {
+	groups := make([]Object, re.NumSubexp())
+	named := NewHash()
+	for i, name := range re.SubexpNames()[1:] {
+		var group Object = NULL
+		if start := loc[2*i+2]; start >= 0 {
+			group = &String{Value: s[start:loc[2*i+3]]}
+		}
+		groups[i] = group
+		if name != "" {
+			named.Set(&String{Value: name}, group)
+		}
+	}
+
+	match := NewHash()
+	match.Set(&String{Value: "text"}, &String{Value: s[loc[0]:loc[1]]})
+	match.Set(&String{Value: "start"}, &Integer{Value: int64(counter.runes(s, loc[0]))})
+	match.Set(&String{Value: "end"}, &Integer{Value: int64(counter.runes(s, loc[1]))})
+	match.Set(&String{Value: "groups"}, &Array{Elements: groups})
+	match.Set(&String{Value: "named"}, named)
+	return match
+}
+
+// Turns byte offsets into rune indices, counting on from the offset asked for last
+// so the offsets of every match in a string are found in one pass
+type runeCounter struct {
+	offset, count int
+}
+
+func (c *runeCounter) runes(s string, offset int) int {
+	if offset < c.offset {
+		c.offset, c.count = 0, 0
+	}
+	c.count += utf8.RuneCountInString(s[c.offset:offset])
+	c.offset = offset
+	return c.count
+}
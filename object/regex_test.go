@@ -0,0 +1,46 @@
+package object
+
+import "testing"
+
+func TestReModule(t *testing.T) {
+	compile := func(pattern string) *Regex {
+		return callMember("re", "compile", str(pattern)).(*Regex)
+	}
+	date := compile(`(?P<year>\d{4})-(?P<month>\d\d)(-(\d\d))?`)
+	words := compile(`\s*,\s*`)
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"compile", []Object{str("a(")}, "ERROR: re.compile: error parsing regexp: missing closing ): `a(`"},
+		{"compile", []Object{num(1)}, "ERROR: argument to `re.compile` must be STRING, got INTEGER"},
+		{"match", []Object{date, str("née 2024-03, 1999-12-31")}, "{end: 11, groups: [2024, 03, null, null], named: {month: 03, year: 2024}, start: 4, text: 2024-03}"},
+		{"match", []Object{date, str("no date")}, "null"},
+		{"findAll", []Object{date, str("é 2024-03, 1999-12-31")}, "[{end: 9, groups: [2024, 03, null, null], named: {month: 03, year: 2024}, start: 2, text: 2024-03}, " +
+			"{end: 21, groups: [1999, 12, -31, 31], named: {month: 12, year: 1999}, start: 11, text: 1999-12-31}]"},
+		{"findAll", []Object{date, str("")}, "[]"},
+		{"replace", []Object{date, str("on 2024-03-09"), str("${month}/$4/$year")}, "on 03/09/2024"},
+		{"replace", []Object{words, str("a , b,c"), str("${0}x")}, "a , xb,xc"},
+		{"split", []Object{words, str("a , b,c")}, "[a, b, c]"},
+		{"split", []Object{str("a"), str("b")}, "ERROR: argument 1 to `re.split` must be REGEX, got STRING"},
+		{"replace", []Object{words, str("a"), num(1)}, "ERROR: argument 3 to `re.replace` must be STRING, got INTEGER"},
+		{"match", []Object{words}, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		got := callMember("re", tt.name, tt.args...)
+		inspect := "null"
+		if got != nil {
+			inspect = got.Inspect()
+		}
+		if inspect != tt.expected {
+			t.Errorf("re.%s: wrong result. got=%q, want=%q", tt.name, inspect, tt.expected)
+		}
+	}
+
+	if got := date.Inspect(); got != `/(?P<year>\d{4})-(?P<month>\d\d)(-(\d\d))?/` {
+		t.Errorf("wrong Inspect. got=%q", got)
+	}
+}
@@ -0,0 +1,140 @@
+package object
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mscript/ast"
+	"os"
+	"time"
+)
+
+// How many steps run between checks of the runtime context
+const contextCheckInterval = 1024
+
+// Runtime holds the state shared by every environment of one interpreter:
+// where builtins read and write, how imports are loaded and the execution limits
+type Runtime struct {
+	Stdout   io.Writer
+	Stdin    io.Reader
+	Importer Importer
+
+	//Cancels execution when done, nil means never
+	Context context.Context
+	//Maximum number of evaluation steps, 0 means unlimited
+	MaxSteps int
+	//Maximum depth of nested function calls, 0 means unlimited
+	MaxDepth int
+	//Called by the tree walker before each statement, nil when not debugging
+	//Execution waits for it to return and stops with the error it returns
+	Debug func(stmt ast.Statement, env *Environment) error
+	//Makes the tree walker check lets, arguments and results against their type annotations
+	Strict bool
+	//What the fs and os modules may do, nothing unless the host grants it
+	Capabilities Capabilities
+	//Arguments for the script, returned by os.args
+	Args []string
+	//Calls a function of the script, set by the backend running it
+	//Used by Go funcs made from script functions by ToGo
+	Call func(fn Object, args ...Object) Object
+
+	steps  int
+	depth  int
+	stdin  *bufio.Reader
+	random *rand.Rand
+	exit   *int //The code passed to os.exit during the current run
+}
+
+// Runtime reading from os.Stdin and writing to os.Stdout without any limits
+func NewRuntime() *Runtime {
+	return &Runtime{Stdout: os.Stdout, Stdin: os.Stdin}
+}
+
+// Counts one evaluation step and reports an error once a limit is hit
+func (rt *Runtime) Step() error {
+	rt.steps++
+	if rt.MaxSteps > 0 && rt.steps > rt.MaxSteps {
+		return fmt.Errorf("step limit of %d exceeded", rt.MaxSteps)
+	}
+	if rt.Context != nil && rt.steps%contextCheckInterval == 0 {
+		if err := rt.Context.Err(); err != nil {
+			return fmt.Errorf("execution cancelled: %s", err)
+		}
+	}
+	return nil
+}
+
+// Called before entering a function, errors once MaxDepth calls are active
+func (rt *Runtime) Enter() error {
+	if rt.MaxDepth > 0 && rt.depth >= rt.MaxDepth {
+		return fmt.Errorf("maximum call depth of %d exceeded", rt.MaxDepth)
+	}
+	rt.depth++
+	return nil
+}
+
+// Returns how many function calls are active
+func (rt *Runtime) Depth() int {
+	return rt.depth
+}
+
+// Called when a function entered with Enter returns
+func (rt *Runtime) Leave() {
+	rt.depth--
+}
+
+// Resets the step and depth counters, called before each run
+func (rt *Runtime) Reset() {
+	rt.steps = 0
+	rt.depth = 0
+	rt.exit = nil
+}
+
+// Records that the script asked to exit with code and returns the error that stops it
+func (rt *Runtime) Exit(code int) *Error {
+	rt.exit = &code
+	return newError("exit status %d", code)
+}
+
+// Returns the code passed to Exit since the last Reset
+func (rt *Runtime) Exited() (int, bool) {
+	if rt.exit == nil {
+		return 0, false
+	}
+	return *rt.exit, true
+}
+
+// Makes the generator of the random module start over from seed
+func (rt *Runtime) Seed(seed int64) {
+	rt.random = rand.New(rand.NewPCG(uint64(seed), 0))
+}
+
+// Returns the generator of the random module, seeded from the clock unless Seed was called
+func (rt *Runtime) Rand() *rand.Rand {
+	if rt.random == nil {
+		rt.Seed(time.Now().UnixNano())
+	}
+	return rt.random
+}
+
+// Reads one line from Stdin without the trailing newline
+// Returns io.EOF once the input is exhausted
+func (rt *Runtime) ReadLine() (string, error) {
+	if rt.stdin == nil {
+		rt.stdin = bufio.NewReader(rt.Stdin)
+	}
+	line, err := rt.stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
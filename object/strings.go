@@ -0,0 +1,270 @@
+package object
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Longest string strings.repeat makes, in bytes
+const maxRepeatLength = 1 << 28
+
+// The strings module, indexes and lengths count runes like len does
+var stringsModule = &Module{
+	Name: "strings",
+	Exports: map[string]Object{
+		"split": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			s, sep, err := twoStrings("split", args)
+			if err != nil {
+				return err
+			}
+			//An empty separator splits after each rune like chars
+			return stringArray(strings.Split(s, sep))
+		}},
+		"join": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[0].(*Array)
+			if !ok {
+				return newError("first argument to `strings.join` must be ARRAY, got %s", args[0].Type())
+			}
+			sep, ok := args[1].(*String)
+			if !ok {
+				return newError("second argument to `strings.join` must be STRING, got %s", args[1].Type())
+			}
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				s, ok := el.(*String)
+				if !ok {
+					return newError("`strings.join` needs an array of STRING, got %s at %d", el.Type(), i)
+				}
+				parts[i] = s.Value
+			}
+			return &String{Value: strings.Join(parts, sep.Value)}
+		}},
+		"trim":       trimBuiltin("trim", strings.TrimSpace, strings.Trim),
+		"trimLeft":   trimBuiltin("trimLeft", func(s string) string { return strings.TrimLeftFunc(s, unicode.IsSpace) }, strings.TrimLeft),
+		"trimRight":  trimBuiltin("trimRight", func(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) }, strings.TrimRight),
+		"upper":      mapString("upper", strings.ToUpper),
+		"lower":      mapString("lower", strings.ToLower),
+		"contains":   testStrings("contains", strings.Contains),
+		"startsWith": testStrings("startsWith", strings.HasPrefix),
+		"endsWith":   testStrings("endsWith", strings.HasSuffix),
+		"indexOf": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			s, sub, err := twoStrings("indexOf", args)
+			if err != nil {
+				return err
+			}
+			i := strings.Index(s, sub)
+			if i < 0 {
+				return &Integer{Value: -1}
+			}
+			return &Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+		}},
+		"replace": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+			values, err := stringArgs("replace", args)
+			if err != nil {
+				return err
+			}
+			return &String{Value: strings.ReplaceAll(values[0], values[1], values[2])}
+		}},
+		"repeat": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			s, ok := args[0].(*String)
+			if !ok {
+				return newError("first argument to `strings.repeat` must be STRING, got %s", args[0].Type())
+			}
+			n, ok := args[1].(*Integer)
+			if !ok {
+				return newError("second argument to `strings.repeat` must be INTEGER, got %s", args[1].Type())
+			}
+			if n.Value < 0 {
+				return newError("negative count in `strings.repeat`: %d", n.Value)
+			}
+			if len(s.Value) > 0 && n.Value > maxRepeatLength/int64(len(s.Value)) {
+				return newError("`strings.repeat` result too long: %d bytes repeated %d times", len(s.Value), n.Value)
+			}
+			return &String{Value: strings.Repeat(s.Value, int(n.Value))}
+		}},
+		"substring": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			s, ok := args[0].(*String)
+			if !ok {
+				return newError("first argument to `strings.substring` must be STRING, got %s", args[0].Type())
+			}
+			runes := []rune(s.Value)
+			bounds := []int64{0, int64(len(runes))}
+			for i, arg := range args[1:] {
+				n, ok := arg.(*Integer)
+				if !ok {
+					return newError("argument %d to `strings.substring` must be INTEGER, got %s", i+2, arg.Type())
+				}
+				bounds[i] = n.Value
+			}
+			start, end := bounds[0], bounds[1]
+			if start < 0 || end > int64(len(runes)) || start > end {
+				return newError("substring [%d:%d] out of range for length %d", start, end, len(runes))
+			}
+			return &String{Value: string(runes[start:end])}
+		}},
+		"chars": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			s, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `strings.chars` must be STRING, got %s", args[0].Type())
+			}
+			chars := []string{}
+			for _, r := range s.Value {
+				chars = append(chars, string(r))
+			}
+			return stringArray(chars)
+		}},
+		"format": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want=1 or more")
+			}
+			format, ok := args[0].(*String)
+			if !ok {
+				return newError("first argument to `strings.format` must be STRING, got %s", args[0].Type())
+			}
+			return formatString(format.Value, args[1:])
+		}},
+	},
+}
+
+// Formats args with the verbs %d for integers, %s for strings and %v for any value, %% is a percent sign
+func formatString(format string, args []Object) Object {
+	var out strings.Builder
+	next := 0
+	runes := []rune(format)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			out.WriteRune(runes[i])
+			continue
+		}
+		i++
+		if i == len(runes) {
+			return newError("format ends in %%")
+		}
+		verb := runes[i]
+		if verb == '%' {
+			out.WriteRune('%')
+			continue
+		}
+		if verb != 'd' && verb != 's' && verb != 'v' {
+			return newError("unknown verb %%%c in format", verb)
+		}
+		if next == len(args) {
+			return newError("missing argument for %%%c in format", verb)
+		}
+		arg := args[next]
+		next++
+
+		switch verb {
+		case 'd':
+			n, ok := arg.(*Integer)
+			if !ok {
+				return newError("%%d needs INTEGER, got %s", arg.Type())
+			}
+			out.WriteString(n.Inspect())
+		case 's':
+			s, ok := arg.(*String)
+			if !ok {
+				return newError("%%s needs STRING, got %s", arg.Type())
+			}
+			out.WriteString(s.Value)
+		case 'v':
+			//Strings are written without quotes like puts does
+			out.WriteString(arg.Inspect())
+		}
+	}
+	if next != len(args) {
+		return newError("too many arguments for format: %d unused", len(args)-next)
+	}
+	return &String{Value: out.String()}
+}
+
+func stringArray(values []string) *Array {
+	elements := make([]Object, len(values))
+	for i, v := range values {
+		elements[i] = &String{Value: v}
+	}
+	return &Array{Elements: elements}
+}
+
+// Checks that every argument of the builtin name is a string and returns their values
+func stringArgs(name string, args []Object) ([]string, *Error) {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := arg.(*String)
+		if !ok {
+			return nil, newError("argument %d to `strings.%s` must be STRING, got %s", i+1, name, arg.Type())
+		}
+		values[i] = s.Value
+	}
+	return values, nil
+}
+
+func twoStrings(name string, args []Object) (string, string, *Error) {
+	if len(args) != 2 {
+		return "", "", newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	values, err := stringArgs(name, args)
+	if err != nil {
+		return "", "", err
+	}
+	return values[0], values[1], nil
+}
+
+func mapString(name string, fn func(string) string) *Builtin {
+	return &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		values, err := stringArgs(name, args)
+		if err != nil {
+			return err
+		}
+		return &String{Value: fn(values[0])}
+	}}
+}
+
+func testStrings(name string, fn func(string, string) bool) *Builtin {
+	return &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+		s, sub, err := twoStrings(name, args)
+		if err != nil {
+			return err
+		}
+		if fn(s, sub) {
+			return TRUE
+		}
+		return FALSE
+	}}
+}
+
+// Trims white space, or the runes of the second argument when there is one
+func trimBuiltin(name string, space func(string) string, cutset func(string, string) string) *Builtin {
+	return &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+		if len(args) != 1 && len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+		}
+		values, err := stringArgs(name, args)
+		if err != nil {
+			return err
+		}
+		if len(values) == 1 {
+			return &String{Value: space(values[0])}
+		}
+		return &String{Value: cutset(values[0], values[1])}
+	}}
+}
@@ -0,0 +1,60 @@
+package object
+
+import "testing"
+
+func str(s string) *String { return &String{Value: s} }
+func num(n int64) *Integer { return &Integer{Value: n} }
+
+// Calls the member name of the builtin module called module
+func callMember(module, name string, args ...Object) Object {
+	fn := GetBuiltinByName(module).(*Module).Exports[name].(*Builtin)
+	return fn.Fn(NewRuntime(), args...)
+}
+
+func TestStringsModule(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"split", []Object{str("a,b,,c"), str(",")}, "[a, b, , c]"},
+		{"split", []Object{str("héllo"), str("")}, "[h, é, l, l, o]"},
+		{"join", []Object{&Array{Elements: []Object{str("a"), str("ü")}}, str("-")}, "a-ü"},
+		{"join", []Object{&Array{Elements: []Object{str("a"), num(1)}}, str("-")}, "ERROR: `strings.join` needs an array of STRING, got INTEGER at 1"},
+		{"trim", []Object{str("  hi\t\n")}, "hi"},
+		{"trim", []Object{str("xxhixx"), str("x")}, "hi"},
+		{"trimLeft", []Object{str("  hi  ")}, "hi  "},
+		{"trimRight", []Object{str("  hi  ")}, "  hi"},
+		{"upper", []Object{str("héllo")}, "HÉLLO"},
+		{"lower", []Object{str("ÀB")}, "àb"},
+		{"upper", []Object{num(1)}, "ERROR: argument 1 to `strings.upper` must be STRING, got INTEGER"},
+		{"contains", []Object{str("héllo"), str("él")}, "true"},
+		{"startsWith", []Object{str("héllo"), str("hé")}, "true"},
+		{"endsWith", []Object{str("héllo"), str("x")}, "false"},
+		{"indexOf", []Object{str("héllo"), str("l")}, "2"},
+		{"indexOf", []Object{str("héllo"), str("z")}, "-1"},
+		{"replace", []Object{str("a-b-c"), str("-"), str("+")}, "a+b+c"},
+		{"repeat", []Object{str("ab"), num(3)}, "ababab"},
+		{"repeat", []Object{str("ab"), num(-1)}, "ERROR: negative count in `strings.repeat`: -1"},
+		{"repeat", []Object{str("ab"), num(1 << 62)}, "ERROR: `strings.repeat` result too long: 2 bytes repeated 4611686018427387904 times"},
+		{"repeat", []Object{str(""), num(1 << 62)}, ""},
+		{"substring", []Object{str("héllo"), num(1), num(3)}, "él"},
+		{"substring", []Object{str("héllo"), num(3)}, "lo"},
+		{"substring", []Object{str("héllo"), num(2), num(9)}, "ERROR: substring [2:9] out of range for length 5"},
+		{"chars", []Object{str("日本")}, "[日, 本]"},
+		{"chars", []Object{str("")}, "[]"},
+		{"format", []Object{str("%s is %d%%, %v"), str("ü"), num(5), &Array{Elements: []Object{TRUE}}}, "ü is 5%, [true]"},
+		{"format", []Object{str("%d"), str("a")}, "ERROR: %d needs INTEGER, got STRING"},
+		{"format", []Object{str("%s %s"), str("a")}, "ERROR: missing argument for %s in format"},
+		{"format", []Object{str("%s"), str("a"), str("b")}, "ERROR: too many arguments for format: 1 unused"},
+		{"format", []Object{str("%x"), num(1)}, "ERROR: unknown verb %x in format"},
+		{"split", []Object{str("a")}, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		got := callMember("strings", tt.name, tt.args...)
+		if got.Inspect() != tt.expected {
+			t.Errorf("strings.%s: wrong result. got=%q, want=%q", tt.name, got.Inspect(), tt.expected)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// The fs module, every function needs the read or write capability for its path
+var fsModule = &Module{
+	Name: "fs",
+	Exports: map[string]Object{
+		"read": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			path, err := pathArg("read", args, rt.Capabilities.Read, "--allow-read")
+			if err != nil {
+				return err
+			}
+			data, readErr := os.ReadFile(path)
+			if readErr != nil {
+				return newError("fs.read: %s", readErr)
+			}
+			return &String{Value: string(data)}
+		}},
+		"write": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			content, ok := args[1].(*String)
+			if !ok {
+				return newError("second argument to `fs.write` must be STRING, got %s", args[1].Type())
+			}
+			path, err := pathArg("write", args[:1], rt.Capabilities.Write, "--allow-write")
+			if err != nil {
+				return err
+			}
+			if writeErr := os.WriteFile(path, []byte(content.Value), 0o644); writeErr != nil {
+				return newError("fs.write: %s", writeErr)
+			}
+			return nil
+		}},
+		"exists": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			path, err := pathArg("exists", args, rt.Capabilities.Read, "--allow-read")
+			if err != nil {
+				return err
+			}
+			_, statErr := os.Stat(path)
+			if errors.Is(statErr, fs.ErrNotExist) {
+				return FALSE
+			}
+			if statErr != nil {
+				return newError("fs.exists: %s", statErr)
+			}
+			return TRUE
+		}},
+		//Returns the names in a directory, sorted
+		"list": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			path, err := pathArg("list", args, rt.Capabilities.Read, "--allow-read")
+			if err != nil {
+				return err
+			}
+			entries, readErr := os.ReadDir(path)
+			if readErr != nil {
+				return newError("fs.list: %s", readErr)
+			}
+			names := make([]string, len(entries))
+			for i, entry := range entries {
+				names[i] = entry.Name()
+			}
+			sort.Strings(names)
+			return stringArray(names)
+		}},
+		//Removes a file or an empty directory
+		"remove": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			path, err := pathArg("remove", args, rt.Capabilities.Write, "--allow-write")
+			if err != nil {
+				return err
+			}
+			if removeErr := os.Remove(path); removeErr != nil {
+				return newError("fs.remove: %s", removeErr)
+			}
+			return nil
+		}},
+	},
+}
+
+// The os module, env and exec need their capabilities
+// args only holds what the host passed in Runtime.Args and exit only ends the script, not the host
+var osModule = &Module{
+	Name: "os",
+	Exports: map[string]Object{
+		//env(name) is the variable or null when it is not set, env() a hash of every variable
+		"env": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			if !rt.Capabilities.Env {
+				return newError("os.env: permission denied, needs --allow-env")
+			}
+			if len(args) == 0 {
+				env := NewHash()
+				for _, kv := range os.Environ() {
+					if name, value, ok := strings.Cut(kv, "="); ok {
+						env.Set(&String{Value: name}, &String{Value: value})
+					}
+				}
+				return env
+			}
+			name, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `os.env` must be STRING, got %s", args[0].Type())
+			}
+			value, ok := os.LookupEnv(name.Value)
+			if !ok {
+				return nil
+			}
+			return &String{Value: value}
+		}},
+		"args": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return stringArray(rt.Args)
+		}},
+		"exit": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			code := int64(0)
+			if len(args) == 1 {
+				n, ok := args[0].(*Integer)
+				if !ok {
+					return newError("argument to `os.exit` must be INTEGER, got %s", args[0].Type())
+				}
+				code = n.Value
+			}
+			return rt.Exit(int(code))
+		}},
+		//exec(name, args...) runs a program without a shell and returns its output and exit code
+		//as a hash with the keys stdout, stderr and code
+		"exec": &Builtin{Fn: func(rt *Runtime, args ...Object) Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want=1 or more")
+			}
+			if !rt.Capabilities.Exec {
+				return newError("os.exec: permission denied, needs --allow-exec")
+			}
+			argv := make([]string, len(args))
+			for i, arg := range args {
+				s, ok := arg.(*String)
+				if !ok {
+					return newError("argument %d to `os.exec` must be STRING, got %s", i+1, arg.Type())
+				}
+				argv[i] = s.Value
+			}
+
+			ctx := rt.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			var stdout, stderr bytes.Buffer
+			cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+			cmd.Stdout, cmd.Stderr = &stdout, &stderr
+			code := 0
+			if err := cmd.Run(); err != nil {
+				var exitErr *exec.ExitError
+				if !errors.As(err, &exitErr) {
+					return newError("os.exec: %s", err)
+				}
+				code = exitErr.ExitCode()
+			}
+
+			result := NewHash()
+			result.Set(&String{Value: "stdout"}, &String{Value: stdout.String()})
+			result.Set(&String{Value: "stderr"}, &String{Value: stderr.String()})
+			result.Set(&String{Value: "code"}, &Integer{Value: int64(code)})
+			return result
+		}},
+	},
+}
+
+// Returns the path given to the builtin fs.name once paths allows it
+func pathArg(name string, args []Object, paths Paths, flag string) (string, *Error) {
+	if len(args) != 1 {
+		return "", newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	path, ok := args[0].(*String)
+	if !ok {
+		return "", newError("argument to `fs.%s` must be STRING, got %s", name, args[0].Type())
+	}
+	if !paths.Allows(path.Value) {
+		return "", newError("fs.%s: permission denied for %q, needs %s", name, path.Value, flag)
+	}
+	return path.Value, nil
+}
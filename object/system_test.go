@@ -0,0 +1,133 @@
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFsModule(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("s"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	fs := GetBuiltinByName("fs").(*Module)
+	call := func(rt *Runtime, name string, args ...Object) string {
+		result := fs.Exports[name].(*Builtin).Fn(rt, args...)
+		if result == nil {
+			return "null"
+		}
+		return result.Inspect()
+	}
+	path := func(elem ...string) *String { return str(filepath.Join(append([]string{dir}, elem...)...)) }
+
+	sandboxed := NewRuntime()
+	if got, want := call(sandboxed, "read", path("a.txt")), "ERROR: fs.read: permission denied for \""+path("a.txt").Value+"\", needs --allow-read"; got != want {
+		t.Errorf("read without capability. got=%q, want=%q", got, want)
+	}
+	if got := call(sandboxed, "write", path("a.txt"), str("x")); !strings.HasPrefix(got, "ERROR: fs.write: permission denied") {
+		t.Errorf("write without capability. got=%q", got)
+	}
+
+	rt := NewRuntime()
+	rt.Capabilities = Capabilities{Read: Paths{Dirs: []string{dir}}, Write: Paths{Dirs: []string{dir}}}
+	steps := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"exists", []Object{path("a.txt")}, "false"},
+		{"write", []Object{path("a.txt"), str("héllo")}, "null"},
+		{"exists", []Object{path("a.txt")}, "true"},
+		{"read", []Object{path("a.txt")}, "héllo"},
+		{"list", []Object{str(dir)}, "[a.txt, link]"},
+		{"remove", []Object{path("a.txt")}, "null"},
+		{"exists", []Object{path("a.txt")}, "false"},
+		{"read", []Object{path("a.txt")}, "ERROR: fs.read: open " + path("a.txt").Value + ": no such file or directory"},
+		{"write", []Object{path("a.txt"), num(1)}, "ERROR: second argument to `fs.write` must be STRING, got INTEGER"},
+		{"read", []Object{num(1)}, "ERROR: argument to `fs.read` must be STRING, got INTEGER"},
+		{"read", []Object{str(filepath.Join(outside, "secret"))}, "ERROR: fs.read: permission denied for \"" + filepath.Join(outside, "secret") + "\", needs --allow-read"},
+		{"read", []Object{path("..", filepath.Base(outside), "secret")}, "ERROR: fs.read: permission denied for \"" + path("..", filepath.Base(outside), "secret").Value + "\", needs --allow-read"},
+		{"read", []Object{path("link", "secret")}, "ERROR: fs.read: permission denied for \"" + path("link", "secret").Value + "\", needs --allow-read"},
+	}
+	for _, step := range steps {
+		if got := call(rt, step.name, step.args...); got != step.expected {
+			t.Errorf("fs.%s: wrong result. got=%q, want=%q", step.name, got, step.expected)
+		}
+	}
+
+	rt.Capabilities.Read = Paths{All: true}
+	if got := call(rt, "read", path("link", "secret")); got != "s" {
+		t.Errorf("read with every path allowed. got=%q", got)
+	}
+}
+
+func TestOsModule(t *testing.T) {
+	t.Setenv("MSCRIPT_TEST", "yes")
+	os := GetBuiltinByName("os").(*Module)
+	call := func(rt *Runtime, name string, args ...Object) Object {
+		return os.Exports[name].(*Builtin).Fn(rt, args...)
+	}
+
+	rt := NewRuntime()
+	if got := call(rt, "env", str("MSCRIPT_TEST")).Inspect(); got != "ERROR: os.env: permission denied, needs --allow-env" {
+		t.Errorf("env without capability. got=%q", got)
+	}
+	if got := call(rt, "exec", str("go"), str("version")).Inspect(); got != "ERROR: os.exec: permission denied, needs --allow-exec" {
+		t.Errorf("exec without capability. got=%q", got)
+	}
+
+	rt.Capabilities = Capabilities{Env: true, Exec: true}
+	if got := call(rt, "env", str("MSCRIPT_TEST")).Inspect(); got != "yes" {
+		t.Errorf("env. got=%q", got)
+	}
+	if got := call(rt, "env", str("MSCRIPT_TEST_UNSET")); got != nil {
+		t.Errorf("env of an unset variable. got=%q", got.Inspect())
+	}
+	env, ok := call(rt, "env").(*Hash)
+	if !ok {
+		t.Fatalf("env() is not a hash")
+	}
+	if value, _ := env.Get(str("MSCRIPT_TEST")); value == nil || value.Inspect() != "yes" {
+		t.Errorf("env() misses MSCRIPT_TEST. got=%v", value)
+	}
+
+	result, ok := call(rt, "exec", str("go"), str("env"), str("GOOS")).(*Hash)
+	if !ok {
+		t.Fatalf("exec did not return a hash")
+	}
+	if code, _ := result.Get(str("code")); code.Inspect() != "0" {
+		t.Errorf("exec: wrong code. got=%s", code.Inspect())
+	}
+	if stdout, _ := result.Get(str("stdout")); stdout.Inspect() == "" {
+		t.Errorf("exec: empty stdout")
+	}
+	result = call(rt, "exec", str("go"), str("no-such-command")).(*Hash)
+	if code, _ := result.Get(str("code")); code.Inspect() == "0" {
+		t.Errorf("exec of a failing command returned code 0")
+	}
+
+	rt.Args = []string{"a", "b"}
+	if got := call(rt, "args").Inspect(); got != "[a, b]" {
+		t.Errorf("args. got=%q", got)
+	}
+
+	if _, exited := rt.Exited(); exited {
+		t.Fatalf("exited before os.exit")
+	}
+	if got := call(rt, "exit", num(3)).Inspect(); got != "ERROR: exit status 3" {
+		t.Errorf("exit. got=%q", got)
+	}
+	if code, exited := rt.Exited(); !exited || code != 3 {
+		t.Errorf("wrong exit. got=%d, %t", code, exited)
+	}
+	rt.Reset()
+	if _, exited := rt.Exited(); exited {
+		t.Errorf("Reset kept the exit")
+	}
+}
@@ -0,0 +1,205 @@
+// Package optimizer rewrites programs into cheaper ones that behave the same.
+//
+// It folds operators applied to literals, replaces ifs whose condition is a
+// literal with the branch that runs and drops statements after a return.
+// Expressions that fail when run, like 1 / 0, are left alone so they still fail
+// the same way. Programs are optimized after macro expansion, the code inside
+// quote is kept as written since it is data.
+package optimizer
+
+import (
+	"math"
+	"mscript/ast"
+	"mscript/evaluator"
+	"mscript/object"
+	"mscript/token"
+	"strconv"
+)
+
+// Optimizes program in place and returns it
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = statements(program.Statements)
+	return program
+}
+
+// Optimizes stmts, inlining the branch taken by ifs with a literal condition
+// The value of a list is the value of its last statement, which is kept wherever it comes from
+func statements(stmts []ast.Statement) []ast.Statement {
+	out := []ast.Statement{}
+	for i, stmt := range stmts {
+		stmt = statement(stmt)
+
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			if ifExp, ok := es.Expression.(*ast.IfExpression); ok {
+				if taken, ok := branch(ifExp); ok {
+					//Blocks do not open scopes so the statements of the branch can run in place
+					if taken != nil && len(taken.Statements) > 0 {
+						out = append(out, taken.Statements...)
+						if returns(out[len(out)-1]) {
+							break
+						}
+						continue
+					}
+					//A branch without statements only matters for the value of the last statement
+					if i < len(stmts)-1 {
+						continue
+					}
+				}
+			}
+		}
+
+		out = append(out, stmt)
+		if returns(stmt) {
+			break
+		}
+	}
+	return out
+}
+
+func returns(stmt ast.Statement) bool {
+	_, ok := stmt.(*ast.ReturnStatement)
+	return ok
+}
+
+func statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = expression(stmt.Value)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		stmt.Expression = expression(stmt.Expression)
+	case *ast.ExportStatement:
+		if stmt.Statement != nil {
+			statement(stmt.Statement)
+		}
+	case *ast.BlockStatement:
+		block(stmt)
+	}
+	return stmt
+}
+
+func block(b *ast.BlockStatement) *ast.BlockStatement {
+	if b != nil {
+		b.Statements = statements(b.Statements)
+	}
+	return b
+}
+
+func expression(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		exp.Right = expression(exp.Right)
+		return fold(exp, exp.Token, exp.Right)
+
+	case *ast.InfixExpression:
+		exp.Left = expression(exp.Left)
+		exp.Right = expression(exp.Right)
+		return fold(exp, exp.Token, exp.Left, exp.Right)
+
+	case *ast.IfExpression:
+		exp.Condition = expression(exp.Condition)
+		exp.Consequence = block(exp.Consequence)
+		exp.Alternative = block(exp.Alternative)
+		//As an expression only a branch holding a single expression can replace the if
+		if taken, ok := branch(exp); ok && taken != nil && len(taken.Statements) == 1 {
+			if es, ok := taken.Statements[0].(*ast.ExpressionStatement); ok && es.Expression != nil {
+				return es.Expression
+			}
+		}
+
+	case *ast.FunctionLiteral:
+		exp.Body = block(exp.Body)
+
+	case *ast.CallExpression:
+		if ident, ok := exp.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return exp
+		}
+		exp.Function = expression(exp.Function)
+		for i, arg := range exp.Arguments {
+			exp.Arguments[i] = expression(arg)
+		}
+
+	case *ast.ArrayLiteral:
+		for i, el := range exp.Elements {
+			exp.Elements[i] = expression(el)
+		}
+
+	case *ast.HashLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(exp.Pairs))
+		for i, key := range exp.Keys {
+			value := exp.Pairs[key]
+			exp.Keys[i] = expression(key)
+			pairs[exp.Keys[i]] = expression(value)
+		}
+		exp.Pairs = pairs
+
+	case *ast.IndexExpression:
+		exp.Left = expression(exp.Left)
+		exp.Index = expression(exp.Index)
+
+	case *ast.MemberExpression:
+		exp.Object = expression(exp.Object)
+	}
+	return exp
+}
+
+// Returns the branch an if with a literal condition takes, nil when it has no else and the condition is false
+func branch(exp *ast.IfExpression) (*ast.BlockStatement, bool) {
+	var truthy bool
+	switch cond := exp.Condition.(type) {
+	case *ast.Boolean:
+		truthy = cond.Value
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral:
+		truthy = true
+	default:
+		return nil, false
+	}
+	if truthy {
+		return exp.Consequence, true
+	}
+	return exp.Alternative, true
+}
+
+// Replaces exp by the literal it evaluates to when its operands are number or boolean literals
+// Strings are not folded: a literal is a single constant in the vm, a concatenation a new string
+// each time, and the two compare differently with ==. Bang works on any literal since it only
+// looks at truthiness.
+func fold(exp ast.Expression, tok token.Token, operands ...ast.Expression) ast.Expression {
+	for _, operand := range operands {
+		switch operand.(type) {
+		case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean:
+		case *ast.StringLiteral:
+			if prefix, ok := exp.(*ast.PrefixExpression); !ok || prefix.Operator != "!" {
+				return exp
+			}
+		default:
+			return exp
+		}
+	}
+
+	switch result := evaluator.Eval(exp, object.NewEnvironment()).(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{
+			Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(result.Value, 10), Line: tok.Line, Column: tok.Column},
+			Value: result.Value,
+		}
+	case *object.Float:
+		//Infinities and NaN have no literal
+		if math.IsInf(result.Value, 0) || math.IsNaN(result.Value) {
+			return exp
+		}
+		return &ast.FloatLiteral{
+			Token: token.Token{Type: token.FLOAT, Literal: result.Inspect(), Line: tok.Line, Column: tok.Column},
+			Value: result.Value,
+		}
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false", Line: tok.Line, Column: tok.Column}
+		if result.Value {
+			t.Type, t.Literal = token.TRUE, "true"
+		}
+		return &ast.Boolean{Token: t, Value: result.Value}
+	}
+	//Errors are kept for the program to report when it gets there
+	return exp
+}
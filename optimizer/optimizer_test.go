@@ -0,0 +1,52 @@
+package optimizer
+
+import (
+	"mscript/lexer"
+	"mscript/parser"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 3 + 1", "7"},
+		{"let x = (1 + 2) * (10 / 3) - -4;", "let x = 13;"},
+		{"1 < 2; 3 == 4; true != false; 1 == true", "truefalsetruefalse"},
+		{"!true; !!5; !\"\"", "falsetruefalse"},
+		{"x + 2 * 3", "(x + 6)"},
+		{"1 / 0", "(1 / 0)"},
+		{"1.5 * 2 + 1; 7 / 2.0; -2.5; 0.5 < 1", "4.03.5-2.5true"},
+		{"1e308 * 10; 1.0 / 0", "(1e308 * 10)(1.0 / 0)"},
+		{"let y = if (0.0) { 1 } else { 2 };", "let y = 1;"},
+		{"1 + 2 * (3 / 0)", "(1 + (2 * (3 / 0)))"},
+		{"-true; 1 + true; true < false", "(-true)(1 + true)(true < false)"},
+		{"\"a\" + \"b\"; \"a\" == \"a\"", "(a + b)(a == a)"},
+		{"let y = if (1 < 2) { 10 } else { 20 };", "let y = 10;"},
+		{"let y = if (1 > 2) { 10 } else { 20 };", "let y = 20;"},
+		{"let y = if (false) { 10 };", "let y = iffalse 10;"},
+		{"let y = if (0) { 1; 2 } else { 3 };", "let y = if0 12else 3;"},
+		{"if (true) { let a = 1; puts(a) } else { puts(2) }; a", "let a = 1;puts(a)a"},
+		{"if (false) { puts(1) }; 2", "2"},
+		{"if (!true) { puts(1) }", "iffalse puts(1)"},
+		{"if (x) { 1 + 1 } else { 2 * 2 }", "ifx 2else 4"},
+		{"let f = fn() { return 1; puts(2); 3 };", "let f = fn() return 1;;"},
+		{"let f = fn() { if (true) { return 1; } puts(2) };", "let f = fn() return 1;;"},
+		{"return 1; puts(2)", "return 1;"},
+		{"[1 + 1, {2 * 2: 3 - 3}[4]]", "[2, ({4: 0}[4])]"},
+		{"f(1 + 1)(2 * 2).x", "f(2)(4).x"},
+		{"quote(1 + 1)", "quote((1 + 1))"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%q: parser errors: %v", tt.input, p.Errors())
+		}
+		if got := Optimize(program).String(); got != tt.expected {
+			t.Errorf("%q: got=%q, want=%q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"mscript/ast"
 	"mscript/lexer"
 	"mscript/token"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -17,6 +20,7 @@ const (
 	PRODUCT     // *
 	PREFIX      //Prefix
 	CALL        //myFunction(x)
+	INDEX       //array[index]
 )
 
 var precedences = map[token.TokenType]int{
@@ -29,11 +33,21 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
+	token.DOT:      CALL,
+	token.LBRACKET: INDEX,
+}
+
+// Returns the binding power of an infix operator, LOWEST for any other token
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
 }
 
 type Parser struct {
 	l      *lexer.Lexer //Copy of lexer
-	errors []string     //An array of errors collected along the way
+	errors []Error      //An array of errors collected along the way
 
 	curToken  token.Token //Current token parsing
 	peekToken token.Token //Next token parsing
@@ -41,6 +55,9 @@ type Parser struct {
 	//Check if cur token has a parsing function associated
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	tracer     io.Writer //Where parse functions log their entry and exit, nil when not tracing
+	traceLevel int
 }
 
 type (
@@ -51,7 +68,7 @@ type (
 // Creates a new instance of Parser
 // Has a copy of the lexer the current token and the next token
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []Error{}}
 
 	//INIT map
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -59,6 +76,7 @@ func New(l *lexer.Lexer) *Parser {
 	//Add parse prefix functions
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -66,7 +84,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	//INIT map
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -81,6 +102,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
 	p.nextToken()
@@ -88,6 +111,7 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
+	defer p.untrace(p.trace("parseIdentifier"))
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
@@ -117,19 +141,38 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 // Checking the type of statement we need to parse and returning the resulting statement
+// nil when it does not parse, never a nil pointer in the interface, so partial programs hold no nil statements
 func (p *Parser) parseStatement() ast.Statement {
+	defer p.untrace(p.trace("parseStatement"))
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if let := p.parseLetStatement(); let != nil {
+			stmt = let
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if ret := p.parseReturnStatement(); ret != nil {
+			stmt = ret
+		}
+	case token.IMPORT:
+		if imp := p.parseImportStatement(); imp != nil {
+			stmt = imp
+		}
+	case token.EXPORT:
+		if exp := p.parseExportStatement(); exp != nil {
+			stmt = exp
+		}
 	default:
-		return p.parseExpressionStatement()
+		if es := p.parseExpressionStatement(); es != nil {
+			stmt = es
+		}
 	}
+	return stmt
 }
 
 // Handles parsing let statements
 func (p *Parser) parseLetStatement() *ast.LetStatement {
+	defer p.untrace(p.trace("parseLetStatement"))
 	//Creating let statement with the current Token = to cur token (let)
 	stmt := &ast.LetStatement{Token: p.curToken}
 
@@ -141,6 +184,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	//Set the identifers token to cur token and the value of the current tokens literal (var name)
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if stmt.Type = p.parseTypeAnnotation(); stmt.Type == nil {
+			return nil
+		}
+	}
+
 	//If an equal sign does not follow the identifer return nil
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -159,6 +210,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 // Parses ReturnStatement
 // Returns ReturnStatement
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	defer p.untrace(p.trace("parseReturnStatement"))
 	//Creating ReturnStatement
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
@@ -172,8 +224,56 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// Parses import "<path>" as <identifier>;
+// Without 'as' the module is bound to its file name minus the extension
+func (p *Parser) parseImportStatement() ast.Statement {
+	defer p.untrace(p.trace("parseImportStatement"))
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = p.curToken.Literal
+
+	if p.peekTokenIs(token.AS) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	} else {
+		base := filepath.Base(stmt.Path)
+		name := strings.TrimSuffix(base, filepath.Ext(base))
+		stmt.Name = &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// Parses export let <identifier> = <expression>;
+func (p *Parser) parseExportStatement() ast.Statement {
+	defer p.untrace(p.trace("parseExportStatement"))
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+
+	return stmt
+}
+
 // Parse Expression Statement
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	defer p.untrace(p.trace("parseExpressionStatement"))
 	//Create Expression Statement
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -190,6 +290,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 // Parse prefix left hand side of expression if prefix
 func (p *Parser) parseExpression(precednce int) ast.Expression {
+	defer p.untrace(p.trace("parseExpression(" + precedenceNames[precednce] + ")"))
 	//Get prefix function for cur token
 	prefix := p.prefixParseFns[p.curToken.Type]
 
@@ -214,12 +315,26 @@ func (p *Parser) parseExpression(precednce int) ast.Expression {
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseIntegerLiteral"))
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("Could not parse %q as interger", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, "Could not parse %q as interger", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseFloatLiteral"))
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(p.curToken, "Could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -229,6 +344,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 // create prefix expression and call parseExpression
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.untrace(p.trace("parsePrefixExpression"))
 	//create PrefixExpression
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -245,6 +361,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 // Create Infix expression get operator precedence and call parseExpression on the right
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseInfixExpression"))
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -264,11 +381,13 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
+	defer p.untrace(p.trace("parseBoolean"))
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
 // Just parse the inner expression
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.untrace(p.trace("parseGroupedExpression"))
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
@@ -282,6 +401,7 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 
 // Handle if expression
 func (p *Parser) parseIfExpression() ast.Expression {
+	defer p.untrace(p.trace("parseIfExpression"))
 	expression := &ast.IfExpression{Token: p.curToken}
 
 	//if ( <exp>
@@ -320,6 +440,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	defer p.untrace(p.trace("parseBlockStatement"))
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
@@ -332,11 +453,13 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 	return block
 }
 
 // Parse function
 func (p *Parser) parseFunctionLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseFunctionLiteral"))
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
 	//Check for parens
@@ -344,7 +467,19 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 	//Parse parameters
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.ParameterTypes = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+	lit.Rparen = p.curToken
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if lit.ReturnType = p.parseTypeAnnotation(); lit.ReturnType == nil {
+			return nil
+		}
+	}
 
 	//Check for start of body
 	if !p.expectPeek(token.LBRACE) {
@@ -357,63 +492,247 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-// Parse parameters
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// Parse macro, same shape as a function
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseMacroLiteral"))
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	var types []*ast.TypeAnnotation
+	lit.Parameters, types = p.parseFunctionParameters()
+	lit.Rparen = p.curToken
+	if types != nil {
+		p.addError(lit.Token, "macro parameters cannot have type annotations")
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+// Parse parameters and their type annotations, the annotations are nil when none are written
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []*ast.TypeAnnotation) {
+	defer p.untrace(p.trace("parseFunctionParameters"))
 	identifiers := []*ast.Identifier{}
+	var types []*ast.TypeAnnotation
+	annotated := false
 
 	//check if empty param list
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, nil
 	}
-	p.nextToken()
-
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
 
-	//While commas
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+	for {
 		p.nextToken()
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
+
+		var t *ast.TypeAnnotation
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if t = p.parseTypeAnnotation(); t == nil {
+				return nil, nil
+			}
+			annotated = true
+		}
+		types = append(types, t)
+
+		//While commas
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 	//Check for closing paren
 	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+
+	if !annotated {
+		return identifiers, nil
+	}
+	return identifiers, types
+}
+
+// Parses the type annotation starting at the current token
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	defer p.untrace(p.trace("parseTypeAnnotation"))
+	t := &ast.TypeAnnotation{Token: p.curToken}
+
+	switch p.curToken.Type {
+	case token.IDENT:
+		t.Name = p.curToken.Literal
+
+	case token.LBRACKET:
+		t.Name = "array"
+		p.nextToken()
+		elem := p.parseTypeAnnotation()
+		if elem == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		t.Elements = []*ast.TypeAnnotation{elem}
+
+	case token.LBRACE:
+		t.Name = "hash"
+		p.nextToken()
+		key := p.parseTypeAnnotation()
+		if key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseTypeAnnotation()
+		if value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		t.Elements = []*ast.TypeAnnotation{key, value}
+
+	case token.FUNCTION:
+		t.Name = "fn"
+		//A bare fn is any function
+		if !p.peekTokenIs(token.LPAREN) {
+			return t
+		}
+		p.nextToken()
+		t.Elements = []*ast.TypeAnnotation{}
+		for !p.peekTokenIs(token.RPAREN) {
+			if len(t.Elements) > 0 && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+			p.nextToken()
+			param := p.parseTypeAnnotation()
+			if param == nil {
+				return nil
+			}
+			t.Elements = append(t.Elements, param)
+		}
+		p.nextToken()
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if t.Result = p.parseTypeAnnotation(); t.Result == nil {
+				return nil
+			}
+		}
+
+	default:
+		p.addError(p.curToken, "expected a type, got %s instead", p.curToken.Type)
 		return nil
 	}
 
-	return identifiers
+	return t
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseCallExpression"))
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+// Parse [<expression>, ...]
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseArrayLiteral"))
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
+	return array
+}
+
+// Parse {<expression>: <expression>, ...}
+func (p *Parser) parseHashLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseHashLiteral"))
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Pairs = make(map[ast.Expression]ast.Expression)
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
 
-	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return args
+		value := p.parseExpression(LOWEST)
+
+		hash.Keys = append(hash.Keys, key)
+		hash.Pairs[key] = value
+
+		//Pairs are separated by commas, no comma means the hash has to end
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
 	}
+	hash.Rbrace = p.curToken
+
+	return hash
+}
+
+// Parse <expression>[<expression>]
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseIndexExpression"))
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken
+
+	return exp
+}
+
+// Parse <expression>.<identifier>
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseMemberExpression"))
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+// Parse a comma separated list of expressions up to the end token
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	defer p.untrace(p.trace("parseExpressionList"))
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
-	return args
+	return list
 }
 
 // Helper for adding a function to to prefix map
@@ -428,11 +747,11 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 
 // Add error for unknown prefix function
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, "no prefix parse function for %s", t)
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseStringLiteral"))
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
@@ -474,14 +793,36 @@ func (p *Parser) curPrecedence() int {
 	return LOWEST
 }
 
+// A parse error and the position of the token it was found at
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e Error) String() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
 // Return the array of errors
 func (p *Parser) Errors() []string {
+	messages := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		messages[i] = err.Message
+	}
+	return messages
+}
+
+// Returns the errors with their positions, for editors and other tools
+func (p *Parser) ErrorList() []Error {
 	return p.errors
 }
 
+func (p *Parser) addError(tok token.Token, format string, a ...interface{}) {
+	p.errors = append(p.errors, Error{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, a...)})
+}
+
 // Add error message to p.errors
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
-
+	p.addError(p.peekToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
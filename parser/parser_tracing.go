@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"mscript/token"
+	"strings"
+)
+
+var precedenceNames = []string{
+	LOWEST:      "LOWEST",
+	EQUALS:      "EQUALS",
+	LESSGREATER: "LESSGREATER",
+	SUM:         "SUM",
+	PRODUCT:     "PRODUCT",
+	PREFIX:      "PREFIX",
+	CALL:        "CALL",
+	INDEX:       "INDEX",
+}
+
+// Logs the entry and exit of every parse function to w, indented by nesting depth
+// Call before ParseProgram, a nil w turns tracing off
+func (p *Parser) Trace(w io.Writer) {
+	p.tracer = w
+	p.traceLevel = 0
+}
+
+// Used as defer p.untrace(p.trace("parseXxx")) at the top of each parse function
+func (p *Parser) trace(msg string) string {
+	if p.tracer == nil {
+		return msg
+	}
+	p.tracePrint("BEGIN " + msg)
+	p.traceLevel++
+	return msg
+}
+
+func (p *Parser) untrace(msg string) {
+	if p.tracer == nil {
+		return
+	}
+	p.traceLevel--
+	p.tracePrint("END " + msg)
+}
+
+func (p *Parser) tracePrint(msg string) {
+	fmt.Fprintf(p.tracer, "%s%s (cur: %s, peek: %s)\n", strings.Repeat("\t", p.traceLevel), msg,
+		describe(p.curToken), describe(p.peekToken))
+}
+
+func describe(t token.Token) string {
+	if t.Type == token.EOF {
+		return "EOF"
+	}
+	return fmt.Sprintf("%s %q", t.Type, t.Literal)
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"bytes"
+	"mscript/lexer"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	var buf bytes.Buffer
+	p := New(lexer.New("-a * b"))
+	p.Trace(&buf)
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := []string{
+		`BEGIN parseStatement (cur: - "-", peek: IDENT "a")`,
+		`	BEGIN parseExpressionStatement (cur: - "-", peek: IDENT "a")`,
+		`		BEGIN parseExpression(LOWEST) (cur: - "-", peek: IDENT "a")`,
+		`			BEGIN parsePrefixExpression (cur: - "-", peek: IDENT "a")`,
+		`				BEGIN parseExpression(PREFIX) (cur: IDENT "a", peek: * "*")`,
+		`					BEGIN parseIdentifier (cur: IDENT "a", peek: * "*")`,
+		`					END parseIdentifier (cur: IDENT "a", peek: * "*")`,
+		`				END parseExpression(PREFIX) (cur: IDENT "a", peek: * "*")`,
+		`			END parsePrefixExpression (cur: IDENT "a", peek: * "*")`,
+		`			BEGIN parseInfixExpression (cur: * "*", peek: IDENT "b")`,
+		`				BEGIN parseExpression(PRODUCT) (cur: IDENT "b", peek: EOF)`,
+		`					BEGIN parseIdentifier (cur: IDENT "b", peek: EOF)`,
+		`					END parseIdentifier (cur: IDENT "b", peek: EOF)`,
+		`				END parseExpression(PRODUCT) (cur: IDENT "b", peek: EOF)`,
+		`			END parseInfixExpression (cur: IDENT "b", peek: EOF)`,
+		`		END parseExpression(LOWEST) (cur: IDENT "b", peek: EOF)`,
+		`	END parseExpressionStatement (cur: IDENT "b", peek: EOF)`,
+		`END parseStatement (cur: IDENT "b", peek: EOF)`,
+	}
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(got) != len(expected) {
+		t.Fatalf("wrong number of trace lines. got=%d, want=%d\n%s", len(got), len(expected), buf.String())
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("line %d wrong.\ngot= %q\nwant=%q", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestTraceOffByDefault(t *testing.T) {
+	p := New(lexer.New("let x = 1;"))
+	p.ParseProgram()
+	if p.traceLevel != 0 {
+		t.Errorf("traceLevel changed without a tracer. got=%d", p.traceLevel)
+	}
+}
@@ -0,0 +1,51 @@
+package repl
+
+import (
+	"mscript/highlight"
+	"mscript/object"
+	"strings"
+)
+
+// Colors of results by their type, types not listed are not colored
+var typeColors = map[object.ObjectType]string{
+	object.STRING_OBJ:            highlight.Green,
+	object.INTEGER_OBJ:           highlight.Cyan,
+	object.FLOAT_OBJ:             highlight.Cyan,
+	object.BOOLEAN_OBJ:           highlight.Yellow,
+	object.NULL_OBJ:              highlight.Gray,
+	object.ERROR_OBJ:             highlight.Red,
+	object.FUNCTION_OBJ:          highlight.Magenta,
+	object.CLOSURE_OBJ:           highlight.Magenta,
+	object.COMPILED_FUNCTION_OBJ: highlight.Magenta,
+	object.MACRO_OBJ:             highlight.Magenta,
+	object.BUILTIN_OBJ:           highlight.Blue,
+	object.MODULE_OBJ:            highlight.Blue,
+	object.REGEX_OBJ:             highlight.Green,
+}
+
+// Prints results and the line being edited in color
+// Only meant for terminals, see NO_COLOR
+func WithColor(color bool) Option {
+	return func(s *session) { s.color = color }
+}
+
+// Returns what Inspect shows for obj with ANSI colors
+// The elements of arrays and hashes are colored by their own type
+func colorize(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Array:
+		elements := make([]string, len(obj.Elements))
+		for i, el := range obj.Elements {
+			elements[i] = colorize(el)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+
+	case *object.Hash:
+		pairs := []string{}
+		for _, p := range obj.SortedPairs() {
+			pairs = append(pairs, colorize(p.Key)+": "+colorize(p.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+	return highlight.Color(typeColors[obj.Type()], obj.Inspect())
+}
@@ -0,0 +1,246 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"mscript/ast"
+	"mscript/compiler"
+	"mscript/lexer"
+	"mscript/object"
+	"mscript/token"
+	"os"
+	"strings"
+	"time"
+)
+
+type command struct {
+	args string //Shown after the name in :help
+	help string
+	run  func(s *session, arg string)
+}
+
+var commands map[string]command
+
+// Set up in init since :help refers back to the table
+func init() {
+	commands = map[string]command{
+		"help":     {"", "show this help", (*session).help},
+		"tokens":   {"expr", "show the tokens the lexer produces for expr", (*session).tokens},
+		"ast":      {"expr", "show the parse tree of expr", (*session).ast},
+		"bytecode": {"expr", "show the compiled instructions and constants of expr", (*session).bytecode},
+		"env":      {"", "list the bindings and macros of the session", (*session).listEnv},
+		"load":     {"file", "evaluate file into the session", (*session).loadCommand},
+		"reset":    {"", "forget every binding, macro and loaded module", (*session).resetCommand},
+		"time":     {"expr", "evaluate expr and report how long it took", (*session).time},
+		"save":     {"file", "write every input evaluated without an error to file", (*session).saveCommand},
+		"snapshot": {"file", "write the bindings of the session, closures included, to file", (*session).snapshotCommand},
+		"restore":  {"file", "replace the bindings of the session with those in a snapshot", (*session).restoreCommand},
+	}
+}
+
+var commandOrder = []string{"help", "tokens", "ast", "bytecode", "env", "load", "reset", "time", "save", "snapshot", "restore"}
+
+// Runs a line of the form :name argument
+func (s *session) command(line string) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, try :help\n", name)
+		return
+	}
+	arg = strings.TrimSpace(arg)
+	if cmd.args != "" && arg == "" {
+		fmt.Fprintf(s.out, "usage: :%s %s\n", name, cmd.args)
+		return
+	}
+	cmd.run(s, arg)
+}
+
+func (s *session) help(string) {
+	for _, name := range commandOrder {
+		cmd := commands[name]
+		fmt.Fprintf(s.out, "  %-16s %s\n", strings.TrimSpace(":"+name+" "+cmd.args), cmd.help)
+	}
+	fmt.Fprintf(s.out, "  %-16s %s\n", CANCEL, "drop the input of an unfinished multi-line statement")
+}
+
+func (s *session) tokens(src string) {
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%d:%d\t%-9s %q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+	}
+}
+
+func (s *session) ast(src string) {
+	program, ok := s.parse(src)
+	if !ok {
+		return
+	}
+	ast.Walk(&treePrinter{out: s.out}, program)
+}
+
+// Prints one node per line, children indented under their parent
+type treePrinter struct {
+	out   io.Writer
+	depth int
+}
+
+func (tp *treePrinter) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		tp.depth--
+		return nil
+	}
+
+	label := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	switch n := node.(type) {
+	case *ast.Identifier:
+		label += " " + n.Value
+	case *ast.IntegerLiteral:
+		label += " " + n.TokenLiteral()
+	case *ast.FloatLiteral:
+		label += " " + n.TokenLiteral()
+	case *ast.StringLiteral:
+		label += " " + fmt.Sprintf("%q", n.Value)
+	case *ast.Boolean:
+		label += " " + n.TokenLiteral()
+	case *ast.PrefixExpression:
+		label += " " + n.Operator
+	case *ast.InfixExpression:
+		label += " " + n.Operator
+	case *ast.ImportStatement:
+		label += " " + fmt.Sprintf("%q", n.Path)
+	}
+	fmt.Fprintf(tp.out, "%s%s\n", strings.Repeat("  ", tp.depth), label)
+	tp.depth++
+	return tp
+}
+
+func (s *session) bytecode(src string) {
+	program, ok := s.parse(src)
+	if !ok {
+		return
+	}
+
+	//Session bindings are visible to the compiled code as globals
+	symbols := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbols.DefineBuiltin(i, v.Name)
+	}
+	for _, name := range s.env.Names() {
+		symbols.Define(name)
+	}
+
+	comp := compiler.NewWithState(symbols, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(s.out, "ERROR: %s\n", err)
+		return
+	}
+	bytecode := comp.Bytecode()
+	io.WriteString(s.out, bytecode.Instructions.String())
+	if len(bytecode.Constants) == 0 {
+		return
+	}
+	io.WriteString(s.out, "constants:\n")
+	for i, c := range bytecode.Constants {
+		fn, ok := c.(*object.CompiledFunction)
+		if !ok {
+			fmt.Fprintf(s.out, "%4d %s %s\n", i, c.Type(), c.Inspect())
+			continue
+		}
+		fmt.Fprintf(s.out, "%4d %s locals=%d params=%d\n", i, c.Type(), fn.NumLocals, fn.NumParameters)
+		for _, line := range strings.Split(strings.TrimSuffix(fn.Instructions.String(), "\n"), "\n") {
+			fmt.Fprintf(s.out, "     %s\n", line)
+		}
+	}
+}
+
+func (s *session) listEnv(string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, summarize(val))
+	}
+	for _, name := range s.macros.Names() {
+		val, _ := s.macros.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, summarize(val))
+	}
+}
+
+// A one line description of obj, functions show their parameters only
+func summarize(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Function:
+		return "fn(" + joinIdentifiers(obj.Parameters) + ") {...}"
+	case *object.Macro:
+		return "macro(" + joinIdentifiers(obj.Parameters) + ") {...}"
+	}
+	return strings.ReplaceAll(obj.Inspect(), "\n", " ")
+}
+
+func joinIdentifiers(idents []*ast.Identifier) string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+func (s *session) loadCommand(path string) {
+	result, err := s.load(path)
+	if err != nil {
+		fmt.Fprintf(s.out, "ERROR: %s\n", err)
+		return
+	}
+	if isError(result) {
+		s.print(result)
+	}
+}
+
+func (s *session) resetCommand(string) {
+	s.reset()
+	io.WriteString(s.out, "session cleared\n")
+}
+
+func (s *session) time(src string) {
+	start := time.Now()
+	result := s.eval(src, "")
+	elapsed := time.Since(start)
+	s.print(result)
+	fmt.Fprintf(s.out, "took %s\n", elapsed)
+}
+
+func (s *session) saveCommand(path string) {
+	if err := s.save(path); err != nil {
+		fmt.Fprintf(s.out, "ERROR: %s\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d inputs to %s\n", len(s.inputs), path)
+}
+
+func (s *session) snapshotCommand(path string) {
+	skipped, err := s.snapshot(path)
+	if err != nil {
+		fmt.Fprintf(s.out, "ERROR: %s\n", err)
+		return
+	}
+	for _, msg := range skipped {
+		fmt.Fprintf(s.out, "skipped %s\n", msg)
+	}
+	fmt.Fprintf(s.out, "snapshot written to %s\n", path)
+}
+
+func (s *session) restoreCommand(path string) {
+	if err := s.restore(path); err != nil {
+		fmt.Fprintf(s.out, "ERROR: %s\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "restored %s\n", path)
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+func readFile(path string) (string, error) {
+	src, err := os.ReadFile(path)
+	return string(src), err
+}
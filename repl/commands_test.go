@@ -0,0 +1,80 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		setup    string
+		command  string
+		expected string
+	}{
+		{"", ":tokens let x", "1:1\tLET       \"let\"\n1:5\tIDENT     \"x\"\n"},
+		{"", ":ast -a", "Program\n  ExpressionStatement\n    PrefixExpression -\n      Identifier a\n"},
+		{"let a = 1;", ":bytecode a", "0000 OpGetGlobal 0\n0003 OpPop\n"},
+		{"", ":bytecode missing", "ERROR: identifier not found: missing\n"},
+		{"let b = [1]; let a = fn(x, y) { x };", ":env", "a = fn(x, y) {...}\nb = [1]\n"},
+		{"let m = macro(x) { x };", ":env", "m = macro(x) {...}\n"},
+		{"let a = 1;", ":reset", "session cleared\n"},
+		{"", ":ast", "usage: :ast expr\n"},
+		{"", ":frobnicate", "unknown command :frobnicate, try :help\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		s := newSession(&out)
+		s.eval(tt.setup, "")
+		s.command(tt.command)
+		if out.String() != tt.expected {
+			t.Errorf("%s wrong output.\ngot= %q\nwant=%q", tt.command, out.String(), tt.expected)
+		}
+	}
+}
+
+func TestLoadAndReset(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "lib.ms"), []byte(`export let two = 2;`), 0o644)
+	os.WriteFile(filepath.Join(dir, "main.ms"), []byte(`import "lib.ms"; let four = lib.two * 2;`), 0o644)
+
+	var out bytes.Buffer
+	s := newSession(&out)
+	s.command(":load " + filepath.Join(dir, "main.ms"))
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output from :load: %q", out.String())
+	}
+	testSessionValue(t, s, "four", "4")
+
+	s.command(":reset")
+	out.Reset()
+	s.print(s.eval("four", ""))
+	if !strings.Contains(out.String(), "identifier not found: four") {
+		t.Errorf("binding survived :reset. got=%q", out.String())
+	}
+}
+
+func TestTime(t *testing.T) {
+	var out bytes.Buffer
+	s := newSession(&out)
+	s.command(":time 1 + 2")
+
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != "3" || !strings.HasPrefix(lines[1], "took ") {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func testSessionValue(t *testing.T, s *session, name, expected string) {
+	t.Helper()
+	val, ok := s.env.Get(name)
+	if !ok {
+		t.Fatalf("%s not bound", name)
+	}
+	if val.Inspect() != expected {
+		t.Errorf("%s wrong. got=%s, want=%s", name, val.Inspect(), expected)
+	}
+}
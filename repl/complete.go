@@ -0,0 +1,71 @@
+package repl
+
+import (
+	"mscript/object"
+	"mscript/token"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Completes the name before pos: command names after a leading ':', module exports
+// after "module." and otherwise keywords, builtins and the names bound in the session
+func (s *session) complete(line string, pos int) (int, []string) {
+	runes := []rune(line)
+	start := pos
+	for start > 0 && isNameRune(runes[start-1]) {
+		start--
+	}
+	prefix := string(runes[start:pos])
+
+	var names []string
+	switch {
+	case start > 0 && runes[start-1] == ':' && strings.TrimSpace(string(runes[:start-1])) == "":
+		names = append(names, commandOrder...)
+
+	case start > 0 && runes[start-1] == '.':
+		end := start - 1
+		objStart := end
+		for objStart > 0 && isNameRune(runes[objStart-1]) {
+			objStart--
+		}
+		val, ok := s.env.Get(string(runes[objStart:end]))
+		if !ok {
+			val = object.GetBuiltinByName(string(runes[objStart:end]))
+			ok = val != nil
+		}
+		if ok {
+			if module, ok := val.(*object.Module); ok {
+				for name := range module.Exports {
+					names = append(names, name)
+				}
+			}
+		}
+
+	default:
+		names = append(names, token.Keywords()...)
+		for _, b := range object.Builtins {
+			names = append(names, b.Name)
+		}
+		names = append(names, s.env.Names()...)
+		names = append(names, s.macros.Names()...)
+	}
+
+	return start, matching(names, prefix)
+}
+
+// Returns the names starting with prefix, sorted and without duplicates
+func matching(names []string, prefix string) []string {
+	sort.Strings(names)
+	matches := []string{}
+	for i, name := range names {
+		if strings.HasPrefix(name, prefix) && (i == 0 || names[i-1] != name) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
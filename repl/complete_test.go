@@ -0,0 +1,49 @@
+package repl
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "shapes.ms"), []byte(`export let square = 1; export let circle = 2; export let sphere = 3;`), 0o644)
+
+	s := newSession(io.Discard)
+	s.eval(`let lemon = 1; let length = 2; let unless = macro(c) { c };`, filepath.Join(dir, "main.ms"))
+	s.eval(`import "shapes.ms";`, filepath.Join(dir, "main.ms"))
+
+	tests := []struct {
+		line          string
+		expectedStart int
+		expected      string
+	}{
+		{"le", 0, "lemon len length let"},
+		{"x + le", 4, "lemon len length let"},
+		{"un", 0, "unless"},
+		{"ret", 0, "return"},
+		{"pu", 0, "puts"},
+		{"zz", 0, ""},
+		{"shapes.s", 7, "sphere square"},
+		{"shapes.", 7, "circle sphere square"},
+		{"lemon.", 6, ""},
+		{"strings.t", 8, "trim trimLeft trimRight"},
+		{":to", 1, "tokens"},
+		{"  :re", 3, "reset restore"},
+		{"x :re", 3, "re readline return"},
+	}
+
+	for _, tt := range tests {
+		start, candidates := s.complete(tt.line, len([]rune(tt.line)))
+		if start != tt.expectedStart {
+			t.Errorf("%q: wrong start. got=%d, want=%d", tt.line, start, tt.expectedStart)
+		}
+		got := strings.Join(candidates, " ")
+		if got != tt.expected {
+			t.Errorf("%q: wrong candidates. got=%q, want=%q", tt.line, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package repl
+
+import (
+	"mscript/lexer"
+	"mscript/token"
+	"strings"
+)
+
+// Tokens that cannot end a statement, input ending in one continues on the next line
+var continuesLine = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.BANG:     true,
+	token.LT:       true,
+	token.GT:       true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.COMMA:    true,
+	token.DOT:      true,
+	token.COLON:    true,
+	token.LET:      true,
+	token.RETURN:   true,
+	token.IMPORT:   true,
+	token.EXPORT:   true,
+	token.AS:       true,
+	token.FUNCTION: true,
+	token.MACRO:    true,
+	token.IF:       true,
+	token.ELSE:     true,
+}
+
+// Reports whether src needs more lines before it can be parsed:
+// it has unclosed brackets, an unterminated string or ends in an operator
+func incomplete(src string) bool {
+	l := lexer.New(src)
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+
+	//An unterminated string runs to the end of the input, including its final newline
+	if last.Type == token.STRING && strings.HasSuffix(last.Literal, "\n") && strings.HasSuffix(src, last.Literal) {
+		return true
+	}
+	return depth > 0 || continuesLine[last.Type]
+}
@@ -0,0 +1,33 @@
+package repl
+
+import "testing"
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"", false},
+		{"1 + 2\n", false},
+		{"let f = fn(x) {\n", true},
+		{"let f = fn(x) {\nx\n}\n", false},
+		{"puts(1,\n", true},
+		{"[1, 2\n", true},
+		{"{\"a\": \n", true},
+		{"1 +\n", true},
+		{"let x =\n", true},
+		{"a.\n", true},
+		{"if (x) { 1 } else\n", true},
+		{"let s = \"abc\n", true},
+		{"let s = \"abc\ndef\"\n", false},
+		{"let s = \"\"\n", false},
+		{"1 + 2 // trailing {\n", false},
+		{"1)\n", false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("incomplete(%q) wrong. got=%t, want=%t", tt.input, got, tt.expected)
+		}
+	}
+}
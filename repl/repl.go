@@ -1,46 +1,264 @@
 package repl
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
+	"mscript/ast"
 	"mscript/evaluator"
+	"mscript/highlight"
 	"mscript/lexer"
+	"mscript/lineedit"
 	"mscript/object"
 	"mscript/parser"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT              = ">> "
+	CONTINUATION_PROMPT = ".. " //Shown while the input so far is incomplete
+	CANCEL              = ":cancel"
+	HISTORY_FILE        = ".mscript_history" //In the home directory
+)
+
+// How results are written
+type Format int
+
+const (
+	FormatInspect Format = iota //The value as Inspect shows it
+	FormatJSON                  //One JSON object per result, see result
+)
+
+type Option func(*session)
+
+// Leaves out the prompts, for input piped in by another program
+func WithQuiet(quiet bool) Option {
+	return func(s *session) { s.quiet = quiet }
+}
+
+// Writes results and errors in format
+func WithFormat(format Format) Option {
+	return func(s *session) { s.format = format }
+}
+
+// Grants the fs and os modules caps, by default they have none
+func WithCapabilities(caps object.Capabilities) Option {
+	return func(s *session) { s.caps = caps }
+}
+
+// The state one REPL keeps between inputs
+type session struct {
+	out    io.Writer
+	quiet  bool
+	format Format
+	color  bool
+	caps   object.Capabilities
+
+	rt     *object.Runtime
+	env    *object.Environment
+	macros *object.Environment
+	inputs []string //Every input evaluated without an error, for :save
+}
+
+func newSession(out io.Writer, opts ...Option) *session {
+	s := &session{out: out}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.reset()
+	return s
+}
+
+// Forgets every binding, macro and loaded module
+func (s *session) reset() {
+	s.rt = object.NewRuntime()
+	s.rt.Stdout = s.out
+	s.rt.Capabilities = s.caps
+	s.rt.Importer = evaluator.NewModuleLoader(evaluator.DefaultSearchPath()...)
+	s.env = object.NewModuleEnvironment("", s.rt)
+	s.macros = object.NewModuleEnvironment("", s.rt)
+	s.inputs = nil
+}
 
 // Reads user input and outputs tokens
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+// Input spanning several lines is collected until it is complete, :cancel or Ctrl-C drops it
+// Lines starting with ':' are commands, see :help
+// On a terminal lines can be edited, history is kept in ~/.mscript_history and Tab completes names
+// Everything, prompts included, is written to out
+// With WithColor results are colored by type and the line being edited by token
+// os.exit ends the session, Start returns its code
+func Start(in io.Reader, out io.Writer, opts ...Option) int {
+	s := newSession(out, opts...)
+	editor := lineedit.New(in, out)
+	editor.Complete = s.complete
+	if s.color {
+		editor.Highlight = highlight.ANSI
+	}
+	if editor.Interactive() {
+		if home, err := os.UserHomeDir(); err == nil {
+			editor.SetHistoryFile(filepath.Join(home, HISTORY_FILE))
+		}
+	}
+
+	var pending strings.Builder
 	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
-		}
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		prompt := PROMPT
+		if pending.Len() != 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+		if s.quiet {
+			prompt = ""
+		}
+		line, err := editor.ReadLine(prompt)
+		if err == lineedit.ErrInterrupted {
+			pending.Reset()
 			continue
 		}
+		if err != nil {
+			//Whatever is left is run so its errors are shown
+			if pending.Len() != 0 {
+				s.print(s.eval(pending.String(), ""))
+			}
+			return 0
+		}
+		editor.AddHistory(line)
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		if pending.Len() != 0 && strings.TrimSpace(line) == CANCEL {
+			pending.Reset()
+			continue
+		}
+		if pending.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			s.command(strings.TrimSpace(line))
+			continue
+		}
+
+		pending.WriteString(line)
+		pending.WriteString("\n")
+		if incomplete(pending.String()) {
+			continue
 		}
+		result := s.eval(pending.String(), "")
+		if code, exited := s.rt.Exited(); exited {
+			return code
+		}
+		s.print(result)
+		pending.Reset()
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+// Parses, expands and evaluates src, file is where imports are resolved from
+// Parser errors are printed and nil is returned
+func (s *session) eval(src string, file string) (result object.Object) {
+	//A bug in the interpreter ends the input that hit it, not the session
+	defer func() {
+		if r := recover(); r != nil {
+			result = &object.Error{Message: fmt.Sprintf("internal error: %v", r)}
+		}
+	}()
+
+	program, ok := s.parse(src)
+	if !ok {
+		return nil
+	}
+
+	evaluator.DefineMacros(program, s.macros)
+	expanded, err := evaluator.ExpandMacros(program, s.macros)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	s.rt.Reset()
+	env := s.env
+	if file != "" {
+		env = env.InFile(file)
+	}
+	result = evaluator.Eval(expanded, env)
+	if !isError(result) {
+		s.inputs = append(s.inputs, src)
+	}
+	return result
+}
+
+func (s *session) parse(src string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		s.printParserErrors(p.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+func (s *session) print(obj object.Object) {
+	if obj == nil {
+		return
+	}
+	if s.format == FormatJSON {
+		s.printJSON(newResult(obj))
+		return
+	}
+	if s.color {
+		io.WriteString(s.out, colorize(obj)+"\n")
+		return
+	}
+	io.WriteString(s.out, obj.Inspect())
+	io.WriteString(s.out, "\n")
+}
+
+// Evaluates the file at path into the session, its imports resolve relative to it
+func (s *session) load(path string) (object.Object, error) {
+	file, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return s.eval(src, file), nil
+}
+
+func (s *session) printParserErrors(errors []string) {
+	if s.format == FormatJSON {
+		s.printJSON(result{Type: "PARSE_ERROR", Errors: errors})
+		return
+	}
 	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+		if s.color {
+			msg = highlight.Color(highlight.Red, msg)
+		}
+		io.WriteString(s.out, "\t"+msg+"\n")
+	}
+}
+
+// A result in FormatJSON
+// Value holds the result converted to plain JSON, null when it has no JSON form
+type result struct {
+	Type    object.ObjectType `json:"type"`
+	Value   json.RawMessage   `json:"value,omitempty"`
+	Inspect string            `json:"inspect,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Errors  []string          `json:"errors,omitempty"`
+}
+
+func newResult(obj object.Object) result {
+	if errObj, ok := obj.(*object.Error); ok {
+		return result{Type: object.ERROR_OBJ, Error: errObj.Message}
+	}
+
+	r := result{Type: obj.Type(), Inspect: obj.Inspect(), Value: json.RawMessage("null")}
+	var value any
+	if err := object.ToGo(obj, &value); err == nil {
+		if data, err := json.Marshal(value); err == nil {
+			r.Value = data
+		}
 	}
+	return r
+}
+
+func (s *session) printJSON(r result) {
+	data, _ := json.Marshal(r)
+	s.out.Write(append(data, '\n'))
 }
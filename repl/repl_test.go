@@ -0,0 +1,118 @@
+package repl
+
+import (
+	"bytes"
+	"mscript/object"
+	"strings"
+	"testing"
+)
+
+func run(input string, opts ...Option) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, opts...)
+	return out.String()
+}
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2\n", ">> 3\n>> "},
+		{"let a = 5;\na * 2\n", ">> >> 10\n>> "},
+		{"puts(\"hi\")\n", ">> hi\nnull\n>> "},
+		{"let f = fn(x) {\nx * 2\n};\nf(4)\n", ">> .. .. >> 8\n>> "},
+		{"let f = fn(x) {\n:cancel\n1\n", ">> .. >> 1\n>> "},
+		{"[1,\n2]", ">> .. [1, 2]\n>> "},
+		{"[1,\n2", ">> .. .. \texpected next token to be ], got  instead\n"},
+		{"let 5;\n", ">> \texpected next token to be IDENT, got INT instead\n>> "},
+		{"1 + true\n", ">> ERROR: type mismatch: INTEGER + BOOLEAN\n>> "},
+		{":tokens 1\n", ">> 1:1\tINT       \"1\"\n>> "},
+		{"let f = fn() { let x = 1; };\nputs(f(), len([f()]))\n", ">> >> null\n1\nnull\n>> "},
+	}
+
+	for _, tt := range tests {
+		if got := run(tt.input); got != tt.expected {
+			t.Errorf("%q: wrong output.\ngot= %q\nwant=%q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	var out bytes.Buffer
+	code := Start(strings.NewReader("puts(1)\nos.exit(3)\nputs(2)\n"), &out, WithQuiet(true))
+	if code != 3 || out.String() != "1\nnull\n" {
+		t.Errorf("wrong exit. got=%d, %q", code, out.String())
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	if got := run(`os.env("HOME")`+"\n", WithQuiet(true)); got != "ERROR: os.env: permission denied, needs --allow-env\n" {
+		t.Errorf("sandboxed session. got=%q", got)
+	}
+	t.Setenv("MSCRIPT_TEST", "yes")
+	if got := run(`os.env("MSCRIPT_TEST")`+"\n", WithQuiet(true), WithCapabilities(object.Capabilities{Env: true})); got != "yes\n" {
+		t.Errorf("session with --allow-env. got=%q", got)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	got := run("let f = fn(x) {\nx + 1\n};\nf(1)\nputs(2)\n", WithQuiet(true))
+	expected := "2\n2\nnull\n"
+	if got != expected {
+		t.Errorf("wrong output.\ngot= %q\nwant=%q", got, expected)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	input := `5
+"five"
+[1, true, "x"]
+{"a": 1}
+{1: 2}
+fn(x) { x }
+if (false) { 1 }
+1 + true
+let 5;
+`
+	expected := []string{
+		`{"type":"INTEGER","value":5,"inspect":"5"}`,
+		`{"type":"STRING","value":"five","inspect":"five"}`,
+		`{"type":"ARRAY","value":[1,true,"x"],"inspect":"[1, true, x]"}`,
+		`{"type":"HASH","value":{"a":1},"inspect":"{a: 1}"}`,
+		`{"type":"HASH","value":{"1":2},"inspect":"{1: 2}"}`,
+		`{"type":"FUNCTION","value":null,"inspect":"fn(x) {\nx\n}"}`,
+		`{"type":"NULL","value":null,"inspect":"null"}`,
+		`{"type":"ERROR","error":"type mismatch: INTEGER + BOOLEAN"}`,
+		`{"type":"PARSE_ERROR","errors":["expected next token to be IDENT, got INT instead"]}`,
+	}
+
+	got := strings.Split(strings.TrimSuffix(run(input, WithQuiet(true), WithFormat(FormatJSON)), "\n"), "\n")
+	if len(got) != len(expected) {
+		t.Fatalf("wrong number of lines. got=%d, want=%d\n%s", len(got), len(expected), strings.Join(got, "\n"))
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("line %d wrong.\ngot= %s\nwant=%s", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestColor(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"\"hi\"\n", "\x1b[32mhi\x1b[0m\n"},
+		{"5\n", "\x1b[36m5\x1b[0m\n"},
+		{"[1, true]\n", "[\x1b[36m1\x1b[0m, \x1b[33mtrue\x1b[0m]\n"},
+		{"1 + true\n", "\x1b[31mERROR: type mismatch: INTEGER + BOOLEAN\x1b[0m\n"},
+		{"let 5;\n", "\t\x1b[31mexpected next token to be IDENT, got INT instead\x1b[0m\n"},
+	}
+
+	for _, tt := range tests {
+		if got := run(tt.input, WithQuiet(true), WithColor(true)); got != tt.expected {
+			t.Errorf("%q: wrong output.\ngot= %q\nwant=%q", tt.input, got, tt.expected)
+		}
+	}
+}
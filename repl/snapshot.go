@@ -0,0 +1,456 @@
+package repl
+
+import (
+	"encoding/json"
+	"fmt"
+	"mscript/ast"
+	"mscript/astjson"
+	"mscript/object"
+	"mscript/token"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Bumped whenever the snapshot encoding changes
+const snapshotVersion = 1
+
+// The bindings of a session written by :snapshot
+// Functions refer to the environment they close over by its index in Environments,
+// so closures sharing an environment still share it after :restore
+type snapshot struct {
+	Version      int           `json:"version"`
+	Globals      int           `json:"globals"`
+	Macros       int           `json:"macros"`
+	Environments []environment `json:"environments"`
+}
+
+type environment struct {
+	File     string    `json:"file,omitempty"`
+	Outer    *int      `json:"outer,omitempty"`
+	Bindings []binding `json:"bindings"`
+}
+
+type binding struct {
+	Name  string `json:"name"`
+	Value value  `json:"value"`
+}
+
+// One object, only the members its type needs are set
+type value struct {
+	Type       object.ObjectType `json:"type"`
+	Int        int64             `json:"int,omitempty"`
+	Float      float64           `json:"float,omitempty"`
+	String     string            `json:"string,omitempty"`
+	Bool       bool              `json:"bool,omitempty"`
+	Elements   []value           `json:"elements,omitempty"`
+	Pairs      []pair            `json:"pairs,omitempty"`
+	Parameters []string          `json:"parameters,omitempty"`
+	ParamTypes []json.RawMessage `json:"paramTypes,omitempty"` //Annotations of the parameters, null for those without one
+	ReturnType json.RawMessage   `json:"returnType,omitempty"`
+	Code       json.RawMessage   `json:"code,omitempty"` //Function bodies and quotes in the astjson encoding
+	Env        *int              `json:"env,omitempty"`
+	Name       string            `json:"name,omitempty"` //Builtins by name
+	Path       string            `json:"path,omitempty"` //Modules are imported again on restore
+}
+
+type pair struct {
+	Key   value `json:"key"`
+	Value value `json:"value"`
+}
+
+// Writes the bindings of the session to path
+// Bindings that cannot be encoded are left out and returned as warnings
+func (s *session) snapshot(path string) (skipped []string, err error) {
+	w := &snapshotWriter{ids: map[*object.Environment]int{}}
+	snap := snapshot{Version: snapshotVersion, Globals: w.env(s.env), Macros: w.env(s.macros)}
+	snap.Environments = w.envs
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return w.skipped, os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+type snapshotWriter struct {
+	ids     map[*object.Environment]int
+	envs    []environment
+	skipped []string
+}
+
+// Returns the index of e, adding it and the environments it refers to on first use
+func (w *snapshotWriter) env(e *object.Environment) int {
+	if id, ok := w.ids[e]; ok {
+		return id
+	}
+	id := len(w.envs)
+	w.ids[e] = id
+	w.envs = append(w.envs, environment{File: e.File()})
+
+	var outer *int
+	if e.Outer() != nil {
+		o := w.env(e.Outer())
+		outer = &o
+	}
+
+	bindings := []binding{}
+	for _, name := range e.Names() {
+		obj, _ := e.Get(name)
+		v, err := w.value(obj)
+		if err != nil {
+			w.skipped = append(w.skipped, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		bindings = append(bindings, binding{Name: name, Value: v})
+	}
+
+	//Indexing again since the recursive calls may have moved the slice
+	w.envs[id].Outer = outer
+	w.envs[id].Bindings = bindings
+	return id
+}
+
+func (w *snapshotWriter) value(obj object.Object) (value, error) {
+	v := value{Type: obj.Type()}
+	switch obj := obj.(type) {
+	case *object.Integer:
+		v.Int = obj.Value
+	case *object.Float:
+		v.Float = obj.Value
+	case *object.String:
+		v.String = obj.Value
+	case *object.Regex:
+		v.String = obj.Value.String()
+	case *object.Boolean:
+		v.Bool = obj.Value
+	case *object.Null:
+
+	case *object.Array:
+		for _, el := range obj.Elements {
+			ev, err := w.value(el)
+			if err != nil {
+				return v, err
+			}
+			v.Elements = append(v.Elements, ev)
+		}
+
+	case *object.Hash:
+		for _, p := range obj.SortedPairs() {
+			key, err := w.value(p.Key)
+			if err != nil {
+				return v, err
+			}
+			val, err := w.value(p.Value)
+			if err != nil {
+				return v, err
+			}
+			v.Pairs = append(v.Pairs, pair{Key: key, Value: val})
+		}
+
+	case *object.Function:
+		v, err := w.function(v, obj.Parameters, obj.Body, obj.Env)
+		if err != nil {
+			return v, err
+		}
+		return v, w.types(&v, obj.ParameterTypes, obj.ReturnType)
+
+	case *object.Macro:
+		return w.function(v, obj.Parameters, obj.Body, obj.Env)
+
+	case *object.Quote:
+		code, err := astjson.Marshal(obj.Node)
+		if err != nil {
+			return v, err
+		}
+		v.Code = code
+
+	case *object.Builtin:
+		//Builtins of builtin modules are named like module.name
+		for _, def := range object.Builtins {
+			if def.Value == obj {
+				v.Name = def.Name
+				return v, nil
+			}
+			if module, ok := def.Value.(*object.Module); ok {
+				for name, export := range module.Exports {
+					if export == obj {
+						v.Name = def.Name + "." + name
+						return v, nil
+					}
+				}
+			}
+		}
+		return v, fmt.Errorf("cannot snapshot a builtin that is not in object.Builtins")
+
+	case *object.Module:
+		v.Path = obj.Path
+		if obj.Path == "" {
+			v.Name = obj.Name
+		}
+
+	default:
+		return v, fmt.Errorf("cannot snapshot %s", obj.Type())
+	}
+	return v, nil
+}
+
+func (w *snapshotWriter) function(v value, params []*ast.Identifier, body *ast.BlockStatement, env *object.Environment) (value, error) {
+	for _, p := range params {
+		v.Parameters = append(v.Parameters, p.Value)
+	}
+	code, err := astjson.Marshal(body)
+	if err != nil {
+		return v, err
+	}
+	v.Code = code
+	id := w.env(env)
+	v.Env = &id
+	return v, nil
+}
+
+// Encodes the annotations of a function, nothing is written for a function without any
+func (w *snapshotWriter) types(v *value, params []*ast.TypeAnnotation, result *ast.TypeAnnotation) error {
+	for _, t := range params {
+		code, err := astjson.Marshal(t)
+		if err != nil {
+			return err
+		}
+		v.ParamTypes = append(v.ParamTypes, code)
+	}
+	if result != nil {
+		code, err := astjson.Marshal(result)
+		if err != nil {
+			return err
+		}
+		v.ReturnType = code
+	}
+	return nil
+}
+
+// Replaces the state of the session with the bindings in the snapshot at path
+// The inputs recorded for :save are kept
+func (s *session) restore(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("%s is not a snapshot: %s", path, err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("%s: unsupported snapshot version %d", path, snap.Version)
+	}
+
+	inputs := s.inputs
+	s.reset()
+	s.inputs = inputs
+
+	r := &snapshotReader{snap: snap, rt: s.rt, envs: map[int]*object.Environment{}}
+	r.envs[snap.Globals] = s.env
+	r.envs[snap.Macros] = s.macros
+	for id := range snap.Environments {
+		if _, err := r.env(id); err != nil {
+			return err
+		}
+	}
+	for id, e := range snap.Environments {
+		env := r.envs[id]
+		for _, b := range e.Bindings {
+			obj, err := r.value(b.Value)
+			if err != nil {
+				return fmt.Errorf("%s: %s", b.Name, err)
+			}
+			env.Set(b.Name, obj)
+		}
+	}
+	return nil
+}
+
+type snapshotReader struct {
+	snap snapshot
+	rt   *object.Runtime
+	envs map[int]*object.Environment
+}
+
+// Creates the environment with index id, the bindings are set once every environment exists
+func (r *snapshotReader) env(id int) (*object.Environment, error) {
+	if env, ok := r.envs[id]; ok {
+		return env, nil
+	}
+	if id < 0 || id >= len(r.snap.Environments) {
+		return nil, fmt.Errorf("environment %d does not exist", id)
+	}
+
+	e := r.snap.Environments[id]
+	var env *object.Environment
+	if e.Outer == nil {
+		env = object.NewModuleEnvironment(e.File, r.rt)
+	} else {
+		outer, err := r.env(*e.Outer)
+		if err != nil {
+			return nil, err
+		}
+		env = object.NewEnclosedEnvironment(outer)
+	}
+	r.envs[id] = env
+	return env, nil
+}
+
+func (r *snapshotReader) value(v value) (object.Object, error) {
+	switch v.Type {
+	case object.INTEGER_OBJ:
+		return &object.Integer{Value: v.Int}, nil
+	case object.FLOAT_OBJ:
+		return &object.Float{Value: v.Float}, nil
+	case object.STRING_OBJ:
+		return &object.String{Value: v.String}, nil
+	case object.REGEX_OBJ:
+		re, err := regexp.Compile(v.String)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Regex{Value: re}, nil
+	case object.BOOLEAN_OBJ:
+		if v.Bool {
+			return object.TRUE, nil
+		}
+		return object.FALSE, nil
+	case object.NULL_OBJ:
+		return object.NULL, nil
+
+	case object.ARRAY_OBJ:
+		elements := make([]object.Object, len(v.Elements))
+		for i, ev := range v.Elements {
+			el, err := r.value(ev)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case object.HASH_OBJ:
+		hash := object.NewHash()
+		for _, p := range v.Pairs {
+			key, err := r.value(p.Key)
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			val, err := r.value(p.Value)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(hashable, val)
+		}
+		return hash, nil
+
+	case object.FUNCTION_OBJ, object.MACRO_OBJ:
+		params := make([]*ast.Identifier, len(v.Parameters))
+		for i, name := range v.Parameters {
+			params[i] = &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+		}
+		node, err := astjson.Unmarshal(v.Code)
+		if err != nil {
+			return nil, err
+		}
+		body, ok := node.(*ast.BlockStatement)
+		if !ok {
+			return nil, fmt.Errorf("function body is not a block")
+		}
+		if v.Env == nil {
+			return nil, fmt.Errorf("function without an environment")
+		}
+		env, err := r.env(*v.Env)
+		if err != nil {
+			return nil, err
+		}
+		if v.Type == object.MACRO_OBJ {
+			return &object.Macro{Parameters: params, Body: body, Env: env}, nil
+		}
+		fn := &object.Function{Parameters: params, Body: body, Env: env}
+		if err := r.types(v, fn); err != nil {
+			return nil, err
+		}
+		return fn, nil
+
+	case object.QUOTE_OBJ:
+		node, err := astjson.Unmarshal(v.Code)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Quote{Node: node}, nil
+
+	case object.BUILTIN_OBJ:
+		name, member, inModule := strings.Cut(v.Name, ".")
+		builtin := object.GetBuiltinByName(name)
+		if module, ok := builtin.(*object.Module); ok && inModule {
+			builtin = module.Exports[member]
+		}
+		if builtin != nil {
+			return builtin, nil
+		}
+		return nil, fmt.Errorf("unknown builtin %q", v.Name)
+
+	case object.MODULE_OBJ:
+		//Builtin modules have no file
+		if v.Path == "" {
+			if module, ok := object.GetBuiltinByName(v.Name).(*object.Module); ok {
+				return module, nil
+			}
+			return nil, fmt.Errorf("unknown builtin module %q", v.Name)
+		}
+		//Imported as if by a script next to it, like :load the user chose the file
+		return r.rt.Importer.Import(v.Path, object.NewModuleEnvironment(v.Path, r.rt))
+	}
+
+	return nil, fmt.Errorf("cannot restore %s", v.Type)
+}
+
+// Writes every input evaluated without an error to path, in order
+func (s *session) save(path string) error {
+	var out strings.Builder
+	for _, input := range s.inputs {
+		out.WriteString(input)
+		if !strings.HasSuffix(input, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return os.WriteFile(path, []byte(out.String()), 0o644)
+}
+
+// Restores the annotations of fn encoded by snapshotWriter.types
+func (r *snapshotReader) types(v value, fn *object.Function) error {
+	for _, code := range v.ParamTypes {
+		t, err := annotation(code)
+		if err != nil {
+			return err
+		}
+		fn.ParameterTypes = append(fn.ParameterTypes, t)
+	}
+	if v.ReturnType != nil {
+		t, err := annotation(v.ReturnType)
+		if err != nil {
+			return err
+		}
+		fn.ReturnType = t
+	}
+	return nil
+}
+
+func annotation(code json.RawMessage) (*ast.TypeAnnotation, error) {
+	node, err := astjson.Unmarshal(code)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	t, ok := node.(*ast.TypeAnnotation)
+	if !ok {
+		return nil, fmt.Errorf("function annotation is not a type")
+	}
+	return t, nil
+}
@@ -0,0 +1,175 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.ms")
+	os.WriteFile(filepath.Join(dir, "shapes.ms"), []byte(`let sides = 4; export let square = fn(n) { n * sides };`), 0o644)
+
+	s := newSession(io.Discard)
+	s.eval(`
+	import "shapes.ms";
+	let newAdder = fn(x) { fn(y) { x + y } };
+	let addTwo = newAdder(2);
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	let data = [1, "two", true, {"k": [3]}, if (false) { 1 }];
+	let size = len;
+	let shout = strings.upper;
+	let text = strings;
+	let area = shapes.square;
+	let code = quote(1 + 2);
+	let twice = macro(x) { quote(unquote(x) + unquote(x)) };
+	let digits = re.compile("\d+");
+	`, main)
+
+	var out bytes.Buffer
+	s.out = &out
+	path := filepath.Join(dir, "session.snap")
+	s.command(":snapshot " + path)
+	if out.String() != "snapshot written to "+path+"\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	restored := newSession(io.Discard)
+	restored.out = &out
+	out.Reset()
+	restored.command(":restore " + path)
+	if out.String() != "restored "+path+"\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"addTwo(3)", "5"},
+		{"fib(10)", "55"},
+		{"data", "[1, two, true, {k: [3]}, null]"},
+		{"size(data)", "5"},
+		{"shout(\"hi\")", "HI"},
+		{"text.lower(\"HI\")", "hi"},
+		{"area(3)", "12"},
+		{"shapes.square(2)", "8"},
+		{"code", "QUOTE((1 + 2))"},
+		{"twice(21)", "42"},
+		{`re.replace(digits, "a1b22", "#")`, "a#b#"},
+		{"let addTen = newAdder(10); addTen(1)", "11"},
+	}
+	for _, tt := range tests {
+		got := restored.eval(tt.input, "")
+		if got == nil || got.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. got=%v, want=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSnapshotTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.snap")
+	s := newSession(io.Discard)
+	s.eval(`let scale = fn(x: int, by): [int] { [x, by] }; let plain = fn(x) { x };`, "")
+	if _, err := s.snapshot(path); err != nil {
+		t.Fatalf("snapshot error: %s", err)
+	}
+
+	restored := newSession(io.Discard)
+	if err := restored.restore(path); err != nil {
+		t.Fatalf("restore error: %s", err)
+	}
+	if got := restored.eval("scale", ""); got.Inspect() != "fn(x: int, by): [int] {\n[x, by]\n}" {
+		t.Errorf("annotations lost. got=%q", got.Inspect())
+	}
+
+	//Strict mode still checks the restored function
+	restored.rt.Strict = true
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`scale(2, 3)`, "[2, 3]"},
+		{`scale("a", 3)`, "ERROR: cannot use STRING as int for parameter x"},
+		{`scale(2, "a")`, "ERROR: cannot use ARRAY as [int] in return"},
+		{`plain("a")`, "a"},
+	}
+	for _, tt := range tests {
+		if got := restored.eval(tt.input, ""); got.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. got=%s, want=%s", tt.input, got.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestSnapshotSharedEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	s := newSession(io.Discard)
+	s.eval(`let pair = fn(x) { [fn() { x }, fn() { x + 1 }] }; let p = pair(5); let get = p[0]; let next = p[1];`, "")
+
+	path := filepath.Join(dir, "s.snap")
+	if _, err := s.snapshot(path); err != nil {
+		t.Fatalf("snapshot error: %s", err)
+	}
+	data, _ := os.ReadFile(path)
+	//The session, the macros, the call to pair and nothing more
+	if n := strings.Count(string(data), `"bindings"`); n != 3 {
+		t.Errorf("closures over one call should share an environment. got %d environments", n)
+	}
+
+	restored := newSession(io.Discard)
+	if err := restored.restore(path); err != nil {
+		t.Fatalf("restore error: %s", err)
+	}
+	if got := restored.eval("get() + next()", ""); got.Inspect() != "11" {
+		t.Errorf("wrong result. got=%s", got.Inspect())
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.snap")
+	os.WriteFile(bad, []byte(`not json`), 0o644)
+	old := filepath.Join(dir, "old.snap")
+	os.WriteFile(old, []byte(`{"version": 99}`), 0o644)
+
+	var out bytes.Buffer
+	s := newSession(&out)
+	s.command(":restore " + filepath.Join(dir, "missing.snap"))
+	s.command(":restore " + bad)
+	s.command(":restore " + old)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 errors. got=%q", out.String())
+	}
+	if !strings.Contains(lines[1], "is not a snapshot") || !strings.Contains(lines[2], "unsupported snapshot version 99") {
+		t.Errorf("wrong errors. got=%q", out.String())
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.ms")
+	input := "let a = 1;\n1 + true\nlet f = fn(x) {\nx + a\n};\nlet 5;\n:save " + path + "\n"
+
+	output := run(input, WithQuiet(true))
+	if !strings.HasSuffix(output, "saved 2 inputs to "+path+"\n") {
+		t.Errorf("wrong output. got=%q", output)
+	}
+
+	data, _ := os.ReadFile(path)
+	expected := "let a = 1;\nlet f = fn(x) {\nx + a\n};\n"
+	if string(data) != expected {
+		t.Errorf("wrong file.\ngot= %q\nwant=%q", data, expected)
+	}
+
+	//The saved session replays to the same state
+	s := newSession(io.Discard)
+	s.command(":load " + path)
+	if got := s.eval("f(1)", ""); got.Inspect() != "2" {
+		t.Errorf("wrong result after load. got=%s", got.Inspect())
+	}
+}
@@ -1,11 +1,14 @@
 package token
 
+import "sort"
+
 //All TokenTypes
 const (
 	ILLEGAL   = "ILLEGAL"
 	EOF       = ""
 	IDENT     = "IDENT"
 	INT       = "INT"
+	FLOAT     = "FLOAT"
 	ASSIGN    = "="
 	PLUS      = "+"
 	MINUS     = "-"
@@ -13,11 +16,15 @@ const (
 	ASTERISK  = "*"
 	SLASH     = "/"
 	COMMA     = ","
+	DOT       = "."
 	SEMICOLON = ";"
+	COLON     = ":"
 	LPAREN    = "("
 	RPAREN    = ")"
 	LBRACE    = "{"
 	RBRACE    = "}"
+	LBRACKET  = "["
+	RBRACKET  = "]"
 	GT        = ">"
 	LT        = "<"
 	EQ        = "=="
@@ -30,6 +37,10 @@ const (
 	ELSE      = "ELSE"
 	RETURN    = "RETURN"
 	STRING    = "STRING"
+	IMPORT    = "IMPORT"
+	EXPORT    = "EXPORT"
+	AS        = "AS"
+	MACRO     = "MACRO"
 )
 
 //Keywords mapped to TokenTypes
@@ -41,6 +52,10 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"import": IMPORT,
+	"export": EXPORT,
+	"as":     AS,
+	"macro":  MACRO,
 }
 
 //Creating new type TokenType set to a string
@@ -51,6 +66,8 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int //1 based line of the first character
+	Column  int //1 based byte column of the first character
 }
 
 //Used for debugging
@@ -61,3 +78,13 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+//Returns every keyword in sorted order
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
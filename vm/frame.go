@@ -0,0 +1,21 @@
+package vm
+
+import (
+	"mscript/Code"
+	"mscript/object"
+)
+
+// A call frame, one per active function call
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int //Stack pointer before the call, locals live above it
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}